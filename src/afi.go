@@ -0,0 +1,133 @@
+package main
+
+// address families of a neighbor, by short name, from its
+// " For address family:" sections (or the summary command echo):
+//
+//	IPv4 Unicast   ipv4
+//	VPNv4 Unicast  vpnv4
+//	VPNv6 Unicast  vpnv6
+//	L2VPN E-VPN    l2vpn-evpn
+//	IPv4 Multicast ipv4-multicast
+//
+// The prefix count of a neighbor is the sum over its address families;
+// -afi narrows it, and the neighbor list, to the selected ones.
+
+import (
+	"strconv"
+	"strings"
+)
+
+// afiName returns the short name of an address family.
+func afiName(af string) string {
+	f := strings.Fields(strings.ToLower(af))
+	if len(f) == 0 {
+		return ""
+	}
+	name := f[0]
+	for _, safi := range f[1:] {
+		if safi == "unicast" {
+			continue
+		}
+		name += "-" + strings.Replace(safi, "e-vpn", "evpn", 1)
+	}
+	return name
+}
+
+var afiDisplayNames = map[string]string{"ipv4": "IPv4", "ipv6": "IPv6", "vpnv4": "VPNv4", "vpnv6": "VPNv6", "l2vpn": "L2VPN"}
+
+// summaryCommandAF returns the address family of a summary command echo,
+// "" for all address families:
+// pe1#show bgp vpnv4 unicast all summary     VPNv4 Unicast
+// pe1#show ip bgp summary                    IPv4 Unicast
+func summaryCommandAF(line string) string {
+	f := splitFields(strings.ToLower(line))
+	afi := f.after("bgp")
+	name, ok := afiDisplayNames[afi]
+	if !ok {
+		if f.after("ip") == "bgp" {
+			return "IPv4 Unicast"
+		}
+		return ""
+	}
+	switch f.after("bgp", afi) {
+	case "multicast":
+		return name + " Multicast"
+	case "evpn":
+		return name + " E-VPN"
+	}
+	return name + " Unicast"
+}
+
+// afis returns the short names of the address families of n: those with a
+// section in the capture, or else those negotiated.
+func (n *neigh) afis() []string {
+	var list []string
+	for _, p := range n.Policies {
+		list = appendUnique(list, afiName(p.AddressFamily))
+	}
+	if len(list) > 0 {
+		return list
+	}
+	for _, af := range n.negotiatedFamilies() {
+		list = appendUnique(list, afiName(af))
+	}
+	return list
+}
+
+// sumPrefixes sets the prefix count of n to the sum over its address
+// families.
+func (n *neigh) sumPrefixes() {
+	n.Prefixes = 0
+	for _, p := range n.Policies {
+		n.Prefixes += p.Prefixes
+	}
+}
+
+// matchAFI reports whether any address family of n matches m; a negated
+// m matches neighbors without any of them.
+func (m *matcher) matchAFI(n *neigh) bool {
+	if m == nil {
+		return true
+	}
+	for _, afi := range n.afis() {
+		if m.matchPattern(afi) {
+			return !m.negate
+		}
+	}
+	return m.negate
+}
+
+// selectAFI returns a copy of n keeping only the address families matched
+// by m, with the prefix count summed over them.
+func (m *matcher) selectAFI(n *neigh) *neigh {
+	if m == nil || len(n.Policies) == 0 {
+		return n
+	}
+	c := *n
+	c.Policies = nil
+	for _, p := range n.Policies {
+		if m.match(afiName(p.AddressFamily)) {
+			c.Policies = append(c.Policies, p)
+		}
+	}
+	c.sumPrefixes()
+	return &c
+}
+
+// afiPrefixes returns the column value of the prefixes received in afi,
+// "" when n has no such address family.
+func afiPrefixes(afi string) func(n *neigh) string {
+	return func(n *neigh) string {
+		count, found := 0, false
+		for _, p := range n.Policies {
+			if afiName(p.AddressFamily) == afi {
+				count += p.Prefixes
+				found = true
+			}
+		}
+		if !found {
+			return ""
+		}
+		return strconv.Itoa(count)
+	}
+}
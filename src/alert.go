@@ -0,0 +1,337 @@
+package main
+
+// alert rules evaluated after each collection:
+// state != Established
+// prefixes == 0
+// prefix_delta > 20%   (change since the previous collection)
+// max_prefix_pct >= 80 (prefixes received, percent of the max-prefix limit)
+// prefix_jump > 1000,50% (increase since the previous collection, leak category; see leak.go)
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type alertRule struct {
+	spec  string
+	field string
+	op    string
+	match *matcher  // string fields
+	value float64   // numeric fields
+	delta threshold // prefix_delta
+	jump  leakGuard // prefix_jump
+}
+
+const (
+	alertCategoryRule = "rule"
+	alertCategoryLeak = "leak" // prefix_jump
+)
+
+func (r *alertRule) category() string {
+	if r.field == "prefix_jump" {
+		return alertCategoryLeak
+	}
+	return alertCategoryRule
+}
+
+var alertStringFields = map[string]func(n *neigh) string{
+	"device":   func(n *neigh) string { return n.Device },
+	"addr":     func(n *neigh) string { return n.Addr },
+	"vrf":      func(n *neigh) string { return n.VRF },
+	"asn":      func(n *neigh) string { return n.RemoteAS },
+	"state":    func(n *neigh) string { return n.State },
+	"shutdown": func(n *neigh) string { return yesNo(n.Shutdown) },
+	"link":     func(n *neigh) string { return n.Link },
+	"auth":     func(n *neigh) string { return authValue(n) },
+}
+
+var alertNumericFields = map[string]func(n *neigh) (float64, bool){
+	"prefixes": func(n *neigh) (float64, bool) { return float64(n.Prefixes), true },
+	"uptime_seconds": func(n *neigh) (float64, bool) {
+		if n.UptimeSeconds == nil {
+			return 0, false
+		}
+		return float64(*n.UptimeSeconds), true
+	},
+	"in_q":           func(n *neigh) (float64, bool) { return float64(n.InQ), true },
+	"out_q":          func(n *neigh) (float64, bool) { return float64(n.OutQ), true },
+	"max_prefix_pct": func(n *neigh) (float64, bool) { return n.maxPrefixUsage() },
+	"hold_time": func(n *neigh) (float64, bool) {
+		if n.Timers == nil {
+			return 0, false
+		}
+		return float64(n.Timers.HoldTime), true
+	},
+	"dropped": func(n *neigh) (float64, bool) {
+		if n.Connections == nil {
+			return 0, false
+		}
+		return float64(n.Connections.Dropped), true
+	},
+	"drop_ratio": func(n *neigh) (float64, bool) { return n.dropRatio() },
+}
+
+var alertOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseAlertRule parses "field op value".
+// String fields accept == and != with -vrf/-state matcher patterns.
+// Numeric fields accept ==, !=, <, <=, >, >=.
+// prefix_delta accepts > with an absolute or percent threshold, as diff -threshold.
+func parseAlertRule(spec string) (*alertRule, error) {
+	f := strings.Fields(spec)
+	if len(f) != 3 {
+		return nil, fmt.Errorf("parseAlertRule: expecting 'field op value': [%s]", spec)
+	}
+	r := &alertRule{spec: spec, field: f[0], op: f[1]}
+
+	validOp := false
+	for _, op := range alertOps {
+		if r.op == op {
+			validOp = true
+		}
+	}
+	if !validOp {
+		return nil, fmt.Errorf("parseAlertRule: bad operator: [%s]", spec)
+	}
+
+	if _, ok := alertStringFields[r.field]; ok {
+		if r.op != "==" && r.op != "!=" {
+			return nil, fmt.Errorf("parseAlertRule: %s supports only == and !=: [%s]", r.field, spec)
+		}
+		m, err := parseMatcher(f[2])
+		if err != nil {
+			return nil, fmt.Errorf("parseAlertRule: %v", err)
+		}
+		r.match = m
+		return r, nil
+	}
+
+	if _, ok := alertNumericFields[r.field]; ok {
+		v, err := strconv.ParseFloat(f[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parseAlertRule: bad number: [%s]", spec)
+		}
+		r.value = v
+		return r, nil
+	}
+
+	if r.field == "prefix_delta" {
+		if r.op != ">" {
+			return nil, fmt.Errorf("parseAlertRule: prefix_delta supports only >: [%s]", spec)
+		}
+		t, err := parseThreshold(f[2])
+		if err != nil {
+			return nil, fmt.Errorf("parseAlertRule: %v", err)
+		}
+		r.delta = t
+		return r, nil
+	}
+
+	if r.field == "prefix_jump" {
+		if r.op != ">" {
+			return nil, fmt.Errorf("parseAlertRule: prefix_jump supports only >: [%s]", spec)
+		}
+		g, err := parseLeakGuard(f[2])
+		if err != nil {
+			return nil, fmt.Errorf("parseAlertRule: %v", err)
+		}
+		r.jump = g
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("parseAlertRule: unknown field: [%s]", spec)
+}
+
+// fires reports whether the rule matches neighbor n.
+// prev is the same neighbor from the previous collection, or nil.
+func (r *alertRule) fires(n, prev *neigh) bool {
+	if get, ok := alertStringFields[r.field]; ok {
+		return r.match.match(get(n)) == (r.op == "==")
+	}
+	if get, ok := alertNumericFields[r.field]; ok {
+		v, known := get(n)
+		if !known {
+			return false
+		}
+		switch r.op {
+		case "==":
+			return v == r.value
+		case "!=":
+			return v != r.value
+		case "<":
+			return v < r.value
+		case "<=":
+			return v <= r.value
+		case ">":
+			return v > r.value
+		case ">=":
+			return v >= r.value
+		}
+		return false
+	}
+	if r.field == "prefix_jump" {
+		return r.jump.jumped(prev, n)
+	}
+	// prefix_delta
+	return prev != nil && r.delta.exceeded(prev.Prefixes, n.Prefixes)
+}
+
+// alertRules collects repeated -alert flags.
+type alertRules []*alertRule
+
+func (rules *alertRules) String() string {
+	var specs []string
+	for _, r := range *rules {
+		specs = append(specs, r.spec)
+	}
+	return strings.Join(specs, "; ")
+}
+
+func (rules *alertRules) Set(spec string) error {
+	r, err := parseAlertRule(spec)
+	if err != nil {
+		return err
+	}
+	*rules = append(*rules, r)
+	return nil
+}
+
+// alertEvent is the JSON document posted to the webhook or written to the command stdin.
+type alertEvent struct {
+	Rule     string    `json:"rule"`
+	Category string    `json:"category"` // rule, or leak for prefix_jump
+	Time     time.Time `json:"time"`
+	Neighbor *neigh    `json:"neighbor"`
+	Previous *neigh    `json:"previous,omitempty"` // previous collection, if any
+}
+
+type alerter struct {
+	rules   alertRules
+	webhook string // POST alert JSON to URL
+	command string // run command with alert JSON on stdin
+	active  map[string]bool
+}
+
+func newAlerter(rules alertRules, webhook, command string) *alerter {
+	return &alerter{rules: rules, webhook: webhook, command: command, active: map[string]bool{}}
+}
+
+// evaluate checks the rules against curr. An alert is raised when a rule
+// starts matching a neighbor; it is not raised again while the rule keeps
+// matching on later collections. prev may be nil.
+func (a *alerter) evaluate(prev, curr map[string]*neigh) {
+	list := neighborList(curr)
+	sortNeighbors(list, nil)
+
+	active := map[string]bool{}
+	for _, n := range list {
+		k := neighKey(n)
+		p := prev[k]
+		for _, r := range a.rules {
+			if !r.fires(n, p) {
+				continue
+			}
+			id := r.spec + "|" + k
+			active[id] = true
+			if a.active[id] {
+				continue // already raised
+			}
+			a.raise(alertEvent{Rule: r.spec, Category: r.category(), Time: time.Now(), Neighbor: n, Previous: p})
+		}
+	}
+	a.active = active
+}
+
+// prime marks the rules already matching the previous table as raised,
+// so a single run with -alert-state only alerts on new matches.
+func (a *alerter) prime(prev map[string]*neigh) {
+	for k, n := range prev {
+		for _, r := range a.rules {
+			if r.fires(n, nil) {
+				a.active[r.spec+"|"+k] = true
+			}
+		}
+	}
+}
+
+func (a *alerter) raise(e alertEvent) {
+	warnf("alert: %s: %s: %s vrf=%s state=%s prefixes=%d", e.Category, e.Rule, e.Neighbor.Addr, e.Neighbor.VRF, e.Neighbor.State, e.Neighbor.Prefixes)
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		errorf("alert: %v", err)
+		return
+	}
+
+	if a.webhook != "" {
+		if err := postAlert(a.webhook, body); err != nil {
+			errorf("alert: %v", err)
+		}
+	}
+
+	if a.command != "" {
+		cmd := shellCommand(a.command)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			errorf("alert: %s: %v", a.command, err)
+		}
+	}
+}
+
+func postAlert(url string, body []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("postAlert: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("postAlert: %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// loadTableJSON reads a table saved by saveTableJSON.
+// A missing file yields a nil table.
+func loadTableJSON(path string) (map[string]*neigh, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadTableJSON: %v", err)
+	}
+	defer f.Close()
+	var list []*neigh
+	if err := json.NewDecoder(f).Decode(&list); err != nil {
+		return nil, fmt.Errorf("loadTableJSON: %s: %v", path, err)
+	}
+	table := map[string]*neigh{}
+	for _, n := range list {
+		table[neighKey(n)] = n
+	}
+	return table, nil
+}
+
+func saveTableJSON(path string, table map[string]*neigh) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("saveTableJSON: %v", err)
+	}
+	if err := writeJSON(f, neighborList(table)); err != nil {
+		f.Close()
+		return fmt.Errorf("saveTableJSON: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("saveTableJSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAlertRule(t *testing.T) {
+	up := int64(300)
+	n := &neigh{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", RemoteAS: "65001", State: "Established", Prefixes: 850,
+		UptimeSeconds: &up, Policies: []*afPolicy{{AddressFamily: "IPv4 Unicast", Prefixes: 850, MaxPrefix: 1000}}}
+	idle := &neigh{Addr: "198.51.100.2", VRF: "CUST-B", State: "Idle", Shutdown: true}
+	prev := &neigh{Addr: "198.51.100.1", VRF: "CUST-A", State: "Established", Prefixes: 600}
+	cases := []struct {
+		rule     string
+		n, prev  *neigh
+		fires    bool
+		category string
+	}{
+		{"state != Established", n, nil, false, alertCategoryRule},
+		{"state != Established", idle, nil, true, alertCategoryRule},
+		{"state == Idle,Active", idle, nil, true, alertCategoryRule},
+		{"vrf == CUST-*", n, nil, true, alertCategoryRule},
+		{"shutdown == yes", idle, nil, true, alertCategoryRule},
+		{"prefixes == 0", idle, nil, true, alertCategoryRule},
+		{"prefixes >= 850", n, nil, true, alertCategoryRule},
+		{"prefixes < 850", n, nil, false, alertCategoryRule},
+		{"uptime_seconds < 600", n, nil, true, alertCategoryRule},
+		{"uptime_seconds < 600", idle, nil, false, alertCategoryRule}, // unknown uptime
+		{"max_prefix_pct >= 80", n, nil, true, alertCategoryRule},
+		{"max_prefix_pct >= 90", n, nil, false, alertCategoryRule},
+		{"prefix_delta > 20%", n, prev, true, alertCategoryRule},
+		{"prefix_delta > 300", n, prev, false, alertCategoryRule},
+		{"prefix_delta > 20%", n, nil, false, alertCategoryRule},
+		{"prefix_jump > 200,40%", n, prev, true, alertCategoryLeak},
+		{"prefix_jump > 200,50%", n, prev, false, alertCategoryLeak},
+	}
+	for _, c := range cases {
+		r, err := parseAlertRule(c.rule)
+		if err != nil {
+			t.Errorf("parseAlertRule(%q): %v", c.rule, err)
+			continue
+		}
+		if got := r.fires(c.n, c.prev); got != c.fires {
+			t.Errorf("%q on %s: fires = %v, want %v", c.rule, c.n.Addr, got, c.fires)
+		}
+		if r.category() != c.category {
+			t.Errorf("%q: category %s, want %s", c.rule, r.category(), c.category)
+		}
+	}
+	for _, bad := range []string{
+		"state Established",
+		"state =~ Idle",
+		"state > Idle",
+		"prefixes > many",
+		"prefix_delta < 10%",
+		"prefix_jump >= 1000",
+		"prefix_jump > 1000,x",
+		"color == red",
+		"vrf == /(/",
+	} {
+		if _, err := parseAlertRule(bad); err == nil {
+			t.Errorf("parseAlertRule(%q): want error", bad)
+		}
+	}
+}
+
+// TestAlerterState checks that an alert is raised once when a rule starts
+// matching, and again only after it stopped matching.
+func TestAlerterState(t *testing.T) {
+	logger.mu.Lock()
+	out := logger.out
+	logger.out = io.Discard
+	logger.mu.Unlock()
+	defer func() {
+		logger.mu.Lock()
+		logger.out = out
+		logger.mu.Unlock()
+	}()
+
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+	var rules alertRules
+	if err := rules.Set("state != Established"); err != nil {
+		t.Fatal(err)
+	}
+	a := newAlerter(rules, "", "cat >> "+events+"; echo >> "+events)
+
+	table := func(states ...string) map[string]*neigh {
+		t := map[string]*neigh{}
+		for i, s := range states {
+			n := &neigh{Addr: "198.51.100." + strconv.Itoa(i+1), VRF: "CUST-A", State: s}
+			t[neighKey(n)] = n
+		}
+		return t
+	}
+	raised := func() []string {
+		b, _ := os.ReadFile(events)
+		var list []string
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			if line == "" {
+				continue
+			}
+			var e alertEvent
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("bad event %q: %v", line, err)
+			}
+			list = append(list, e.Neighbor.Addr+" "+e.Neighbor.State)
+		}
+		return list
+	}
+
+	runs := []struct {
+		states []string
+		want   string // all events so far
+	}{
+		{[]string{"Established", "Idle"}, "198.51.100.2 Idle"},
+		{[]string{"Established", "Active"}, "198.51.100.2 Idle"},
+		{[]string{"Idle", "Established"}, "198.51.100.2 Idle,198.51.100.1 Idle"},
+		{[]string{"Idle", "Idle"}, "198.51.100.2 Idle,198.51.100.1 Idle,198.51.100.2 Idle"},
+	}
+	var prev map[string]*neigh
+	for i, r := range runs {
+		curr := table(r.states...)
+		a.evaluate(prev, curr)
+		prev = curr
+		if got := strings.Join(raised(), ","); got != r.want {
+			t.Errorf("run %d: events %s, want %s", i+1, got, r.want)
+		}
+	}
+
+	// -alert-state: neighbors already down in the saved collection are not raised again
+	b := newAlerter(rules, "", "cat >> "+events+"; echo >> "+events)
+	b.prime(table("Idle", "Established"))
+	b.evaluate(nil, table("Idle", "Idle"))
+	if got := raised(); len(got) != 4 || got[3] != "198.51.100.2 Idle" {
+		t.Errorf("primed alerter: events %v", got)
+	}
+}
+
+func TestTableJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if table, err := loadTableJSON(path); err != nil || table != nil {
+		t.Errorf("missing file: got %v, %v", table, err)
+	}
+	n := &neigh{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", RemoteAS: "65001", State: "Established", Prefixes: 26}
+	if err := saveTableJSON(path, map[string]*neigh{neighKey(n): n}); err != nil {
+		t.Fatal(err)
+	}
+	table, err := loadTableJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table[neighKey(n)]; got == nil || got.Prefixes != 26 || got.Device != "pe1" {
+		t.Errorf("round trip: %+v", got)
+	}
+	os.WriteFile(path, []byte("{"), 0600)
+	if _, err := loadTableJSON(path); err == nil {
+		t.Error("bad JSON: want error")
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+// anonymization for sharing captures: addresses, AS numbers, VRF names,
+// descriptions, peer groups and device names are replaced by keyed hashes,
+// so the same value always maps to the same replacement and relationships
+// survive.
+// IPv4 maps into 10.0.0.0/8, IPv6 into 2001:db8::/32, ASNs into the
+// private range 4200000000-4294967294.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type anonymizer struct {
+	key  []byte
+	seen map[string]string // kind|value -> replacement
+	used map[string]bool   // kind|replacement, to resolve collisions
+}
+
+// newAnonymizer returns an anonymizer keyed by key.
+// An empty key picks a random one, so replacements change on every run.
+func newAnonymizer(key string) *anonymizer {
+	a := &anonymizer{key: []byte(key), seen: map[string]string{}, used: map[string]bool{}}
+	if key == "" {
+		a.key = make([]byte, 32)
+		rand.Read(a.key)
+	}
+	return a
+}
+
+// replace maps value to gen(hash), retrying with a counter on collision.
+func (a *anonymizer) replace(kind, value string, gen func(h []byte) string) string {
+	if r, ok := a.seen[kind+"|"+value]; ok {
+		return r
+	}
+	for i := 0; ; i++ {
+		mac := hmac.New(sha256.New, a.key)
+		fmt.Fprintf(mac, "%s|%s|%d", kind, value, i)
+		r := gen(mac.Sum(nil))
+		if !a.used[kind+"|"+r] {
+			a.seen[kind+"|"+value] = r
+			a.used[kind+"|"+r] = true
+			return r
+		}
+	}
+}
+
+// addr anonymizes an IPv4 or IPv6 address. Other values are hashed as names.
+func (a *anonymizer) addr(s string) string {
+	ip := net.ParseIP(s)
+	switch {
+	case s == "":
+		return s
+	case ip == nil:
+		return a.name("PEER", s)
+	case ip.IsUnspecified():
+		return s // e.g. router ID 0.0.0.0 of a down session
+	case ip.To4() != nil:
+		return a.replace("ipv4", ip.String(), func(h []byte) string {
+			return net.IPv4(10, h[0], h[1], h[2]).String()
+		})
+	}
+	return a.replace("ipv6", ip.String(), func(h []byte) string {
+		v6 := make(net.IP, net.IPv6len)
+		copy(v6, []byte{0x20, 0x01, 0x0d, 0xb8})
+		copy(v6[4:], h)
+		return v6.String()
+	})
+}
+
+func (a *anonymizer) asn(s string) string {
+	if s == "" || s == "?" {
+		return s
+	}
+	return a.replace("asn", s, func(h []byte) string {
+		return strconv.FormatUint(4200000000+uint64(binary.BigEndian.Uint32(h))%94967295, 10)
+	})
+}
+
+// name replaces s with prefix followed by a short hash, e.g. VRF-3fa2c1.
+func (a *anonymizer) name(prefix, s string) string {
+	if s == "" {
+		return s
+	}
+	return a.replace(prefix, s, func(h []byte) string {
+		return prefix + "-" + hex.EncodeToString(h[:3])
+	})
+}
+
+func (a *anonymizer) vrf(s string) string {
+	if s == vrfGlobalVPN || s == vrfDefault {
+		return s
+	}
+	return a.name("VRF", s)
+}
+
+func (a *anonymizer) neighbor(n *neigh) {
+	n.Device = a.name("DEV", n.Device)
+	n.Addr = a.addr(n.Addr)
+	n.VRF = a.vrf(n.VRF)
+	n.RemoteAS = a.asn(n.RemoteAS)
+	n.LocalAS = a.asn(n.LocalAS)
+	n.RouterID = a.addr(n.RouterID)
+	n.LocalRouterID = a.addr(n.LocalRouterID)
+	n.LocalHost = a.addr(n.LocalHost)
+	n.ForeignHost = a.addr(n.ForeignHost)
+	n.Description = a.name("DESC", n.Description)
+	n.PeerGroup = a.name("PG", n.PeerGroup)
+	n.ListenRange = a.prefix(n.ListenRange)
+	n.ClusterID = a.addr(n.ClusterID)
+	n.RD = a.rd(n.RD)
+	if n.Extra != nil {
+		extra := map[string]string{} // n may share the map with the table copied
+		for k, v := range n.Extra {
+			extra[k] = a.name("EXTRA", v)
+		}
+		n.Extra = extra
+	}
+}
+
+// rd anonymizes the address of a type 1 route distinguisher, e.g.
+// 192.0.2.1:100. ASN:nn route distinguishers are kept, as in scrubbed
+// captures.
+func (a *anonymizer) rd(s string) string {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 || net.ParseIP(s[:i]).To4() == nil {
+		return s
+	}
+	return a.addr(s[:i]) + s[i:]
+}
+
+// policyNames replaces the VRF, device and peer group names within the
+// route map and filter names of n, as scrub does in the capture.
+func (a *anonymizer) policyNames(n *neigh, namePattern *regexp.Regexp, names map[string]string) {
+	if namePattern == nil || len(n.Policies) == 0 {
+		return
+	}
+	policies := make([]*afPolicy, len(n.Policies)) // shared with the table copied
+	for i, p := range n.Policies {
+		c := *p
+		for _, name := range []*string{&c.RouteMapIn, &c.RouteMapOut, &c.PrefixListIn, &c.PrefixListOut,
+			&c.FilterListIn, &c.FilterListOut, &c.DistributeListIn, &c.DistributeListOut} {
+			*name = namePattern.ReplaceAllStringFunc(*name, func(m string) string { return names[m] })
+		}
+		policies[i] = &c
+	}
+	n.Policies = policies
+}
+
+// prefix anonymizes the address of addr/len, keeping the length.
+func (a *anonymizer) prefix(s string) string {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return a.addr(s)
+	}
+	p := a.addr(s[:i]) + s[i:]
+	if _, network, err := net.ParseCIDR(p); err == nil {
+		return network.String()
+	}
+	return p
+}
+
+// table returns a copy of t with anonymized neighbors, keyed again.
+func (a *anonymizer) table(t map[string]*neigh) map[string]*neigh {
+	names, namePattern := a.knownNames(t)
+	anon := map[string]*neigh{}
+	for _, n := range t {
+		c := *n
+		a.neighbor(&c)
+		a.policyNames(&c, namePattern, names)
+		anon[neighKey(&c)] = &c
+	}
+	return anon
+}
+
+var (
+	anonIPv4Pattern    = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+	anonIPv6Pattern    = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(:[0-9A-Fa-f]{0,4}){2,7}`)
+	anonASNPattern     = regexp.MustCompile(`\b(AS (?:number )?)(\d+(?:\.\d+)?)\b`)
+	anonSummaryPattern = regexp.MustCompile(`^(\*?\S*\s+4\s+)(\d+(?:\.\d+)?)(\s+\d+\s+\d+\s)`)
+)
+
+// knownNames returns the replacements of the VRF, device and peer group
+// names of the neighbors in known, and a pattern matching those names as
+// words (nil without any).
+func (a *anonymizer) knownNames(known map[string]*neigh) (map[string]string, *regexp.Regexp) {
+	names := map[string]string{}
+	for _, n := range known {
+		if v := a.vrf(n.VRF); v != n.VRF {
+			names[n.VRF] = v
+		}
+		if n.Device != "" {
+			names[n.Device] = a.name("DEV", n.Device)
+		}
+		if n.PeerGroup != "" {
+			names[n.PeerGroup] = a.name("PG", n.PeerGroup)
+		}
+	}
+	if len(names) == 0 {
+		return names, nil
+	}
+	var quoted []string
+	for name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) }) // longest first
+	return names, regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// scrub copies the capture from r to w with the same replacements used for
+// the parsed output. known holds the neighbors parsed from the capture,
+// providing the VRF and device names to replace.
+func (a *anonymizer) scrub(r io.Reader, w io.Writer, known map[string]*neigh) error {
+	names, namePattern := a.knownNames(known)
+
+	bw := bufio.NewWriter(w)
+	err := scanFile(r, func(line string, lineNumber int) error {
+		bw.WriteString(a.scrubLine(line, namePattern, names))
+		bw.WriteByte('\n')
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("anonymizer.scrub: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("anonymizer.scrub: %v", err)
+	}
+	return nil
+}
+
+func (a *anonymizer) scrubLine(line string, namePattern *regexp.Regexp, names map[string]string) string {
+	if strings.HasPrefix(line, " Description: ") {
+		return " Description: " + a.name("DESC", strings.TrimSpace(line[len(" Description: "):]))
+	}
+	line = anonSummaryPattern.ReplaceAllStringFunc(line, func(m string) string {
+		g := anonSummaryPattern.FindStringSubmatch(m)
+		return g[1] + a.asn(g[2]) + g[3]
+	})
+	line = anonASNPattern.ReplaceAllStringFunc(line, func(m string) string {
+		g := anonASNPattern.FindStringSubmatch(m)
+		return g[1] + a.asn(g[2])
+	})
+	line = anonIPv4Pattern.ReplaceAllStringFunc(line, func(m string) string {
+		if net.ParseIP(m) == nil {
+			return m
+		}
+		return a.addr(m)
+	})
+	line = anonIPv6Pattern.ReplaceAllStringFunc(line, func(m string) string {
+		if !strings.Contains(m, "::") && strings.Count(m, ":") != 7 {
+			return m // e.g. 00:42:17
+		}
+		if net.ParseIP(m) == nil {
+			return m
+		}
+		return a.addr(m)
+	})
+	if namePattern != nil {
+		line = namePattern.ReplaceAllStringFunc(line, func(m string) string { return names[m] })
+	}
+	return line
+}
+
+// parseScrubbed parses the capture files, or stdin if there are none, and
+// writes a scrubbed copy of each one to dest: a file for a single capture,
+// else a directory holding copies named after the anonymized file names.
+func parseScrubbed(files []string, opts parseOptions, a *anonymizer, dest string) (map[string]*neigh, error) {
+	if len(files) == 0 {
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		table, _, err := parseInput(bytes.NewReader(raw), "stdin", opts)
+		if err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		if err := scrubFile(a, bytes.NewReader(raw), dest, table); err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		return table, nil
+	}
+
+	table := map[string]*neigh{}
+	for _, path := range files {
+		fileOpts := opts
+		fileOpts.device = deviceFromPath(path)
+		t, _, err := parseFile(path, fileOpts)
+		if err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		out := dest
+		if len(files) > 1 {
+			out = filepath.Join(dest, a.name("DEV", deviceFromPath(path))+filepath.Ext(path))
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		err = scrubFile(a, f, out, t)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parseScrubbed: %v", err)
+		}
+		for k, n := range t {
+			table[k] = n
+		}
+	}
+	return table, nil
+}
+
+func scrubFile(a *anonymizer, r io.Reader, path string, known map[string]*neigh) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("scrubFile: %v", err)
+	}
+	if err := a.scrub(r, f, known); err != nil {
+		f.Close()
+		return fmt.Errorf("scrubFile: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("scrubFile: %v", err)
+	}
+	infof("scrubFile: wrote %s", path)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizerStable(t *testing.T) {
+	a, b, other := newAnonymizer("s3cret"), newAnonymizer("s3cret"), newAnonymizer("other")
+	cases := []struct {
+		kind, value string
+		check       func(r string) bool
+	}{
+		{"addr", "198.51.100.1", func(r string) bool { return strings.HasPrefix(r, "10.") && net.ParseIP(r).To4() != nil }},
+		{"addr", "2001:db8:cafe::1", func(r string) bool { _, n, _ := net.ParseCIDR("2001:db8::/32"); return n.Contains(net.ParseIP(r)) }},
+		{"addr", "pe1-peer", func(r string) bool { return strings.HasPrefix(r, "PEER-") }},
+		{"asn", "65001", func(r string) bool {
+			v, err := strconv.ParseUint(r, 10, 32)
+			return err == nil && v >= 4200000000 && v < 4294967295
+		}},
+		{"asn", "1.10", func(r string) bool { return strings.HasPrefix(r, "42") }},
+		{"vrf", "CUST-A", func(r string) bool { return strings.HasPrefix(r, "VRF-") && len(r) == len("VRF-")+6 }},
+		{"rd", "192.0.2.1:100", func(r string) bool { return strings.HasPrefix(r, "10.") && strings.HasSuffix(r, ":100") }},
+		{"prefix", "198.51.100.0/24", func(r string) bool { return strings.HasPrefix(r, "10.") && strings.HasSuffix(r, ".0/24") }},
+	}
+	apply := func(an *anonymizer, kind, value string) string {
+		switch kind {
+		case "addr":
+			return an.addr(value)
+		case "asn":
+			return an.asn(value)
+		case "vrf":
+			return an.vrf(value)
+		case "rd":
+			return an.rd(value)
+		}
+		return an.prefix(value)
+	}
+	for _, c := range cases {
+		r := apply(a, c.kind, c.value)
+		if !c.check(r) {
+			t.Errorf("%s %s: bad replacement %s", c.kind, c.value, r)
+		}
+		if again := apply(a, c.kind, c.value); again != r {
+			t.Errorf("%s %s: %s, then %s", c.kind, c.value, r, again)
+		}
+		if same := apply(b, c.kind, c.value); same != r {
+			t.Errorf("%s %s: %s with the same key, want %s", c.kind, c.value, same, r)
+		}
+		if diff := apply(other, c.kind, c.value); diff == r {
+			t.Errorf("%s %s: %s with another key too", c.kind, c.value, r)
+		}
+	}
+
+	// values carrying no identity are kept
+	for _, v := range []string{"", "0.0.0.0", "::"} {
+		if r := a.addr(v); r != v {
+			t.Errorf("addr(%q) = %q", v, r)
+		}
+	}
+	for _, v := range []string{"", "?"} {
+		if r := a.asn(v); r != v {
+			t.Errorf("asn(%q) = %q", v, r)
+		}
+	}
+	if r := a.rd("65000:101"); r != "65000:101" {
+		t.Errorf("rd(65000:101) = %q", r)
+	}
+	for _, v := range []string{vrfDefault, vrfGlobalVPN} {
+		if r := a.vrf(v); r != v {
+			t.Errorf("vrf(%q) = %q", v, r)
+		}
+	}
+
+	// without a key, runs are not linkable
+	if newAnonymizer("").addr("198.51.100.1") == newAnonymizer("").addr("198.51.100.1") {
+		t.Error("random keys give the same replacement")
+	}
+}
+
+// TestAnonymizerCollisions maps enough names to collide on their 24-bit
+// hashes.
+func TestAnonymizerCollisions(t *testing.T) {
+	a := newAnonymizer("s3cret")
+	seen := map[string]string{}
+	for i := 0; i < 70000; i++ {
+		v := "198.51." + strconv.Itoa(i/256%256) + "." + strconv.Itoa(i%256) + "-" + strconv.Itoa(i)
+		r := a.name("DESC", v)
+		if prev, dup := seen[r]; dup {
+			t.Fatalf("%s and %s both map to %s", prev, v, r)
+		}
+		seen[r] = v
+	}
+}
+
+// TestAnonymizeCapture checks that the scrubbed copy of a capture parses
+// to the anonymized table of the original.
+func TestAnonymizeCapture(t *testing.T) {
+	for _, name := range []string{"iosxr-bgp-vrf-all-neighbors.txt", "ios-vpnv4-neighbors.txt", "ios-ipv6-neighbors.txt"} {
+		path := filepath.Join("testdata", name)
+		table, _, err := parseFile(path, parseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := newAnonymizer("s3cret")
+		anon := a.table(table)
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scrubbed bytes.Buffer
+		err = newAnonymizer("s3cret").scrub(f, &scrubbed, table)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range table {
+			if strings.Contains(scrubbed.String(), n.Addr) {
+				t.Errorf("%s: scrubbed copy still holds %s", name, n.Addr)
+			}
+		}
+
+		reparsed, _, err := parseInput(bytes.NewReader(scrubbed.Bytes()), name, parseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := json.MarshalIndent(sortedList(anon), "", " ")
+		got, _ := json.MarshalIndent(sortedList(reparsed), "", " ")
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: scrubbed capture parses to:\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+}
+
+func sortedList(table map[string]*neigh) []*neigh {
+	list := neighborList(table)
+	sortNeighbors(list, nil)
+	return list
+}
@@ -0,0 +1,68 @@
+package main
+
+// TCP authentication of the BGP session, for security audits:
+//
+//	Option Flags: nagle, path mtu capable, md5                  (IOS, IOS-XE)
+//	Option Flags: VRF id set, nagle, path mtu capable, TCP-AO
+//	  TCP MD5 authentication is enabled                         (NX-OS)
+//	  TCP-AO keychain: BGP-KEYS, active key 3                   (IOS-XR)
+//
+// Neighbors without an active TCP connection print no option flags: their
+// authentication is unknown.
+
+import "strings"
+
+const (
+	authMD5  = "md5"
+	authAO   = "tcp-ao"
+	authNone = "none"
+)
+
+// parseOptionFlag records a TCP option flag.
+func (tcp *tcpSession) parseOptionFlag(flag string) {
+	tcp.options = true
+	switch strings.ToLower(strings.TrimSpace(flag)) {
+	case "md5":
+		tcp.MD5 = true
+	case "ao", "tcp-ao":
+		tcp.AO = true
+	}
+}
+
+// parseAuthLine records the authentication lines printed outside the
+// option flags. It returns false for any other line.
+func parseAuthLine(n *neigh, line string) bool {
+	if !strings.Contains(line, "TCP") {
+		return false
+	}
+	s := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(s, "TCP MD5 authentication is "):
+		if strings.HasSuffix(s, " enabled") || strings.HasSuffix(s, " set") {
+			n.tcpSession().MD5 = true
+		}
+	case strings.HasPrefix(s, "TCP-AO") || strings.HasPrefix(s, "TCP Authentication Option"):
+		if !strings.Contains(s, "disabled") && !strings.Contains(s, " not ") {
+			n.tcpSession().AO = true
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// authValue returns the session authentication: tcp-ao, md5, none when the
+// TCP option flags show neither, or "" when unknown.
+func authValue(n *neigh) string {
+	switch {
+	case n.TCP == nil:
+		return ""
+	case n.TCP.AO:
+		return authAO
+	case n.TCP.MD5:
+		return authMD5
+	case n.TCP.options:
+		return authNone
+	}
+	return ""
+}
@@ -0,0 +1,228 @@
+package main
+
+// captures read from RANCID or Oxidized backup trees:
+// each device file holds the saved config plus the output of extra show
+// commands, usually as comments:
+//
+//	!show bgp vpnv4 unicast all neighbors          (RANCID)
+//	! BGP neighbor is 10.0.0.1,  remote AS 65001, external link   (Oxidized)
+//
+// The neighbors or summary command output is cut out of each file and parsed
+// as a capture of the device named by the file. Bare git repositories
+// (Oxidized git output) are read at HEAD with the git client.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type backupFile struct {
+	name string // path inside the backup tree
+	read func() ([]byte, error)
+}
+
+// parseBackupDir parses the neighbors section of every device file under dir.
+// devices, if not empty, are globs selecting the devices by name.
+func parseBackupDir(dir string, devices []string, opts parseOptions) (map[string]*neigh, error) {
+	files, err := backupFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("parseBackupDir: %v", err)
+	}
+
+	table := map[string]*neigh{}
+	var parsed, skipped int
+	for _, bf := range files {
+		device := deviceFromPath(bf.name)
+		if !backupDeviceSelected(device, devices) {
+			continue
+		}
+		data, err := bf.read()
+		if err != nil {
+			return nil, fmt.Errorf("parseBackupDir: %v", err)
+		}
+		section, found := backupSection(data)
+		if !found {
+			debugf("parseBackupDir: %s: no bgp neighbors section", bf.name)
+			skipped++
+			continue
+		}
+		fileOpts := opts
+		fileOpts.device = device
+		t, _, err := parseInput(bytes.NewReader(section), bf.name, fileOpts)
+		if err != nil {
+			return nil, fmt.Errorf("parseBackupDir: %v", err)
+		}
+		for k, n := range t {
+			table[k] = n
+		}
+		parsed++
+	}
+
+	infof("parseBackupDir: %s: %d devices with bgp neighbors, %d files without", dir, parsed, skipped)
+
+	return table, nil
+}
+
+func backupDeviceSelected(device string, globs []string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, g := range globs {
+		if ok, _ := path.Match(g, device); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// backupFiles lists the candidate device files: regular files in a
+// directory tree, or the files at HEAD of a bare git repository.
+func backupFiles(dir string) ([]backupFile, error) {
+	if isBareGitRepo(dir) {
+		return gitBackupFiles(dir)
+	}
+
+	var files []backupFile
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if p != dir && (strings.HasPrefix(name, ".") || name == "CVS") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") || isRancidListFile(name) {
+			return nil
+		}
+		files = append(files, backupFile{name: p, read: func() ([]byte, error) { return os.ReadFile(p) }})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backupFiles: %v", err)
+	}
+	return files, nil
+}
+
+// isRancidListFile reports the RANCID group files next to configs/.
+func isRancidListFile(name string) bool {
+	return name == "router.db" || strings.HasPrefix(name, "routers.")
+}
+
+func isBareGitRepo(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func gitBackupFiles(dir string) ([]backupFile, error) {
+	out, err := exec.Command("git", "--git-dir", dir, "ls-tree", "-r", "--name-only", "HEAD").Output()
+	if err != nil {
+		return nil, fmt.Errorf("gitBackupFiles: git ls-tree: %s: %v", dir, err)
+	}
+	var files []backupFile
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name == "" {
+			continue
+		}
+		name := name
+		files = append(files, backupFile{name: name, read: func() ([]byte, error) {
+			data, err := exec.Command("git", "--git-dir", dir, "show", "HEAD:"+name).Output()
+			if err != nil {
+				return nil, fmt.Errorf("gitBackupFiles: git show: %s: %v", name, err)
+			}
+			return data, nil
+		}})
+	}
+	return files, nil
+}
+
+// backupSection returns the lines of the bgp neighbors and summary command
+// output found in a backup file, with the comment prefix removed.
+// A commented section ends at the first line without the prefix, a plain
+// one at a config comment or the next unrelated command.
+func backupSection(data []byte) ([]byte, bool) {
+	var out bytes.Buffer
+	var prefix string
+	inside, found := false, false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if inside {
+			text, ok := line, true
+			if prefix != "" {
+				text, ok = backupUncomment(line, prefix)
+			} else if strings.HasPrefix(line, "!") || isOtherCommand(line) {
+				ok = false
+			}
+			if ok && (!isOtherCommand(text) || isNeighborsSectionStart(text)) {
+				out.WriteString(text)
+				out.WriteByte('\n')
+				continue
+			}
+			inside = false
+		}
+
+		for _, p := range []string{"! ", "!", ""} {
+			text, ok := backupUncomment(line, p)
+			if ok && isNeighborsSectionStart(text) {
+				inside, found, prefix = true, true, p
+				out.WriteString(text)
+				out.WriteByte('\n')
+				break
+			}
+		}
+	}
+
+	return out.Bytes(), found
+}
+
+func backupUncomment(line, prefix string) (string, bool) {
+	if prefix == "" {
+		return line, true
+	}
+	if line == strings.TrimSpace(prefix) {
+		return "", true // blank output line
+	}
+	if !strings.HasPrefix(line, prefix) {
+		return "", false
+	}
+	return line[len(prefix):], true
+}
+
+// isNeighborsSectionStart matches the neighbors or summary command echo,
+// or the output itself when the backup keeps no echo.
+func isNeighborsSectionStart(line string) bool {
+	if strings.HasPrefix(line, "BGP neighbor is ") || isSummaryHeader(line) {
+		return true
+	}
+	lower := strings.ToLower(line)
+	i := strings.Index(lower, "show ")
+	if i < 0 || !(i == 0 || strings.ContainsAny(lower[i-1:i], "#> ")) {
+		return false
+	}
+	cmd := lower[i:]
+	if strings.Contains(cmd, "routes") {
+		return false // e.g. neighbors X advertised-routes
+	}
+	return strings.Contains(cmd, " bgp ") && (strings.Contains(cmd, " neighbors") || strings.HasSuffix(cmd, " summary"))
+}
+
+// isOtherCommand matches a command echo, e.g. "pe1#show version" or "show version".
+func isOtherCommand(line string) bool {
+	return promptDevice(line) != "" || strings.HasPrefix(strings.ToLower(line), "show ")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+)
+
+// capability is a negotiated capability or address family from the
+// "Neighbor capabilities:" section:
+//
+//	Route refresh: advertised and received(new)
+//	Address family VPNv4 Unicast: advertised and received
+type capability struct {
+	Name       string `json:"name"`
+	Advertised bool   `json:"advertised"`
+	Received   bool   `json:"received"`
+}
+
+func (c capability) negotiated() bool {
+	return c.Advertised && c.Received
+}
+
+func (n *neigh) findCapability(name string) (capability, bool) {
+	for _, c := range n.Capabilities {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return capability{}, false
+}
+
+// hasCapability reports whether the capability was both advertised and received.
+func (n *neigh) hasCapability(name string) bool {
+	c, found := n.findCapability(name)
+	return found && c.negotiated()
+}
+
+// negotiatedFamilies lists address families both advertised and received.
+func (n *neigh) negotiatedFamilies() []string {
+	var list []string
+	for _, af := range n.AddressFamilies {
+		if af.negotiated() {
+			list = append(list, af.Name)
+		}
+	}
+	return list
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// parseCapabilityLine parses one line inside the "Neighbor capabilities:" section.
+// Nested detail lines are ignored, except the graceful restart timer.
+func parseCapabilityLine(n *neigh, line string) {
+	if lineIndent(line) > 4 {
+		parseGRCapabilityLine(n, line)
+		return
+	}
+	if lineIndent(line) != 4 {
+		return
+	}
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return
+	}
+	name := strings.TrimSpace(line[:i])
+	status := line[i+1:]
+	c := capability{
+		Advertised: strings.Contains(status, "advertised"),
+		Received:   strings.Contains(status, "received"),
+	}
+	if !c.Advertised && !c.Received {
+		return // e.g. "Multisession Capability:" not negotiated
+	}
+	if strings.HasPrefix(name, "Address family ") {
+		c.Name = strings.TrimPrefix(name, "Address family ")
+		n.AddressFamilies = append(n.AddressFamilies, c)
+		return
+	}
+	c.Name = strings.TrimSuffix(name, " Capability")
+	n.Capabilities = append(n.Capabilities, c)
+}
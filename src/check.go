@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// exit codes
+const (
+	exitOK          = 0
+	exitError       = 1 // fatalf
+	exitCheckFailed = 2 // check found neighbors not Established, or none
+	exitLeak        = 3 // check -leak found prefix jumps
+)
+
+// runCheck prints a summary of neighbors not in Established state
+// and returns the process exit code. An empty list fails, as from a
+// truncated or unparseable capture, unless allowEmpty.
+func runCheck(w io.Writer, list []*neigh, allowEmpty bool) int {
+	if len(list) == 0 && !allowEmpty {
+		fmt.Fprintln(w, "FAIL: no neighbors found (truncated or unparseable capture? see -allow-empty)")
+		return exitCheckFailed
+	}
+
+	var offenders []*neigh
+	for _, n := range list {
+		if n.State != "Established" {
+			offenders = append(offenders, n)
+		}
+	}
+
+	if len(offenders) == 0 {
+		fmt.Fprintf(w, "OK: %d neighbors, all Established\n", len(list))
+		return exitOK
+	}
+
+	fmt.Fprintf(w, "FAIL: %d of %d neighbors not Established\n", len(offenders), len(list))
+	for _, n := range offenders {
+		fmt.Fprintf(w, "  %-15s %-14s %6s %-11s %s\n", n.Addr, n.VRF, n.RemoteAS, n.State, n.Uptime)
+	}
+
+	return exitCheckFailed
+}
@@ -0,0 +1,55 @@
+package main
+
+// cleanup of terminal artifacts found in captures taken without
+// "terminal length 0": --More-- prompts, backspaces, ANSI escapes, CRs.
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[A-Za-z]|[()][A-Za-z0-9]|[=>])`)
+	moreMarker = regexp.MustCompile(`\s*(--More--|<--- More --->)\s?`)
+)
+
+// cleanLine strips terminal artifacts from a capture line.
+func cleanLine(line string) string {
+	if !strings.ContainsAny(line, "\x1b\b\r-<") {
+		return line // fast path
+	}
+
+	if strings.IndexByte(line, '\x1b') >= 0 {
+		line = ansiEscape.ReplaceAllString(line, "")
+	}
+	line = applyBackspaces(line)
+	if strings.Contains(line, "More") {
+		line = moreMarker.ReplaceAllString(line, "")
+	}
+
+	// a carriage return moves back to column 0; keep what was written last
+	line = strings.TrimRight(line, "\r")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+
+	return line
+}
+
+// applyBackspaces erases the character before each backspace, as a terminal would.
+func applyBackspaces(line string) string {
+	if strings.IndexByte(line, '\b') < 0 {
+		return line
+	}
+	buf := make([]rune, 0, len(line))
+	for _, c := range line {
+		if c == '\b' {
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+			continue
+		}
+		buf = append(buf, c)
+	}
+	return string(buf)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// collectFunc retrieves a fresh neighbor table, e.g. from a router.
+type collectFunc func() (map[string]*neigh, error)
+
+// collectHook is called after each collection with the previous table
+// (nil on the first collection) and the new one, e.g. for alerts.
+type collectHook func(prev, curr map[string]*neigh)
+
+// commandCollect runs command through the shell and parses its output.
+// Example: ssh pe1 'show bgp vpnv4 unicast all neighbors'
+func commandCollect(command string, opts parseOptions) (map[string]*neigh, error) {
+	cmd := shellCommand(command)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("commandCollect: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("commandCollect: %v", err)
+	}
+
+	table, _, parseErr := parseInput(out, "command", opts)
+	if parseErr != nil {
+		io.Copy(io.Discard, out) // let the command finish
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("commandCollect: %s: %v", command, err)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("commandCollect: %s: %v", command, parseErr)
+	}
+
+	return table, nil
+}
+
+// shellCommand prepares command to run through the platform shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,99 @@
+package main
+
+// ANSI colors for the text table: broken sessions stand out in long tables.
+// -color auto colors only when stdout is a terminal and NO_COLOR is unset.
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// colorOutput enables colors in table rows, see setupColor.
+var colorOutput bool
+
+func setupColor(mode string) error {
+	switch mode {
+	case "never":
+		colorOutput = false
+	case "always":
+		colorOutput = true
+	case "auto":
+		colorOutput = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	default:
+		return fmt.Errorf("setupColor: unknown color mode: [%s] (available: never,auto,always)", mode)
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func stateColor(n *neigh) string {
+	switch {
+	case n.State == "Established":
+		return ansiGreen
+	case strings.HasPrefix(n.State, "Idle"), n.State == "Active", n.State == "Connect":
+		return ansiRed
+	}
+	return ansiYellow // OpenSent, OpenConfirm, unknown
+}
+
+func prefixesColor(n *neigh) string {
+	if n.Prefixes == 0 {
+		return ansiYellow
+	}
+	return ""
+}
+
+func maxPrefixColor(n *neigh) string {
+	usage, ok := n.maxPrefixUsage()
+	switch {
+	case !ok:
+		return ""
+	case usage >= 90:
+		return ansiRed
+	case usage >= 75:
+		return ansiYellow
+	}
+	return ""
+}
+
+func grColor(n *neigh) string {
+	if n.GracefulRestart != nil && n.GracefulRestart.InRestart {
+		return ansiYellow
+	}
+	return ""
+}
+
+// formatCell pads value to the column width, coloring the value but not the padding.
+func (c *column) formatCell(n *neigh) string {
+	s := c.value(n)
+	f := c.format(s)
+	if !colorOutput || c.color == nil || s == "" {
+		return f
+	}
+	color := c.color(n)
+	if color == "" {
+		return f
+	}
+	i := strings.Index(f, s)
+	return f[:i] + color + s + ansiReset + f[i+len(s):]
+}
+
+// neighRow formats the table row for neighbor n.
+func neighRow(cols []*column, n *neigh) string {
+	fields := make([]string, len(cols))
+	for i, c := range cols {
+		fields[i] = c.formatCell(n)
+	}
+	return strings.TrimRight(strings.Join(fields, " "), " ")
+}
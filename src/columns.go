@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+type column struct {
+	name   string
+	header string
+	width  int  // minimum width
+	right  bool // right-aligned
+	value  func(n *neigh) string
+	color  func(n *neigh) string // ANSI color for the value, or ""
+}
+
+var columns = []*column{
+	{name: "device", header: "Device", width: 12, value: func(n *neigh) string { return n.Device }},
+	{name: "addr", header: "Neighbor", width: 15, value: func(n *neigh) string { return n.Addr }},
+	{name: "vrf", header: "VRF", width: 14, value: func(n *neigh) string { return n.VRF }},
+	{name: "asn", header: "ASN", width: 6, right: true, value: func(n *neigh) string { return n.RemoteAS }},
+	{name: "local_as", header: "Local AS", width: 8, right: true, value: func(n *neigh) string { return n.LocalAS }},
+	{name: "link", header: "Link", width: 8, value: func(n *neigh) string { return n.Link }},
+	{name: "router_id", header: "Router ID", width: 15, value: func(n *neigh) string { return n.RouterID }},
+	{name: "local_router_id", header: "Local ID", width: 15, value: func(n *neigh) string { return n.LocalRouterID }},
+	{name: "local", header: "Local", width: 21, value: func(n *neigh) string { return endpoint(n.LocalHost, n.LocalPort) }},
+	{name: "foreign", header: "Foreign", width: 21, value: func(n *neigh) string { return endpoint(n.ForeignHost, n.ForeignPort) }},
+	{name: "tcp_state", header: "TCP", width: 6, value: tcpState},
+	{name: "mss", header: "MSS", width: 5, right: true, value: tcpMSS},
+	{name: "auth", header: "Auth", width: 6, value: authValue},
+	{name: "pmtud", header: "PMTUD", width: 5, value: tcpPathMTUDiscovery},
+	{name: "retransmit", header: "Retrans", width: 7, right: true, value: tcpRetransmit},
+	{name: "state", header: "State", width: 11, value: func(n *neigh) string { return n.State }, color: stateColor},
+	{name: "uptime", header: "Uptime", width: 6, value: func(n *neigh) string { return n.Uptime }},
+	{name: "uptime_seconds", header: "Seconds", width: 9, right: true, value: uptimeSeconds},
+	{name: "prefixes", header: "Prefixes", width: 8, right: true, value: func(n *neigh) string { return strconv.Itoa(n.Prefixes) }, color: prefixesColor},
+	{name: "description", header: "Description", width: 24, value: func(n *neigh) string { return n.Description }},
+	{name: "peer_group", header: "Peer group", width: 14, value: func(n *neigh) string { return n.PeerGroup }},
+	{name: "dynamic", header: "Dynamic", width: 7, value: func(n *neigh) string { return yesNo(n.Dynamic) }},
+	{name: "shutdown", header: "Shut", width: 4, value: func(n *neigh) string { return yesNo(n.Shutdown) }},
+	{name: "passive", header: "Passive", width: 7, value: func(n *neigh) string { return yesNo(n.Passive) }},
+	{name: "rr_client", header: "RR client", width: 9, value: func(n *neigh) string { return yesNo(n.rrClient()) }},
+	{name: "cluster_id", header: "Cluster ID", width: 15, value: func(n *neigh) string { return n.ClusterID }},
+	{name: "confed", header: "Confed", width: 6, value: func(n *neigh) string { return yesNo(n.Confederation) }},
+	{name: "rd", header: "RD", width: 15, value: func(n *neigh) string { return n.RD }},
+	{name: "import_rt", header: "Import RT", width: 20, value: func(n *neigh) string { return strings.Join(n.ImportRT, " ") }},
+	{name: "export_rt", header: "Export RT", width: 20, value: func(n *neigh) string { return strings.Join(n.ExportRT, " ") }},
+	{name: "listen_range", header: "Listen range", width: 18, value: func(n *neigh) string { return n.ListenRange }},
+	{name: "gr", header: "GR", width: 3, value: func(n *neigh) string { return yesNo(n.hasCapability("Graceful Restart")) }},
+	{name: "gr_restart", header: "GR restart", width: 10, right: true, value: func(n *neigh) string { return grSeconds(n, func(gr *grState) int { return gr.RestartTime }) }},
+	{name: "gr_stalepath", header: "GR stalepath", width: 12, right: true, value: func(n *neigh) string { return grSeconds(n, func(gr *grState) int { return gr.StalepathTime }) }},
+	{name: "gr_remote", header: "GR remote", width: 9, right: true, value: func(n *neigh) string { return grSeconds(n, func(gr *grState) int { return gr.RemoteRestartTime }) }},
+	{name: "gr_state", header: "GR state", width: 10, value: grStatus, color: grColor},
+	{name: "hold_time", header: "Hold", width: 4, right: true, value: func(n *neigh) string { return timersValue(n, func(t *bgpTimers) int { return t.HoldTime }) }},
+	{name: "keepalive", header: "Keepalive", width: 9, right: true, value: func(n *neigh) string { return timersValue(n, func(t *bgpTimers) int { return t.Keepalive }) }},
+	{name: "conn_established", header: "Estab", width: 5, right: true, value: func(n *neigh) string { return connValue(n, func(c *connStats) int { return c.Established }) }},
+	{name: "conn_dropped", header: "Dropped", width: 7, right: true, value: func(n *neigh) string { return connValue(n, func(c *connStats) int { return c.Dropped }) }},
+	{name: "drop_ratio", header: "Drop ratio", width: 10, right: true, value: dropRatioValue},
+	{name: "bfd", header: "BFD", width: 3, value: func(n *neigh) string { return yesNo(n.BFD) }},
+	{name: "bfd_mode", header: "BFD mode", width: 10, value: func(n *neigh) string { return n.BFDMode }},
+	{name: "afs", header: "Address families", width: 16, value: func(n *neigh) string { return strings.Join(n.negotiatedFamilies(), ",") }},
+	{name: "afi", header: "AFI", width: 12, value: func(n *neigh) string { return strings.Join(n.afis(), ",") }},
+	{name: "pfx_ipv4", header: "IPv4", width: 8, right: true, value: afiPrefixes("ipv4")},
+	{name: "pfx_ipv6", header: "IPv6", width: 8, right: true, value: afiPrefixes("ipv6")},
+	{name: "pfx_vpnv4", header: "VPNv4", width: 8, right: true, value: afiPrefixes("vpnv4")},
+	{name: "pfx_vpnv6", header: "VPNv6", width: 8, right: true, value: afiPrefixes("vpnv6")},
+	{name: "pfx_evpn", header: "EVPN", width: 8, right: true, value: afiPrefixes("l2vpn-evpn")},
+	{name: "route_map_in", header: "Route map in", width: 14, value: func(n *neigh) string { in, _ := n.routeMaps(); return strings.Join(in, ",") }},
+	{name: "route_map_out", header: "Route map out", width: 14, value: func(n *neigh) string { _, out := n.routeMaps(); return strings.Join(out, ",") }},
+	{name: "max_prefix", header: "Max prefix", width: 10, right: true, value: maxPrefixLimits},
+	{name: "max_prefix_pct", header: "Max pfx %", width: 9, right: true, value: maxPrefixPct, color: maxPrefixColor},
+	{name: "msg_rcvd", header: "MsgRcvd", width: 8, right: true, value: func(n *neigh) string { return strconv.Itoa(n.MsgRcvd) }},
+	{name: "msg_sent", header: "MsgSent", width: 8, right: true, value: func(n *neigh) string { return strconv.Itoa(n.MsgSent) }},
+	{name: "in_q", header: "InQ", width: 4, right: true, value: func(n *neigh) string { return strconv.Itoa(n.InQ) }},
+	{name: "out_q", header: "OutQ", width: 4, right: true, value: func(n *neigh) string { return strconv.Itoa(n.OutQ) }},
+	{name: "notif_sent", header: "NotifSent", width: 9, right: true, value: func(n *neigh) string { return messageCount(n, func(m *msgStats) int { return m.Notifications.Sent }) }},
+	{name: "notif_rcvd", header: "NotifRcvd", width: 9, right: true, value: func(n *neigh) string { return messageCount(n, func(m *msgStats) int { return m.Notifications.Rcvd }) }},
+	{name: "reset", header: "Last reset", width: 10, value: func(n *neigh) string { return n.LastReset }},
+	{name: "notification", header: "Last notification", width: 34, value: notificationValue},
+	{name: "notif_code", header: "Code", width: 5, value: notificationCodeValue},
+	{name: "reset_reason", header: "Reset reason", width: 24, value: func(n *neigh) string { return n.ResetReason }},
+}
+
+const (
+	defaultColumns    = "addr,vrf,asn,state,uptime,prefixes"
+	defaultCSVColumns = "addr,vrf,asn,state,uptime,uptime_seconds,prefixes"
+)
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// endpoint formats host and port, e.g. 10.0.0.1:179 or [2001:db8::1]:179.
+func endpoint(host string, port int) string {
+	if host == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func messageCount(n *neigh, get func(m *msgStats) int) string {
+	if n.Messages == nil {
+		return ""
+	}
+	return strconv.Itoa(get(n.Messages))
+}
+
+func uptimeSeconds(n *neigh) string {
+	if n.UptimeSeconds == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n.UptimeSeconds, 10)
+}
+
+func findColumn(name string) *column {
+	for _, c := range columns {
+		if c.name == name {
+			return c
+		}
+	}
+	return extraColumn(name)
+}
+
+func columnNames() []string {
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// parseColumns parses a comma-separated list of column names.
+func parseColumns(spec string) ([]*column, error) {
+	var list []*column
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c := findColumn(name)
+		if c == nil {
+			return nil, fmt.Errorf("parseColumns: unknown column: [%s] (available: %s)", name, strings.Join(columnNames(), ","))
+		}
+		list = append(list, c)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("parseColumns: no columns selected")
+	}
+	return list, nil
+}
+
+func (c *column) format(s string) string {
+	if c.right {
+		return fmt.Sprintf("%*s", c.width, s)
+	}
+	return fmt.Sprintf("%-*s", c.width, s)
+}
+
+func tableRow(cols []*column, cell func(c *column) string) string {
+	fields := make([]string, len(cols))
+	for i, c := range cols {
+		fields[i] = c.format(cell(c))
+	}
+	return strings.TrimRight(strings.Join(fields, " "), " ")
+}
+
+func writeTableRow(w io.Writer, cols []*column, cell func(c *column) string) {
+	fmt.Fprintln(w, tableRow(cols, cell))
+}
+
+func writeTable(w io.Writer, cols []*column, list []*neigh) {
+	writeTableMarked(w, cols, list, nil)
+}
+
+// writeTableMarked writes the table highlighting rows for which marked returns true.
+func writeTableMarked(w io.Writer, cols []*column, list []*neigh, marked func(n *neigh) bool) {
+	writeTableRow(w, cols, func(c *column) string { return c.header })
+	for _, n := range list {
+		row := neighRow(cols, n)
+		if marked != nil && marked(n) {
+			// cell colors reset all attributes: highlight again after each one
+			row = ansiHighlight + strings.Replace(row, ansiReset, ansiReset+ansiHighlight, -1) + ansiReset
+		}
+		fmt.Fprintln(w, row)
+	}
+}
@@ -0,0 +1,668 @@
+package main
+
+// subcommands:
+// parse   [flags] [FILE...]  parse captures from files or stdin
+// collect [flags]            collect from a device (-cmd, -snmp, -restconf, -gnmi), optionally -watch
+// diff    [flags] OLD NEW    compare two captures
+// serve   [flags] [FILE...]  serve the REST and gRPC APIs
+// check   [flags] [FILE...]  exit with non-zero status if any neighbor is not Established
+// export  [flags] [FILE...]  write a report file or record the table in the history store
+// query   [flags] REPORT     run a history report
+// detail  [flags] ADDR [VRF]  print every parsed field of one neighbor
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type subcommand struct {
+	name string
+	args string // positional arguments synopsis
+	help string
+	run  func(fs *flag.FlagSet, args []string)
+}
+
+var subcommands = []*subcommand{
+	{name: "parse", args: "[FILE...]", help: "parse captures from files or stdin", run: runParse},
+	{name: "collect", help: "collect from a device with -cmd, -snmp, -restconf or -gnmi", run: runCollect},
+	{name: "diff", args: "OLD NEW", help: "compare two captures", run: runDiff},
+	{name: "serve", args: "[FILE...]", help: "serve the neighbor table as a REST and gRPC API", run: runServe},
+	{name: "check", args: "[FILE...]", help: "exit with status 2 if any neighbor is not Established, or not as listed by -expect (-nagios: plugin output and exit codes)", run: runCheckCmd},
+	{name: "export", args: "[FILE...]", help: "write a report file (-o) or record the table in the history store (-store)", run: runExport},
+	{name: "report", args: "REPORT [ARG]", help: "run a report on the history store, e.g. trend -since 30d", run: runReport},
+	{name: "query", args: "REPORT [ARG]", help: "same as report", run: runReport},
+	{name: "detail", args: "ADDR [VRF] [FILE...]", help: "print every parsed field of one neighbor, from capture files, stdin or a device", run: runDetail},
+}
+
+func lookupSubcommand(name string) *subcommand {
+	for _, c := range subcommands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func programName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s COMMAND [flags] [args]\n\ncommands:\n", programName())
+	for _, c := range subcommands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.help)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command, parse is assumed. Run '%s COMMAND -h' for the command flags.\n", programName())
+}
+
+// dispatch runs the subcommand named by args[0].
+// Arguments starting with a flag, or no arguments, run parse.
+func dispatch(args []string) {
+	name := "parse"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage()
+		return
+	}
+	c := lookupSubcommand(name)
+	if c == nil {
+		usage()
+		fatalf("dispatch: unknown command: [%s]", name)
+	}
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [flags] %s\n\n%s\n\nflags:\n", programName(), c.name, c.args, c.help)
+		fs.PrintDefaults()
+	}
+	c.run(fs, args)
+}
+
+// parseFlags parses args and sets up logging.
+func parseFlags(fs *flag.FlagSet, common *commonFlags, args []string) {
+	common.register(fs)
+	if err := fs.Parse(args); err != nil { // ContinueOnError, see runCheckCmd
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		fatalf("parseFlags: %v", err)
+	}
+	if common.config != "" {
+		cfg, err := loadConfig(common.config)
+		if err != nil {
+			fatalf("parseFlags: %v", err)
+		}
+		if err := cfg.applyDefaults(fs); err != nil {
+			fatalf("parseFlags: %s: %v", common.config, err)
+		}
+		inventory = cfg
+	}
+	if err := common.setup(); err != nil {
+		fatalf("parseFlags: %v", err)
+	}
+}
+
+func runParse(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var filt filterFlags
+	var out outputFlags
+	var anon anonFlags
+	input.register(fs)
+	filt.register(fs)
+	out.register(fs)
+	anon.register(fs)
+	stream := fs.Bool("stream", false, "write each neighbor from stdin as soon as it is parsed (unsorted; JSON output becomes one object per line)")
+	scrubbed := fs.String("anonymize-capture", "", "also write a scrubbed copy of the capture to file (a directory for several captures); implies -anonymize")
+	parseFlags(fs, &common, args)
+
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runParse: %v", err)
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fatalf("runParse: %v", err)
+	}
+	cols, err := out.parseColumns(fs.NArg() > 1 || opts.backupDir != "")
+	if err != nil {
+		fatalf("runParse: %v", err)
+	}
+
+	if *scrubbed != "" {
+		if opts.backupDir != "" {
+			fatalf("runParse: -anonymize-capture does not support -backup-dir")
+		}
+		anon.enabled = true
+	}
+	anonymizer := anon.anonymizer()
+
+	if *stream {
+		if fs.NArg() > 0 || opts.backupDir != "" {
+			fatalf("runParse: -stream reads stdin only")
+		}
+		if anonymizer != nil {
+			fatalf("runParse: -stream does not support -anonymize")
+		}
+		if out.tmpl != nil {
+			fatalf("runParse: -stream does not support -template")
+		}
+		if opts.enrich != "" {
+			fatalf("runParse: -stream does not support -enrich")
+		}
+		format := out.formatName()
+		if format != "" && format != "table" && format != "json" && format != "csv" && format != "yaml" {
+			fatalf("runParse: -stream does not support -format %s", format)
+		}
+		if err := streamOutput(os.Stdin, os.Stdout, opts, cols, filter, format == "json", format == "csv", format == "yaml"); err != nil {
+			fatalf("runParse: %v", err)
+		}
+		return
+	}
+
+	var table map[string]*neigh
+	if *scrubbed != "" {
+		table, err = parseScrubbed(fs.Args(), opts, anonymizer, *scrubbed)
+		if err == nil && opts.enrich != "" {
+			err = enrichTable(table, opts.enrich)
+		}
+	} else {
+		collect, _ := inputCollector(fs.Args(), opts)
+		table, err = collect()
+	}
+	if err != nil {
+		fatalf("runParse: %v", err)
+	}
+	table = filterTable(table, filter)
+	if anonymizer != nil {
+		table = anonymizer.table(table)
+	}
+	list := neighborList(table)
+	sortNeighbors(list, keys)
+
+	if err := out.write(os.Stdout, cols, list); err != nil {
+		fatalf("runParse: %v", err)
+	}
+}
+
+func runCollect(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var source sourceFlags
+	var filt filterFlags
+	var out outputFlags
+	var hooks hookFlags
+	var anon anonFlags
+	input.register(fs)
+	source.register(fs)
+	filt.register(fs)
+	out.register(fs)
+	hooks.register(fs)
+	anon.register(fs)
+	watchInterval := fs.Duration("watch", 0, "re-collect every interval (e.g. 30s) and highlight changes")
+	parseFlags(fs, &common, args)
+
+	if fs.NArg() > 0 {
+		fatalf("runCollect: unexpected arguments: %v (use parse for capture files)", fs.Args())
+	}
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runCollect: %v", err)
+	}
+	collect := source.device(opts)
+	if collect == nil {
+		fatalf("runCollect: missing device: use -cmd, -devices, -terminal, -snmp, -restconf or -gnmi")
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fatalf("runCollect: %v", err)
+	}
+	cols, err := out.parseColumns(false)
+	if err != nil {
+		fatalf("runCollect: %v", err)
+	}
+	if err := hooks.setup(); err != nil {
+		fatalf("runCollect: %v", err)
+	}
+
+	anonymizer := anon.anonymizer()
+
+	if *watchInterval > 0 {
+		watch(os.Stdout, *watchInterval, collect, filter, keys, cols, hooks.onCollect)
+		return
+	}
+
+	table, err := collect()
+	if err != nil {
+		fatalf("runCollect: %v", err)
+	}
+	table = filterTable(table, filter)
+	if _, err := hooks.once(table); err != nil {
+		fatalf("runCollect: %v", err)
+	}
+	if anonymizer != nil {
+		table = anonymizer.table(table)
+	}
+
+	list := neighborList(table)
+	sortNeighbors(list, keys)
+
+	if err := out.write(os.Stdout, cols, list); err != nil {
+		fatalf("runCollect: %v", err)
+	}
+}
+
+func runDiff(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var filt filterFlags
+	var anon anonFlags
+	input.register(fs)
+	filt.register(fs)
+	anon.register(fs)
+	threshold := fs.String("threshold", "0", "report prefix count changes beyond this absolute value or percentage (e.g. 10 or 5%)")
+	parseFlags(fs, &common, args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		fatalf("runDiff: expecting two files: OLD NEW")
+	}
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runDiff: %v", err)
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fatalf("runDiff: %v", err)
+	}
+	t, err := parseThreshold(*threshold)
+	if err != nil {
+		fatalf("runDiff: %v", err)
+	}
+	oldTable, _, err := parseFile(fs.Arg(0), opts)
+	if err != nil {
+		fatalf("runDiff: %v", err)
+	}
+	newTable, _, err := parseFile(fs.Arg(1), opts)
+	if err != nil {
+		fatalf("runDiff: %v", err)
+	}
+	oldTable = filterTable(oldTable, filter)
+	newTable = filterTable(newTable, filter)
+	if anonymizer := anon.anonymizer(); anonymizer != nil {
+		oldTable = anonymizer.table(oldTable)
+		newTable = anonymizer.table(newTable)
+	}
+	showDiff(os.Stdout, oldTable, newTable, t, keys)
+}
+
+func runServe(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var source sourceFlags
+	var filt filterFlags
+	var hooks hookFlags
+	input.register(fs)
+	source.register(fs)
+	filt.register(fs)
+	hooks.register(fs)
+	addr := fs.String("addr", ":8080", "REST API listen address (empty to disable)")
+	grpcAddr := fs.String("grpc-addr", "", "gRPC API listen address, e.g. :9090 (see neighbors.proto)")
+	interval := fs.Duration("interval", time.Minute, "re-collect interval")
+	parseFlags(fs, &common, args)
+
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runServe: %v", err)
+	}
+	collect, stdin, err := source.collector(fs.Args(), opts)
+	if err != nil {
+		fatalf("runServe: %v", err)
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fatalf("runServe: %v", err)
+	}
+	if err := hooks.setup(); err != nil {
+		fatalf("runServe: %v", err)
+	}
+	if stdin {
+		*interval = 0 // stdin can be read only once
+	}
+	if err := serve(*addr, *grpcAddr, *interval, collect, filter, keys, hooks.onCollect); err != nil {
+		fatalf("runServe: %v", err)
+	}
+}
+
+func runCheckCmd(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var source sourceFlags
+	var filt filterFlags
+	var hooks hookFlags
+	input.register(fs)
+	source.register(fs)
+	filt.register(fs)
+	hooks.register(fs)
+	expect := fs.String("expect", "", "validate against intent file (YAML, or JSON if *.json) listing the expected neighbors per device and VRF")
+	jsonOutput := fs.Bool("json", false, "write the -expect report as JSON")
+	nagios := fs.Bool("nagios", false, "Nagios/Icinga plugin output: status line with perfdata, exit 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN")
+	allowEmpty := fs.Bool("allow-empty", false, "pass when no neighbors are found (default: fail, as for a truncated or unparseable capture)")
+	leak := fs.String("leak", "", "exit with status 3 on neighbors whose prefixes jumped since the -alert-state collection beyond these thresholds, e.g. 1000,50% (all must be exceeded)")
+	var warning, critical alertRules
+	fs.Var(&warning, "warning", "-nagios WARNING rule, as -alert (repeatable), e.g. 'prefixes < 10'")
+	fs.Var(&critical, "critical", "-nagios CRITICAL rule, as -alert (repeatable; default '"+defaultNagiosCritical+"' without -warning or -critical)")
+	if nagiosArg(args) {
+		// bad flags and config files are UNKNOWN too, not the exit status 2
+		// (CRITICAL) of flag.ExitOnError or 1 (WARNING) of fatalf
+		fs.Init(fs.Name(), flag.ContinueOnError)
+		fatalHandler = nagiosFatal
+	}
+	parseFlags(fs, &common, args)
+	if *nagios {
+		fatalHandler = nagiosFatal // from the -config file
+	}
+
+	fail := func(err error) {
+		if *nagios {
+			nagiosFatal(err)
+		}
+		fatalf("runCheckCmd: %v", err)
+	}
+
+	opts, err := input.parseOptions()
+	if err != nil {
+		fail(err)
+	}
+	var in *intent
+	if *expect != "" {
+		if *nagios {
+			fail(fmt.Errorf("-nagios does not support -expect"))
+		}
+		if in, err = loadIntent(*expect); err != nil {
+			fail(err)
+		}
+	}
+	collect, _, err := source.collector(fs.Args(), opts)
+	if err != nil {
+		fail(err)
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fail(err)
+	}
+	var guard leakGuard
+	if *leak != "" {
+		if hooks.alertState == "" {
+			fail(fmt.Errorf("-leak needs -alert-state for the previous collection"))
+		}
+		if guard, err = parseLeakGuard(*leak); err != nil {
+			fail(err)
+		}
+		hooks.keepPrevious = true
+	}
+	if err := hooks.setup(); err != nil {
+		fail(err)
+	}
+	table, err := collect()
+	if err != nil {
+		fail(err)
+	}
+	table = filterTable(table, filter)
+	prev, err := hooks.once(table)
+	if err != nil {
+		fail(err)
+	}
+
+	if in != nil {
+		report := in.validate(table)
+		code := exitOK
+		if *jsonOutput {
+			if code, err = writeIntentReportJSON(os.Stdout, report); err != nil {
+				fatalf("runCheckCmd: %v", err)
+			}
+		} else {
+			code = writeIntentReport(os.Stdout, report)
+		}
+		os.Exit(code)
+	}
+
+	list := neighborList(table)
+	sortNeighbors(list, keys)
+
+	if *nagios {
+		if len(warning) == 0 && len(critical) == 0 {
+			critical.Set(defaultNagiosCritical)
+		}
+		if guard != nil {
+			critical.Set("prefix_jump > " + *leak)
+		}
+		os.Exit(nagiosCheck(os.Stdout, list, prev, warning, critical, *allowEmpty))
+	}
+
+	code := exitOK
+	if guard != nil {
+		if leaks := findLeaks(prev, table, guard); len(leaks) > 0 {
+			writeLeaks(os.Stdout, leaks, *leak)
+			code = exitLeak
+		}
+	}
+	if c := runCheck(os.Stdout, list, *allowEmpty); code == exitOK {
+		code = c
+	}
+	os.Exit(code)
+}
+
+// exportFormats maps file extensions to export formats.
+var exportFormats = map[string]string{
+	".json": "json",
+	".csv":  "csv",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".html": "html",
+	".htm":  "html",
+	".txt":  "table",
+}
+
+func runExport(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var source sourceFlags
+	var filt filterFlags
+	var anon anonFlags
+	input.register(fs)
+	source.register(fs)
+	filt.register(fs)
+	anon.register(fs)
+	output := fs.String("o", "", "write report to file")
+	format := fs.String("format", "", "report format: "+strings.Join(exporterNames(), ",")+" (default from the -o file extension)")
+	columnSpec := fs.String("columns", "", "CSV and HTML columns: "+strings.Join(columnNames(), ","))
+	storeSpec := fs.String("store", "", storeUsage)
+	parseFlags(fs, &common, args)
+
+	if *output == "" && *storeSpec == "" && *format == "" {
+		fs.Usage()
+		fatalf("runExport: missing -o, -format or -store")
+	}
+	if *output != "" && *format == "" {
+		*format = exportFormats[strings.ToLower(filepath.Ext(*output))]
+		if *format == "" {
+			fatalf("runExport: unknown format for %s: use -format", *output)
+		}
+	}
+	if err := checkExporter(*format); err != nil {
+		fatalf("runExport: %v", err)
+	}
+
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runExport: %v", err)
+	}
+	collect, _, err := source.collector(fs.Args(), opts)
+	if err != nil {
+		fatalf("runExport: %v", err)
+	}
+	filter, keys, err := filt.build()
+	if err != nil {
+		fatalf("runExport: %v", err)
+	}
+	cols, err := columnsFor(*columnSpec, *format == "csv", fs.NArg() > 1 || opts.backupDir != "")
+	if err != nil {
+		fatalf("runExport: %v", err)
+	}
+	var store *historyStore
+	if *storeSpec != "" {
+		if store, err = parseStore(*storeSpec); err != nil {
+			fatalf("runExport: %v", err)
+		}
+	}
+
+	table, err := collect()
+	if err != nil {
+		fatalf("runExport: %v", err)
+	}
+	table = filterTable(table, filter)
+	if anonymizer := anon.anonymizer(); anonymizer != nil {
+		table = anonymizer.table(table)
+	}
+	list := neighborList(table)
+	sortNeighbors(list, keys)
+
+	if store != nil {
+		if err := store.save(table, time.Now()); err != nil {
+			fatalf("runExport: %v", err)
+		}
+	}
+
+	switch {
+	case *output != "":
+		if err := exportFile(*output, *format, cols, list); err != nil {
+			fatalf("runExport: %v", err)
+		}
+		infof("runExport: wrote %d neighbors to %s", len(list), *output)
+	case *format != "":
+		e, err := lookupExporter(*format, os.Stdout, cols)
+		if err == nil {
+			err = e.export(list)
+		}
+		if err != nil {
+			fatalf("runExport: %v", err)
+		}
+		infof("runExport: exported %d neighbors as %s", len(list), *format)
+	}
+}
+
+func exportFile(path, format string, cols []*column, list []*neigh) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("exportFile: %v", err)
+	}
+	e, err := lookupExporter(format, f, cols)
+	if err == nil {
+		err = e.export(list)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("exportFile: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("exportFile: %v", err)
+	}
+	return nil
+}
+
+// runReport runs a report on the history store. The report name may come
+// first, before its flags: report trend -since 30d.
+func runReport(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	storeSpec := fs.String("store", "", "history store: sqlite:history.db or postgres:connstring (trend: also a directory of JSON tables)")
+	since := fs.String("since", "30d", "trend: runs recorded within this age, e.g. 30d, 2w, 12h")
+	by := fs.String("by", "neighbor", "trend: prefix count series per neighbor, vrf or device")
+	stats := fs.Bool("stats", false, "trend: one record of growth statistics per series instead of the points")
+	format := fs.String("format", "csv", "trend: output format, csv or json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s REPORT [flags] [ARG]\n\nreports:\n%s\n  %-8s %s\n\nflags:\n", programName(), fs.Name(), storeReportUsage(),
+			"trend", "prefix count over the runs since -since, per -by neighbor, vrf or device")
+		fs.PrintDefaults()
+	}
+	var report string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		report, args = args[0], args[1:]
+	}
+	parseFlags(fs, &common, args)
+	reportArgs := fs.Args()
+	if report == "" && len(reportArgs) > 0 {
+		report, reportArgs = reportArgs[0], reportArgs[1:] // report -store DB trend
+	}
+
+	if *storeSpec == "" || report == "" {
+		fs.Usage()
+		fatalf("runReport: missing -store or REPORT")
+	}
+	if report == "trend" {
+		if err := runTrend(os.Stdout, *storeSpec, *since, *by, *format, *stats); err != nil {
+			fatalf("runReport: %v", err)
+		}
+		return
+	}
+	store, err := parseStore(*storeSpec)
+	if err != nil {
+		fatalf("runReport: %v", err)
+	}
+	if err := store.report(os.Stdout, report, reportArgs); err != nil {
+		fatalf("runReport: %v", err)
+	}
+}
+
+func runDetail(fs *flag.FlagSet, args []string) {
+	var common commonFlags
+	var input inputFlags
+	var source sourceFlags
+	input.register(fs)
+	source.register(fs)
+	jsonOutput := fs.Bool("json", false, "write JSON output")
+	parseFlags(fs, &common, args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		fatalf("runDetail: missing neighbor address")
+	}
+	addr, vrf, files := fs.Arg(0), "", fs.Args()[1:]
+	if len(files) > 0 {
+		if _, err := os.Stat(files[0]); err != nil {
+			vrf, files = files[0], files[1:] // not a capture file
+		}
+	}
+
+	opts, err := input.parseOptions()
+	if err != nil {
+		fatalf("runDetail: %v", err)
+	}
+	collect, _, err := source.collector(files, opts)
+	if err != nil {
+		fatalf("runDetail: %v", err)
+	}
+	table, err := collect()
+	if err != nil {
+		fatalf("runDetail: %v", err)
+	}
+	list := neighborList(table)
+	sortNeighbors(list, nil)
+	list = selectDetail(list, addr, vrf)
+	if len(list) == 0 {
+		fatalf("runDetail: neighbor not found: %s %s", addr, vrf)
+	}
+
+	if *jsonOutput {
+		if err := writeJSON(os.Stdout, list); err != nil {
+			fatalf("runDetail: %v", err)
+		}
+		return
+	}
+	writeDetail(os.Stdout, list)
+}
@@ -0,0 +1,299 @@
+package main
+
+// -config file (YAML): flag defaults, SSH credentials and the device
+// inventory collected with -devices:
+//
+//	defaults:            # any flag of the subcommand, unless set on the command line
+//	  format: json
+//	  vrf: 'CUST-*'
+//	ssh:
+//	  user: netops
+//	  password_env: BGPN_SSH_PASSWORD          # or password: (keep the file private)
+//	  enable_password_env: BGPN_ENABLE_PASSWORD
+//	  command: show bgp vpnv4 unicast all neighbors
+//	  parallel: 10
+//	devices:
+//	  - name: pe1
+//	    host: 192.0.2.1
+//	  - name: leaf1
+//	    port: 2222
+//	    command: show ip bgp neighbors vrf all
+//	    dialect: eos
+//	  - name: old-pe
+//	    transport: telnet    # or terminal (interactive ssh -tt), default exec
+//
+// Devices override any ssh setting but parallel.
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSSHCommand = "show bgp vpnv4 unicast all neighbors"
+
+type sshSettings struct {
+	user           string
+	password       string
+	enablePassword string
+	identity       string // private key file
+	port           int
+	command        string
+	dialect        string
+	options        []string // extra ssh client arguments
+	transport      string   // exec (command on stdin), terminal (interactive ssh -tt) or telnet
+}
+
+type inventoryDevice struct {
+	name    string
+	host    string
+	ssh     sshSettings
+	session *termSession // kept open between collections, for terminal and telnet
+}
+
+type configFile struct {
+	defaults map[string]string
+	ssh      sshSettings
+	parallel int
+	devices  []*inventoryDevice
+}
+
+// inventory is the configuration loaded by -config; nil without it.
+var inventory *configFile
+
+func loadConfig(filePath string) (*configFile, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig: %v", err)
+	}
+	defer f.Close()
+
+	doc, err := parseYAML(f)
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig: %s: %v", filePath, err)
+	}
+	cfg, err := decodeConfig(doc)
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig: %s: %v", filePath, err)
+	}
+
+	if info, err := f.Stat(); err == nil && info.Mode().Perm()&0077 != 0 && cfg.hasPasswords() {
+		warnf("loadConfig: %s holds passwords but is readable by others: chmod 600 or use password_env", filePath)
+	}
+
+	return cfg, nil
+}
+
+func decodeConfig(doc interface{}) (*configFile, error) {
+	top, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("decodeConfig: expecting mapping with defaults, ssh, devices")
+	}
+	cfg := &configFile{defaults: map[string]string{}, ssh: sshSettings{port: 22, command: defaultSSHCommand, transport: "exec"}, parallel: 10}
+
+	for k, v := range top {
+		switch k {
+		case "defaults":
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("decodeConfig: defaults: expecting mapping of flag names")
+			}
+			for name, value := range fields {
+				cfg.defaults[name] = configString(value)
+			}
+		case "ssh":
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("decodeConfig: ssh: expecting mapping")
+			}
+			if p, found := fields["parallel"]; found {
+				n, err := strconv.Atoi(configString(p))
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("decodeConfig: ssh: bad parallel: [%v]", p)
+				}
+				cfg.parallel = n
+				delete(fields, "parallel")
+			}
+			if err := decodeSSHSettings(&cfg.ssh, fields); err != nil {
+				return nil, fmt.Errorf("decodeConfig: ssh: %v", err)
+			}
+		case "devices":
+			// decoded below, once the ssh settings they override are known
+		default:
+			return nil, fmt.Errorf("decodeConfig: unknown section: [%s]", k)
+		}
+	}
+
+	if v, found := top["devices"]; found {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("decodeConfig: devices: expecting list")
+		}
+		names := map[string]bool{}
+		for i, item := range list {
+			d, err := decodeDevice(item, cfg.ssh)
+			if err != nil {
+				return nil, fmt.Errorf("decodeConfig: device %d: %v", i, err)
+			}
+			if names[d.name] {
+				return nil, fmt.Errorf("decodeConfig: duplicate device: [%s]", d.name)
+			}
+			names[d.name] = true
+			cfg.devices = append(cfg.devices, d)
+		}
+	}
+
+	return cfg, nil
+}
+
+func decodeDevice(item interface{}, base sshSettings) (*inventoryDevice, error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting mapping with name, host")
+	}
+	d := &inventoryDevice{name: configString(fields["name"]), host: configString(fields["host"]), ssh: base}
+	delete(fields, "name")
+	delete(fields, "host")
+	if d.name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	if d.host == "" {
+		d.host = d.name
+	}
+	if err := decodeSSHSettings(&d.ssh, fields); err != nil {
+		return nil, fmt.Errorf("%s: %v", d.name, err)
+	}
+	return d, nil
+}
+
+// decodeSSHSettings overrides s with the given fields.
+// Passwords named by *_env are read from the environment.
+func decodeSSHSettings(s *sshSettings, fields map[string]interface{}) error {
+	for k, v := range fields {
+		value := configString(v)
+		switch k {
+		case "user":
+			s.user = value
+		case "password":
+			s.password = value
+		case "password_env", "enable_password_env":
+			secret, found := os.LookupEnv(value)
+			if !found {
+				return fmt.Errorf("%s: environment variable not set: %s", k, value)
+			}
+			if k == "password_env" {
+				s.password = secret
+			} else {
+				s.enablePassword = secret
+			}
+		case "enable_password":
+			s.enablePassword = value
+		case "identity":
+			s.identity = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("bad port: [%s]", value)
+			}
+			s.port = port
+		case "command":
+			s.command = value
+		case "dialect":
+			if _, err := lookupDialect(value); err != nil {
+				return err
+			}
+			s.dialect = value
+		case "transport":
+			if value != "exec" && value != "terminal" && value != "telnet" {
+				return fmt.Errorf("transport: expecting exec, terminal or telnet: [%s]", value)
+			}
+			s.transport = value
+		case "ssh_options":
+			list, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("ssh_options: expecting list")
+			}
+			s.options = nil
+			for _, o := range list {
+				s.options = append(s.options, configString(o))
+			}
+		default:
+			return fmt.Errorf("unknown setting: [%s]", k)
+		}
+	}
+	return nil
+}
+
+// configString returns a scalar, or a list joined with commas
+// (e.g. "vrf: [CUST-A, CUST-B]" for -vrf CUST-A,CUST-B).
+func configString(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (cfg *configFile) hasPasswords() bool {
+	if cfg.ssh.password != "" || cfg.ssh.enablePassword != "" {
+		return true
+	}
+	for _, d := range cfg.devices {
+		if d.ssh.password != "" || d.ssh.enablePassword != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDefaults sets the flags of fs named in defaults, unless given on
+// the command line. Defaults for flags the subcommand lacks are ignored,
+// so one file serves every subcommand.
+func (cfg *configFile) applyDefaults(fs *flag.FlagSet) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var names []string
+	for name := range cfg.defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if given[name] || name == "config" {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			debugf("applyDefaults: %s: no flag -%s, ignoring", fs.Name(), name)
+			continue
+		}
+		if err := fs.Set(name, cfg.defaults[name]); err != nil {
+			return fmt.Errorf("applyDefaults: -%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// selectDevices returns the inventory devices matching any of the globs.
+func (cfg *configFile) selectDevices(globs []string) []*inventoryDevice {
+	var selected []*inventoryDevice
+	for _, d := range cfg.devices {
+		for _, g := range globs {
+			if ok, _ := path.Match(strings.TrimSpace(g), d.name); ok {
+				selected = append(selected, d)
+				break
+			}
+		}
+	}
+	return selected
+}
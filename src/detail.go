@@ -0,0 +1,103 @@
+package main
+
+// detail subcommand: every parsed field of one neighbor as aligned
+// key/value lines. Nested values are flattened to dotted paths as in the
+// JSON output (tcp.mss, messages.updates.rcvd); list items are named by
+// their first text field (capabilities[Route refresh].received).
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+type detailField struct {
+	key   string
+	value string
+}
+
+// detailFields flattens v, a struct, under prefix.
+func detailFields(prefix string, v reflect.Value) []detailField {
+	var fields []detailField
+	for _, f := range yamlFields(yamlDeref(v)) {
+		key := prefix + f.name
+		fv := yamlDeref(f.value)
+		switch fv.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			fields = append(fields, detailField{key: key, value: "-"}) // nil, e.g. uptime never
+		case reflect.Struct:
+			fields = append(fields, detailFields(key+".", fv)...)
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < fv.Len(); i++ {
+				item := yamlDeref(fv.Index(i))
+				if item.Kind() != reflect.Struct {
+					fields = append(fields, detailField{key: fmt.Sprintf("%s[%d]", key, i), value: fmt.Sprint(item.Interface())})
+					continue
+				}
+				name, nameField := detailItemName(item, i)
+				itemPrefix := fmt.Sprintf("%s[%s].", key, name)
+				for _, itemField := range detailFields(itemPrefix, item) {
+					if itemField.key != itemPrefix+nameField {
+						fields = append(fields, itemField)
+					}
+				}
+			}
+		default:
+			fields = append(fields, detailField{key: key, value: fmt.Sprint(fv.Interface())})
+		}
+	}
+	return fields
+}
+
+// detailItemName returns the first text field of a list item and its
+// name, or the item index.
+func detailItemName(item reflect.Value, i int) (name, field string) {
+	for _, f := range yamlFields(item) {
+		if f.value.Kind() == reflect.String && f.value.Len() > 0 {
+			return f.value.String(), f.name
+		}
+	}
+	return fmt.Sprint(i), ""
+}
+
+// writeDetail writes the neighbors, one block each. Groups of nested
+// fields are set apart by blank lines.
+func writeDetail(w io.Writer, list []*neigh) {
+	for i, n := range list {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fields := detailFields("", reflect.ValueOf(n))
+		width := 0
+		for _, f := range fields {
+			if len(f.key) > width {
+				width = len(f.key)
+			}
+		}
+		var group string
+		for j, f := range fields {
+			g := f.key
+			if k := strings.IndexAny(g, ".["); k >= 0 {
+				g = g[:k]
+			}
+			nested := g != f.key
+			if j > 0 && g != group && (nested || strings.ContainsAny(fields[j-1].key, ".[")) {
+				fmt.Fprintln(w)
+			}
+			group = g
+			fmt.Fprintf(w, "%-*s  %s\n", width, f.key, f.value)
+		}
+	}
+}
+
+// selectDetail returns the neighbors with address addr, in vrf if given.
+func selectDetail(list []*neigh, addr, vrf string) []*neigh {
+	var selected []*neigh
+	for _, n := range list {
+		if n.Addr == addr && (vrf == "" || n.VRF == vrf) {
+			selected = append(selected, n)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,200 @@
+package main
+
+// output dialects.
+// The built-in "ios" dialect is lineParser, the default "auto" dialect
+// picks one from the detected platform (see platform.go). The built-in
+// dialects are added with registerDialect; custom ones are loaded from a
+// JSON file with loadDialectFile (-dialect-file), see README.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dialect turns capture lines into neighbors.
+// parseLine updates scanner.curr, starting a new neighbor with
+// scanner.startNeighbor when a neighbor header is found.
+type dialect interface {
+	parseLine(scanner *neighScanner, line string, lineNum int) error
+}
+
+// dialectFunc adapts a plain function to the dialect interface.
+type dialectFunc func(scanner *neighScanner, line string, lineNum int) error
+
+func (f dialectFunc) parseLine(scanner *neighScanner, line string, lineNum int) error {
+	return f(scanner, line, lineNum)
+}
+
+const dialectDefault = "ios"
+
+var dialects = map[string]dialect{
+	dialectDefault: dialectFunc(lineParser),
+}
+
+// registerDialect makes d available by name, replacing any previous dialect with the same name.
+func registerDialect(name string, d dialect) {
+	dialects[name] = d
+}
+
+func lookupDialect(name string) (dialect, error) {
+	if name == "" {
+		name = dialectAuto
+	}
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("lookupDialect: unknown dialect: %s (known: %s)", name, strings.Join(dialectNames(), ","))
+	}
+	return d, nil
+}
+
+func dialectNames() []string {
+	var names []string
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ruleDialect matches lines against regexp rules and falls back to a base
+// dialect for unmatched lines. Named capture groups are stored into the
+// neighbor field with the same json name; a rule capturing "addr" starts
+// a new neighbor.
+type ruleDialect struct {
+	base  dialect
+	rules []*regexp.Regexp
+}
+
+// dialectSpec is the JSON form of a ruleDialect:
+//
+//	{"name": "ios-patched", "base": "ios", "rules": ["^Peer (?P<addr>\\S+), AS (?P<remote_as>\\d+)"]}
+type dialectSpec struct {
+	Name  string   `json:"name"`
+	Base  string   `json:"base"`
+	Rules []string `json:"rules"`
+}
+
+// ruleFields lists the neighbor fields a rule may capture.
+var ruleFields = []string{"addr", "vrf", "remote_as", "state", "uptime", "prefixes", "description", "peer_group", "last_reset", "reset_reason"}
+
+func newRuleDialect(spec dialectSpec) (*ruleDialect, error) {
+	d := &ruleDialect{}
+	if spec.Base != "none" {
+		base, err := lookupDialect(spec.Base)
+		if err != nil {
+			return nil, fmt.Errorf("newRuleDialect: %s: %v", spec.Name, err)
+		}
+		d.base = base
+	}
+	for _, r := range spec.Rules {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("newRuleDialect: %s: bad rule: %v", spec.Name, err)
+		}
+		for _, group := range re.SubexpNames()[1:] {
+			if group != "" && !isRuleField(group) {
+				return nil, fmt.Errorf("newRuleDialect: %s: unknown field %q in rule [%s] (known: %s)", spec.Name, group, r, strings.Join(ruleFields, ","))
+			}
+		}
+		d.rules = append(d.rules, re)
+	}
+	return d, nil
+}
+
+func isRuleField(name string) bool {
+	for _, f := range ruleFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDialectFile registers the dialect described by a JSON file and returns its name.
+func loadDialectFile(path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("loadDialectFile: %v", err)
+	}
+	var spec dialectSpec
+	if err := json.Unmarshal(buf, &spec); err != nil {
+		return "", fmt.Errorf("loadDialectFile: %s: %v", path, err)
+	}
+	if spec.Name == "" {
+		return "", fmt.Errorf("loadDialectFile: %s: missing dialect name", path)
+	}
+	d, err := newRuleDialect(spec)
+	if err != nil {
+		return "", fmt.Errorf("loadDialectFile: %s: %v", path, err)
+	}
+	registerDialect(spec.Name, d)
+	return spec.Name, nil
+}
+
+func (d *ruleDialect) parseLine(scanner *neighScanner, line string, lineNum int) error {
+	for _, re := range d.rules {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		names := re.SubexpNames()
+		for i, name := range names {
+			if name == "addr" && m[i] != "" {
+				if err := scanner.startNeighbor(m[i]); err != nil {
+					return err
+				}
+			}
+		}
+		if scanner.curr == nil {
+			return fmt.Errorf("ruleDialect: hit rule without neighbor: line=%d [%s]", lineNum, line)
+		}
+		for i, name := range names {
+			if name == "" || name == "addr" {
+				continue
+			}
+			if err := setNeighField(scanner.curr, name, m[i]); err != nil {
+				return fmt.Errorf("ruleDialect: line=%d [%s]: %v", lineNum, line, err)
+			}
+		}
+		return nil
+	}
+	if d.base == nil {
+		return nil
+	}
+	return d.base.parseLine(scanner, line, lineNum)
+}
+
+func setNeighField(n *neigh, field, value string) error {
+	switch field {
+	case "vrf":
+		n.VRF = value
+	case "remote_as":
+		n.RemoteAS = value
+	case "state":
+		n.State = value
+	case "uptime":
+		n.setUptime(value)
+	case "prefixes":
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("setNeighField: bad prefix count: [%s]", value)
+		}
+		n.Prefixes = count
+	case "description":
+		n.Description = value
+	case "peer_group":
+		n.PeerGroup = value
+	case "last_reset":
+		n.LastReset = value
+	case "reset_reason":
+		n.ResetReason = value
+	default:
+		return fmt.Errorf("setNeighField: unknown field: %s", field)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDialectFile(t *testing.T) {
+	const capture = `pe1#show bgp vpnv4 unicast all neighbors
+Peer 198.51.100.1 in vrf CUST-A, AS 65001
+  BGP state = Established, up for 5w2d
+Peer 198.51.100.2 in vrf CUST-B, AS 65002
+  BGP state = Idle
+`
+	cases := []struct {
+		spec string
+		want map[string]string // table key: remote_as/state/uptime
+		fail bool
+	}{
+		{
+			spec: `{"name": "test-patched", "base": "ios", "rules": ["^Peer (?P<addr>\\S+) in vrf (?P<vrf>\\S+), AS (?P<remote_as>\\d+)"]}`,
+			want: map[string]string{
+				"pe1/198.51.100.1:CUST-A": "65001/Established/5w2d",
+				"pe1/198.51.100.2:CUST-B": "65002/Idle/?",
+			},
+		},
+		{
+			spec: `{"name": "test-none", "base": "none", "rules": ["^Peer (?P<addr>\\S+) in vrf (?P<vrf>\\S+), AS (?P<remote_as>\\d+)"]}`,
+			want: map[string]string{
+				"pe1/198.51.100.1:CUST-A": "65001//",
+				"pe1/198.51.100.2:CUST-B": "65002//",
+			},
+		},
+		{spec: `{"base": "ios", "rules": []}`, fail: true},
+		{spec: `{"name": "test-bad", "base": "ios", "rules": ["^Peer (?P<peer>\\S+)"]}`, fail: true},
+		{spec: `{"name": "test-bad", "base": "junos", "rules": []}`, fail: true},
+		{spec: `{"name": "test-bad", "base": "ios", "rules": ["^Peer ("]}`, fail: true},
+	}
+	for i, c := range cases {
+		path := filepath.Join(t.TempDir(), "dialect.json")
+		if err := os.WriteFile(path, []byte(c.spec), 0644); err != nil {
+			t.Fatal(err)
+		}
+		name, err := loadDialectFile(path)
+		if c.fail {
+			if err == nil {
+				t.Errorf("case %d: want error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+			continue
+		}
+		d, err := lookupDialect(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, _, err := parseInput(strings.NewReader(capture), "test", parseOptions{dialect: d, dialectName: name})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		got := map[string]string{}
+		for k, n := range table {
+			got[k] = n.RemoteAS + "/" + n.State + "/" + n.Uptime
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", name, got, c.want)
+			continue
+		}
+		for k, v := range c.want {
+			if got[k] != v {
+				t.Errorf("%s: %s: got %q, want %q", name, k, got[k], v)
+			}
+		}
+		delete(dialects, name)
+	}
+}
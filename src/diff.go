@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// threshold is an absolute or relative (percent) limit for prefix count changes.
+type threshold struct {
+	value   float64
+	percent bool
+}
+
+// parseThreshold parses "10" (absolute) or "5%" (relative to old count).
+func parseThreshold(s string) (threshold, error) {
+	var t threshold
+	if strings.HasSuffix(s, "%") {
+		t.percent = true
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return t, fmt.Errorf("parseThreshold: bad threshold: [%s]", s)
+	}
+	t.value = v
+	return t, nil
+}
+
+// exceeded reports whether a change from old to new count goes beyond the threshold.
+func (t threshold) exceeded(oldCount, newCount int) bool {
+	delta := float64(newCount - oldCount)
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta == 0 {
+		return false
+	}
+	if !t.percent {
+		return delta > t.value
+	}
+	if oldCount == 0 {
+		return true // any growth from zero is infinite
+	}
+	return 100*delta/float64(oldCount) > t.value
+}
+
+// showDiff reports neighbors that appeared, disappeared, changed state,
+// or whose prefix count changed beyond the threshold.
+func showDiff(w io.Writer, oldTable, newTable map[string]*neigh, t threshold, keys []sortKey) {
+	var appeared, disappeared, common []*neigh
+
+	for k, n := range newTable {
+		if _, found := oldTable[k]; found {
+			common = append(common, n)
+		} else {
+			appeared = append(appeared, n)
+		}
+	}
+	for k, n := range oldTable {
+		if _, found := newTable[k]; !found {
+			disappeared = append(disappeared, n)
+		}
+	}
+
+	sortNeighbors(appeared, keys)
+	sortNeighbors(disappeared, keys)
+	sortNeighbors(common, keys)
+
+	changes := 0
+
+	for _, n := range appeared {
+		fmt.Fprintf(w, "+ %s appeared: state=%s prefixes=%d\n", diffNeighbor(n), n.State, n.Prefixes)
+		changes++
+	}
+	for _, n := range disappeared {
+		fmt.Fprintf(w, "- %s disappeared: state=%s prefixes=%d\n", diffNeighbor(n), n.State, n.Prefixes)
+		changes++
+	}
+	for _, n := range common {
+		o := oldTable[neighKey(n)]
+		if o.State != n.State {
+			fmt.Fprintf(w, "~ %s state: %s -> %s\n", diffNeighbor(n), o.State, n.State)
+			changes++
+		}
+		oldCount, newCount := o.Prefixes, n.Prefixes
+		if t.exceeded(oldCount, newCount) {
+			fmt.Fprintf(w, "~ %s prefixes: %d -> %d (%+d)\n", diffNeighbor(n), oldCount, newCount, newCount-oldCount)
+			changes++
+		}
+	}
+
+	fmt.Fprintf(w, "%d appeared, %d disappeared, %d changes total\n", len(appeared), len(disappeared), changes)
+}
+
+// diffNeighbor returns the address and vrf columns of a diff line, after
+// the device when known: an address may be a neighbor of several devices.
+func diffNeighbor(n *neigh) string {
+	s := fmt.Sprintf("%-15s %-14s", n.Addr, n.VRF)
+	if n.Device != "" {
+		s = fmt.Sprintf("%-12s %s", n.Device, s)
+	}
+	return s
+}
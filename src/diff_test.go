@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	cases := []struct {
+		spec     string
+		old, new int
+		want     bool
+	}{
+		{"10", 100, 110, false},
+		{"10", 100, 111, true},
+		{"10", 111, 100, true},
+		{"10", 5, 5, false},
+		{"0", 5, 6, true},
+		{"5%", 100, 105, false},
+		{"5%", 100, 106, true},
+		{"5%", 100, 94, true},
+		{"5%", 0, 1, true},
+		{"5%", 0, 0, false},
+	}
+	for _, c := range cases {
+		th, err := parseThreshold(c.spec)
+		if err != nil {
+			t.Errorf("parseThreshold(%q): %v", c.spec, err)
+			continue
+		}
+		if got := th.exceeded(c.old, c.new); got != c.want {
+			t.Errorf("threshold %s: %d -> %d: got %v, want %v", c.spec, c.old, c.new, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "%", "-1", "ten", "5%%"} {
+		if _, err := parseThreshold(bad); err == nil {
+			t.Errorf("parseThreshold(%q): want error", bad)
+		}
+	}
+}
+
+func TestShowDiff(t *testing.T) {
+	table := func(list ...*neigh) map[string]*neigh {
+		m := map[string]*neigh{}
+		for _, n := range list {
+			m[neighKey(n)] = n
+		}
+		return m
+	}
+	oldTable := table(
+		&neigh{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", State: "Established", Prefixes: 100},
+		&neigh{Device: "pe2", Addr: "198.51.100.1", VRF: "CUST-A", State: "Established", Prefixes: 100},
+		&neigh{Device: "pe2", Addr: "198.51.100.9", VRF: "CUST-B", State: "Established", Prefixes: 7},
+	)
+	newTable := table(
+		&neigh{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", State: "Established", Prefixes: 104},
+		&neigh{Device: "pe2", Addr: "198.51.100.1", VRF: "CUST-A", State: "Idle", Prefixes: 0},
+		&neigh{Addr: "203.0.113.1", VRF: "default", State: "Active"},
+	)
+	keys, err := parseSortKeys("device,vrf,addr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, err := parseThreshold("5%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	showDiff(&out, oldTable, newTable, th, keys)
+
+	want := []string{
+		"+ 203.0.113.1     default        appeared: state=Active prefixes=0",
+		"- pe2          198.51.100.9    CUST-B         disappeared: state=Established prefixes=7",
+		"~ pe2          198.51.100.1    CUST-A         state: Established -> Idle",
+		"~ pe2          198.51.100.1    CUST-A         prefixes: 100 -> 0 (-100)",
+		"1 appeared, 1 disappeared, 4 changes total",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("showDiff:\n%s\nwant:\n%s", out.String(), strings.Join(want, "\n"))
+	}
+}
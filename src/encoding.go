@@ -0,0 +1,154 @@
+package main
+
+// input encodings of captures saved on Windows (SecureCRT, PuTTY logs,
+// PowerShell redirection), turned into the UTF-8 the parser expects:
+//
+//	EF BB BF          UTF-8 byte order mark, dropped
+//	FF FE / FE FF     UTF-16LE / UTF-16BE byte order mark, decoded
+//	62 00 67 00 ...   UTF-16LE without mark, detected from the NUL bytes
+//
+// CRLF line endings are stripped by scanLines.
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// encodingSample is the most bytes looked at to detect UTF-16 without a
+// byte order mark. Only bytes already buffered are, so a pipe (-stream,
+// -terminal) is never waited on past its first read.
+const encodingSample = 512
+
+// decodeInput returns br read as UTF-8, without byte order mark.
+func decodeInput(br *bufio.Reader) *bufio.Reader {
+	head, _ := br.Peek(4)
+	if n := br.Buffered(); n > len(head) {
+		if n > encodingSample {
+			n = encodingSample
+		}
+		head, _ = br.Peek(n)
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+		debugf("decodeInput: UTF-8 with byte order mark")
+		return br
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		br.Discard(2)
+		return newUTF16Reader(br, false)
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		br.Discard(2)
+		return newUTF16Reader(br, true)
+	}
+	switch utf16Guess(head) {
+	case "le":
+		return newUTF16Reader(br, false)
+	case "be":
+		return newUTF16Reader(br, true)
+	}
+	return br
+}
+
+// utf16Guess returns le or be when head looks like mostly ASCII text in
+// UTF-16, with a NUL byte in most high-order positions and few in the others.
+func utf16Guess(head []byte) string {
+	pairs := len(head) / 2
+	if pairs < 4 {
+		return ""
+	}
+	var even, odd int
+	for i := 0; i < 2*pairs; i += 2 {
+		if head[i] == 0 {
+			even++
+		}
+		if head[i+1] == 0 {
+			odd++
+		}
+	}
+	switch {
+	case odd*10 >= pairs*8 && even*10 < pairs:
+		return "le"
+	case even*10 >= pairs*8 && odd*10 < pairs:
+		return "be"
+	}
+	return ""
+}
+
+func newUTF16Reader(br *bufio.Reader, bigEndian bool) *bufio.Reader {
+	name := "UTF-16LE"
+	if bigEndian {
+		name = "UTF-16BE"
+	}
+	debugf("decodeInput: %s", name)
+	return bufio.NewReaderSize(&utf16Reader{r: br, bigEndian: bigEndian, pending: -1}, scanBufferSize)
+}
+
+// utf16Reader decodes UTF-16 to UTF-8.
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	pending   rune // unit read after an unpaired high surrogate, or -1
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n+utf8.UTFMax <= len(p) {
+		r, err := u.readRune()
+		if err != nil {
+			if n > 0 {
+				return n, nil // err again on the next call
+			}
+			return 0, err
+		}
+		n += utf8.EncodeRune(p[n:], r)
+	}
+	if n == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) readRune() (rune, error) {
+	c, err := u.unit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(c) {
+		return c, nil
+	}
+	c2, err := u.unit()
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	if r := utf16.DecodeRune(c, c2); r != utf8.RuneError {
+		return r, nil
+	}
+	if !utf16.IsSurrogate(c2) {
+		u.pending = c2 // not the low half, keep it
+	}
+	return utf8.RuneError, nil
+}
+
+// unit returns the next UTF-16 code unit. A final odd byte is dropped.
+func (u *utf16Reader) unit() (rune, error) {
+	if u.pending >= 0 {
+		c := u.pending
+		u.pending = -1
+		return c, nil
+	}
+	b0, err := u.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	b1, err := u.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if u.bigEndian {
+		return rune(b0)<<8 | rune(b1), nil
+	}
+	return rune(b1)<<8 | rune(b0), nil
+}
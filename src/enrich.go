@@ -0,0 +1,89 @@
+package main
+
+// enrichment of the parsed neighbors by an external command, e.g. customer
+// names from an IPAM or sites from DNS PTR records: the command reads the
+// neighbors as a JSON array on stdin and writes them back with an "extra"
+// object of string fields:
+//
+//	[{"device":"pe1","addr":"198.51.100.1","vrf":"CUST-A","extra":{"customer":"ACME","site":"gru1"}}]
+//
+// Neighbors are matched by device, addr and vrf; only their extra fields
+// are taken, the other fields and the neighbors left out are kept as parsed.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// extraColumnPrefix selects an enrichment field as column, e.g. extra.customer
+const extraColumnPrefix = "extra."
+
+// enrichTable runs command with the neighbors of table on stdin and merges
+// the extra fields it returns.
+func enrichTable(table map[string]*neigh, command string) error {
+	var in bytes.Buffer
+	if err := writeJSON(&in, neighborList(table)); err != nil {
+		return fmt.Errorf("enrichTable: %v", err)
+	}
+
+	cmd := shellCommand(command)
+	cmd.Stdin = &in
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("enrichTable: %s: %v", command, err)
+	}
+
+	var list []*neigh
+	if err := json.Unmarshal(out, &list); err != nil {
+		return fmt.Errorf("enrichTable: %s: bad output: %v", command, err)
+	}
+	var unknown int
+	for _, e := range list {
+		n, found := table[neighKey(e)]
+		if !found {
+			unknown++
+			continue
+		}
+		for k, v := range e.Extra {
+			if n.Extra == nil {
+				n.Extra = map[string]string{}
+			}
+			n.Extra[k] = v
+		}
+	}
+	if unknown > 0 {
+		warnf("enrichTable: %s: ignored %d neighbors not in the input", command, unknown)
+	}
+	return nil
+}
+
+// enriched wraps collect to enrich every table it returns, if command is set.
+func enriched(collect collectFunc, command string) collectFunc {
+	if command == "" {
+		return collect
+	}
+	return func() (map[string]*neigh, error) {
+		table, err := collect()
+		if err != nil {
+			return nil, err
+		}
+		if err := enrichTable(table, command); err != nil {
+			return nil, err
+		}
+		return table, nil
+	}
+}
+
+// extraColumn returns the column of an enrichment field, nil unless name
+// starts with extra.
+func extraColumn(name string) *column {
+	if !strings.HasPrefix(name, extraColumnPrefix) || len(name) == len(extraColumnPrefix) {
+		return nil
+	}
+	field := name[len(extraColumnPrefix):]
+	return &column{name: name, header: field, width: 16, value: func(n *neigh) string { return n.Extra[field] }}
+}
@@ -0,0 +1,117 @@
+package main
+
+// Arista EOS "show ip bgp neighbors vrf all" output, registered as the
+// "eos" dialect. EOS phrasing is mapped onto the IOS lines lineParser
+// already understands, so IOS captures parse the same with -dialect eos:
+//
+//BGP neighbor is 10.1.1.2, remote AS 65002, external link
+//  BGP version 4, remote router ID 10.255.0.2, VRF default
+//  Inherits configuration from and member of peer-group SPINES
+//  Description: SPINE1
+//  BGP state is Established, up for 5d02h
+//(...)
+//  Prefix Statistics:
+//                         Sent      Rcvd     Best Paths     Best ECMP Paths
+//    IPv4 Unicast:          12        25             25                   0
+//(...)
+//Local AS is 65001, local router ID 10.255.0.1
+//Local TCP address is 10.1.1.1, local port is 179
+//Remote TCP address is 10.1.1.2, remote port is 54321
+//TCP Socket Information:
+//  TCP state is ESTABLISHED
+//  Outgoing Maximum Segment Size (MSS): 1448
+//  Total Number of TCP retransmissions: 0
+
+import (
+	"fmt"
+	"strings"
+)
+
+const sectionEOSPrefixes = "eos-prefixes"
+
+// eosRewrites maps EOS line prefixes to their IOS equivalents.
+var eosRewrites = []struct{ eos, ios string }{
+	{"  BGP state is ", "  BGP state = "},
+	{"  Description: ", " Description: "},
+	{"  Inherits configuration from and member of peer-group ", " Member of peer-group "},
+	{"  Message Statistics:", "  Message statistics:"},
+}
+
+func init() {
+	registerDialect("eos", dialectFunc(eosParser))
+}
+
+func eosParser(scanner *neighScanner, line string, lineNum int) error {
+
+	if scanner.section == sectionEOSPrefixes && lineIndent(line) > 2 {
+		return parseEOSPrefixLine(scanner.curr, line, lineNum)
+	}
+
+	if scanner.curr != nil {
+		switch {
+		case strings.HasPrefix(line, "  BGP version "):
+			if vrf := splitFields(line).after("VRF"); vrf != "" {
+				scanner.curr.VRF = vrf
+			}
+		case line == "  Prefix Statistics:":
+			scanner.curr.Prefixes = 0
+			scanner.section = sectionEOSPrefixes
+			return nil
+		case strings.HasPrefix(line, "Local AS is "):
+			f := splitFields(line)
+			scanner.curr.LocalAS = f.after("AS", "is")
+			scanner.curr.LocalRouterID = f.after("router", "ID")
+			return nil
+		case strings.HasPrefix(line, "Local TCP address is "):
+			f := splitFields(line)
+			line = fmt.Sprintf("Local host: %s, Local port: %s", f.after("address", "is"), f.after("port", "is"))
+		case strings.HasPrefix(line, "Remote TCP address is "):
+			f := splitFields(line)
+			line = fmt.Sprintf("Foreign host: %s, Foreign port: %s", f.after("address", "is"), f.after("port", "is"))
+		case strings.HasPrefix(line, "  TCP state is "):
+			scanner.curr.tcpSession().State = splitFields(line).word(3)
+			return nil
+		case strings.HasPrefix(line, "  Outgoing Maximum Segment Size (MSS): "):
+			scanner.curr.tcpSession().MSS, _ = splitFields(line).number(5, "mss")
+			return nil
+		case strings.HasPrefix(line, "  Total Number of TCP retransmissions: "):
+			retransmit, _ := splitFields(line).number(5, "retransmissions")
+			scanner.curr.tcpSession().Retransmit = int64(retransmit)
+			return nil
+		case strings.HasPrefix(line, "TTL is "):
+			scanner.curr.tcpSession().OutgoingTTL, _ = splitFields(line).number(2, "ttl")
+			return nil
+		}
+	}
+
+	for _, r := range eosRewrites {
+		if strings.HasPrefix(line, r.eos) {
+			line = r.ios + line[len(r.eos):]
+			break
+		}
+	}
+
+	return lineParser(scanner, line, lineNum)
+}
+
+// parseEOSPrefixLine parses an address family row of "Prefix Statistics:".
+// The neighbor prefix count is the sum of the received prefixes.
+func parseEOSPrefixLine(n *neigh, line string, lineNum int) error {
+	s := strings.TrimSpace(line)
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return nil // column header
+	}
+	af := s[:i]
+	f := splitFields(s[i+1:])
+	if err := f.want(2, "eos prefix statistics line"); err != nil {
+		return fmt.Errorf("parseEOSPrefixLine: %v: line=%d [%s]", err, lineNum, line)
+	}
+	rcvd, err := f.number(1, "eos received prefixes")
+	if err != nil {
+		return fmt.Errorf("parseEOSPrefixLine: %v: line=%d [%s]", err, lineNum, line)
+	}
+	n.afPolicy(af).Prefixes = rcvd
+	n.Prefixes += rcvd
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+// expected-state validation against an intent file listing the neighbors
+// that should exist, per device and VRF:
+//
+//	pe1:
+//	  CUST-A:
+//	    - addr: 198.51.100.1
+//	      asn: 65001
+//	      min_prefixes: 10
+//	  --:
+//	    - {addr: 192.0.2.1, asn: 64512}
+//
+// Device "*" matches any device, e.g. for captures without a prompt.
+// Neighbors found on a listed device but missing from the intent are unexpected.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const intentAnyDevice = "*"
+
+type expectedNeigh struct {
+	Device      string
+	VRF         string
+	Addr        string
+	ASN         string // empty to accept any
+	MinPrefixes int
+}
+
+type intent struct {
+	neighbors []*expectedNeigh
+	devices   map[string]bool
+}
+
+// loadIntent reads an intent file in YAML, or JSON when named *.json.
+func loadIntent(path string) (*intent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadIntent: %v", err)
+	}
+	defer f.Close()
+
+	var doc interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewDecoder(f).Decode(&doc)
+	} else {
+		doc, err = parseYAML(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadIntent: %s: %v", path, err)
+	}
+
+	in, err := decodeIntent(doc)
+	if err != nil {
+		return nil, fmt.Errorf("loadIntent: %s: %v", path, err)
+	}
+	return in, nil
+}
+
+func decodeIntent(doc interface{}) (*intent, error) {
+	devices, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("decodeIntent: expecting mapping of devices")
+	}
+	in := &intent{devices: map[string]bool{}}
+	for device, v := range devices {
+		in.devices[device] = true
+		vrfs, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("decodeIntent: device %s: expecting mapping of VRFs", device)
+		}
+		for vrf, v := range vrfs {
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("decodeIntent: %s/%s: expecting list of neighbors", device, vrf)
+			}
+			for i, item := range list {
+				e, err := decodeExpected(item)
+				if err != nil {
+					return nil, fmt.Errorf("decodeIntent: %s/%s: neighbor %d: %v", device, vrf, i, err)
+				}
+				e.Device = device
+				e.VRF = vrf
+				in.neighbors = append(in.neighbors, e)
+			}
+		}
+	}
+	sort.Slice(in.neighbors, func(i, j int) bool { return intentKey(in.neighbors[i]) < intentKey(in.neighbors[j]) })
+	return in, nil
+}
+
+func decodeExpected(item interface{}) (*expectedNeigh, error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting mapping with addr, asn, min_prefixes")
+	}
+	e := &expectedNeigh{}
+	for k, v := range fields {
+		s := fmt.Sprint(v) // JSON numbers decode as float64
+		switch k {
+		case "addr":
+			e.Addr = normalizeAddr(s)
+		case "asn", "remote_as":
+			e.ASN = s
+		case "min_prefixes":
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad min_prefixes: [%s]", s)
+			}
+			e.MinPrefixes = n
+		default:
+			return nil, fmt.Errorf("unknown field: [%s]", k)
+		}
+	}
+	if e.Addr == "" {
+		return nil, fmt.Errorf("missing addr")
+	}
+	return e, nil
+}
+
+// normalizeAddr gives IP addresses a canonical form, e.g. lowercase IPv6.
+func normalizeAddr(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip.String()
+	}
+	return addr
+}
+
+func intentKey(e *expectedNeigh) string {
+	return e.Device + "/" + e.VRF + "/" + e.Addr
+}
+
+type intentViolation struct {
+	Kind     string `json:"kind"` // missing, unexpected, asn, prefixes
+	Device   string `json:"device,omitempty"`
+	VRF      string `json:"vrf"`
+	Addr     string `json:"addr"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+type intentReport struct {
+	Expected   int                `json:"expected"`
+	Found      int                `json:"found"` // expected neighbors present
+	Violations []*intentViolation `json:"violations"`
+}
+
+// validate compares the neighbor table against the intent.
+func (in *intent) validate(table map[string]*neigh) *intentReport {
+	list := neighborList(table)
+	keys, _ := parseSortKeys("device,vrf,addr")
+	sortNeighbors(list, keys)
+
+	matched := map[*neigh]bool{}
+	find := func(e *expectedNeigh) *neigh {
+		for _, n := range list {
+			if !matched[n] && (e.Device == intentAnyDevice || e.Device == n.Device) && e.VRF == n.VRF && e.Addr == normalizeAddr(n.Addr) {
+				return n
+			}
+		}
+		return nil
+	}
+
+	r := &intentReport{Expected: len(in.neighbors), Violations: []*intentViolation{}}
+	// specific devices first, so "*" entries match what is left
+	for _, wildcard := range []bool{false, true} {
+		for _, e := range in.neighbors {
+			if (e.Device == intentAnyDevice) != wildcard {
+				continue
+			}
+			n := find(e)
+			if n == nil {
+				r.Violations = append(r.Violations, &intentViolation{Kind: "missing", Device: e.Device, VRF: e.VRF, Addr: e.Addr, Expected: expectedASN(e)})
+				continue
+			}
+			matched[n] = true
+			r.Found++
+			if e.ASN != "" && e.ASN != n.RemoteAS {
+				r.Violations = append(r.Violations, &intentViolation{Kind: "asn", Device: n.Device, VRF: n.VRF, Addr: n.Addr, Expected: e.ASN, Found: n.RemoteAS})
+			}
+			if n.Prefixes < e.MinPrefixes {
+				r.Violations = append(r.Violations, &intentViolation{Kind: "prefixes", Device: n.Device, VRF: n.VRF, Addr: n.Addr,
+					Expected: ">= " + strconv.Itoa(e.MinPrefixes), Found: strconv.Itoa(n.Prefixes)})
+			}
+		}
+	}
+
+	for _, n := range list {
+		if matched[n] || !(in.devices[n.Device] || in.devices[intentAnyDevice]) {
+			continue
+		}
+		r.Violations = append(r.Violations, &intentViolation{Kind: "unexpected", Device: n.Device, VRF: n.VRF, Addr: n.Addr, Found: "asn " + n.RemoteAS})
+	}
+
+	return r
+}
+
+func expectedASN(e *expectedNeigh) string {
+	if e.ASN == "" {
+		return ""
+	}
+	return "asn " + e.ASN
+}
+
+// writeIntentReport prints the violations and returns the process exit code.
+func writeIntentReport(w io.Writer, r *intentReport) int {
+	for _, v := range r.Violations {
+		detail := v.Expected
+		switch {
+		case v.Kind == "unexpected":
+			detail = v.Found
+		case v.Found != "":
+			detail = "expected " + v.Expected + ", found " + v.Found
+		}
+		row := fmt.Sprintf("%-10s %-12s %-14s %-15s %s", strings.ToUpper(v.Kind), v.Device, v.VRF, v.Addr, detail)
+		fmt.Fprintln(w, strings.TrimRight(row, " "))
+	}
+	if len(r.Violations) == 0 {
+		fmt.Fprintf(w, "OK: %d expected neighbors found as expected\n", r.Expected)
+		return exitOK
+	}
+	fmt.Fprintf(w, "FAIL: %d violations, %d of %d expected neighbors found\n", len(r.Violations), r.Found, r.Expected)
+	return exitCheckFailed
+}
+
+func writeIntentReportJSON(w io.Writer, r *intentReport) (int, error) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return exitError, fmt.Errorf("writeIntentReportJSON: %v", err)
+	}
+	if len(r.Violations) > 0 {
+		return exitCheckFailed, nil
+	}
+	return exitOK, nil
+}
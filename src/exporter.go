@@ -0,0 +1,112 @@
+package main
+
+// output writers selected by name with -format (parse, watch, export):
+// table, json, csv, yaml and html are built in. exec:COMMAND hands the
+// neighbors to an external command instead, as a JSON array on stdin (the
+// json output), e.g. to push rows into a CMDB without changing the tool:
+//
+//	export -format 'exec:cmdb-import --source bgp' archive/*.txt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exporter writes a neighbor list.
+type exporter interface {
+	export(list []*neigh) error
+}
+
+// exporterFunc adapts a plain function to the exporter interface.
+type exporterFunc func(list []*neigh) error
+
+func (f exporterFunc) export(list []*neigh) error {
+	return f(list)
+}
+
+// newExporterFunc returns an exporter writing to w (stdout or the -o file)
+// with the selected columns. Exporters sending elsewhere may ignore both.
+type newExporterFunc func(w io.Writer, cols []*column) exporter
+
+const exporterDefault = "table"
+
+var exporters = map[string]newExporterFunc{
+	"table": func(w io.Writer, cols []*column) exporter {
+		return exporterFunc(func(list []*neigh) error { writeTable(w, cols, list); return nil })
+	},
+	"json": func(w io.Writer, cols []*column) exporter {
+		return exporterFunc(func(list []*neigh) error { return writeJSON(w, list) })
+	},
+	"csv": func(w io.Writer, cols []*column) exporter {
+		return exporterFunc(func(list []*neigh) error { return writeCSV(w, cols, list) })
+	},
+	"yaml": func(w io.Writer, cols []*column) exporter {
+		return exporterFunc(func(list []*neigh) error { return writeYAML(w, list) })
+	},
+	"html": func(w io.Writer, cols []*column) exporter {
+		return exporterFunc(func(list []*neigh) error { return writeHTML(w, cols, list, time.Now()) })
+	},
+}
+
+// execExporterPrefix selects the exec exporter, exec:COMMAND.
+const execExporterPrefix = "exec:"
+
+func lookupExporter(name string, w io.Writer, cols []*column) (exporter, error) {
+	if name == "" {
+		name = exporterDefault
+	}
+	if err := checkExporter(name); err != nil {
+		return nil, fmt.Errorf("lookupExporter: %v", err)
+	}
+	if command := strings.TrimPrefix(name, execExporterPrefix); command != name {
+		return execExporter(command, w), nil
+	}
+	return exporters[name](w, cols), nil
+}
+
+// checkExporter fails for unknown formats, before anything is collected.
+func checkExporter(name string) error {
+	if strings.HasPrefix(name, execExporterPrefix) {
+		if strings.TrimSpace(name[len(execExporterPrefix):]) == "" {
+			return fmt.Errorf("missing command: %s", name)
+		}
+		return nil
+	}
+	if _, ok := exporters[name]; name != "" && !ok {
+		return fmt.Errorf("unknown format: %s (known: %s)", name, strings.Join(exporterNames(), ","))
+	}
+	return nil
+}
+
+func exporterNames() []string {
+	var names []string
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, execExporterPrefix+"COMMAND")
+}
+
+// execExporter runs command with the neighbor list on stdin, as written by
+// the json exporter, and copies its output to w.
+func execExporter(command string, w io.Writer) exporter {
+	return exporterFunc(func(list []*neigh) error {
+		var in bytes.Buffer
+		if err := writeJSON(&in, list); err != nil {
+			return fmt.Errorf("execExporter: %v", err)
+		}
+		cmd := shellCommand(command)
+		cmd.Stdin = &in
+		cmd.Stdout = w
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("execExporter: %s: %v", command, err)
+		}
+		return nil
+	})
+}
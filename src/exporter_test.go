@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLookupExporter(t *testing.T) {
+	list := []*neigh{
+		{Addr: "198.51.100.1", VRF: "CUST-A", RemoteAS: "65001", State: "Established", Prefixes: 12},
+		{Addr: "198.51.100.2", VRF: "CUST-B", RemoteAS: "65002", State: "Idle"},
+	}
+	cols, err := columnsFor("addr,vrf,state", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		format string
+		want   string // in the output
+		fail   bool
+	}{
+		{format: "", want: "198.51.100.2"},
+		{format: "table", want: "198.51.100.2"},
+		{format: "json", want: `"addr": "198.51.100.1"`},
+		{format: "csv", want: "198.51.100.1,CUST-A,Established"},
+		{format: "yaml", want: `addr: "198.51.100.1"`},
+		{format: "html", want: "<html"},
+		{format: "exec:grep -c CUST-", want: "2"},
+		{format: "exec:cat", want: `"vrf": "CUST-B"`},
+		{format: "exec:exit 3", fail: true},
+		{format: "exec: ", fail: true},
+		{format: "xml", fail: true},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		e, err := lookupExporter(c.format, &out, cols)
+		if err == nil {
+			err = e.export(list)
+		}
+		if c.fail {
+			if err == nil {
+				t.Errorf("%q: want error", c.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.format, err)
+			continue
+		}
+		if !strings.Contains(out.String(), c.want) {
+			t.Errorf("%q: output without %q:\n%s", c.format, c.want, out.String())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lineFields holds the whitespace-separated fields of a capture line with
+// bounds-checked access, so off-spec lines end up as parse errors rather
+// than index out of range panics.
+type lineFields []string
+
+func splitFields(line string) lineFields {
+	return strings.Fields(line)
+}
+
+// want fails unless there are at least n fields.
+func (f lineFields) want(n int, what string) error {
+	if len(f) < n {
+		return fmt.Errorf("short %s: want %d fields, got %d", what, n, len(f))
+	}
+	return nil
+}
+
+// at returns field i, or "" past the end.
+func (f lineFields) at(i int) string {
+	if i < 0 || i >= len(f) {
+		return ""
+	}
+	return f[i]
+}
+
+// word returns field i without trailing punctuation, e.g. "10.0.0.1," -> "10.0.0.1".
+func (f lineFields) word(i int) string {
+	return strings.TrimRight(f.at(i), ",;:")
+}
+
+// number parses field i as an integer, ignoring trailing punctuation.
+func (f lineFields) number(i int, what string) (int, error) {
+	v, err := strconv.Atoi(f.word(i))
+	if err != nil {
+		return 0, fmt.Errorf("bad %s: [%s]", what, f.at(i))
+	}
+	return v, nil
+}
+
+// after returns the word following the first occurrence of the keyword
+// sequence, or "" if absent: after("remote", "AS") on
+// "BGP neighbor is 10.0.0.1,  remote AS 65001, external link" is "65001".
+func (f lineFields) after(keywords ...string) string {
+	for i := 0; i+len(keywords) < len(f); i++ {
+		match := true
+		for j, k := range keywords {
+			if f[i+j] != k {
+				match = false
+				break
+			}
+		}
+		if match {
+			return f.word(i + len(keywords))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// matcher matches a field value against a pattern:
+// CUST-*        glob (path.Match syntax)
+// Idle,Active   any of the comma-separated globs
+// /^CUST-\d+$/  regular expression
+// !Established  negation of any of the above
+type matcher struct {
+	negate bool
+	globs  []string
+	re     *regexp.Regexp
+}
+
+func parseMatcher(spec string) (*matcher, error) {
+	if spec == "" {
+		return nil, nil // match everything
+	}
+
+	m := &matcher{}
+
+	if strings.HasPrefix(spec, "!") {
+		m.negate = true
+		spec = spec[1:]
+	}
+
+	if len(spec) > 1 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+		re, err := regexp.Compile(spec[1 : len(spec)-1])
+		if err != nil {
+			return nil, fmt.Errorf("parseMatcher: bad regexp: [%s]: %v", spec, err)
+		}
+		m.re = re
+		return m, nil
+	}
+
+	for _, g := range strings.Split(spec, ",") {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("parseMatcher: bad glob: [%s]: %v", g, err)
+		}
+		m.globs = append(m.globs, g)
+	}
+
+	return m, nil
+}
+
+func (m *matcher) match(value string) bool {
+	if m == nil {
+		return true
+	}
+	return m.matchPattern(value) != m.negate
+}
+
+func (m *matcher) matchPattern(value string) bool {
+	if m.re != nil {
+		return m.re.MatchString(value)
+	}
+	for _, g := range m.globs {
+		if ok, _ := path.Match(g, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type neighFilter struct {
+	vrf      *matcher
+	state    *matcher
+	asn      *matcher
+	link     *matcher
+	auth     *matcher // see authValue
+	afi      *matcher // see afi.go
+	shutdown string   // include (default), exclude or only
+}
+
+func newNeighFilter(vrf, state, asn string) (*neighFilter, error) {
+	var f neighFilter
+	var err error
+	if f.vrf, err = parseMatcher(vrf); err != nil {
+		return nil, fmt.Errorf("newNeighFilter: vrf: %v", err)
+	}
+	if f.state, err = parseMatcher(state); err != nil {
+		return nil, fmt.Errorf("newNeighFilter: state: %v", err)
+	}
+	if f.asn, err = parseMatcher(asn); err != nil {
+		return nil, fmt.Errorf("newNeighFilter: asn: %v", err)
+	}
+	return &f, nil
+}
+
+func (f *neighFilter) match(n *neigh) bool {
+	switch {
+	case f.shutdown == "exclude" && n.Shutdown, f.shutdown == "only" && !n.Shutdown:
+		return false
+	}
+	return f.vrf.match(n.VRF) && f.state.match(n.State) && f.asn.match(n.RemoteAS) &&
+		f.link.match(n.Link) && (f.auth == nil || f.auth.match(authMatchValue(n))) && f.afi.matchAFI(n)
+}
+
+// authMatchValue is authValue, unknown when not known.
+func authMatchValue(n *neigh) string {
+	if v := authValue(n); v != "" {
+		return v
+	}
+	return "unknown"
+}
+
+// filterTable returns a new table holding only neighbors matched by the filter.
+// With -afi, the neighbors are copies narrowed to the selected address families.
+func filterTable(table map[string]*neigh, f *neighFilter) map[string]*neigh {
+	result := map[string]*neigh{}
+	for k, n := range table {
+		if f.match(n) {
+			result[k] = f.afi.selectAFI(n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseMatcher(t *testing.T) {
+	cases := []struct {
+		spec  string
+		match []string
+		miss  []string
+	}{
+		{"", []string{"", "anything"}, nil},
+		{"CUST-*", []string{"CUST-A", "CUST-"}, []string{"default", "XCUST-A"}},
+		{"Idle,Active", []string{"Idle", "Active"}, []string{"Established", "Idle,Active"}},
+		{"!Established", []string{"Idle", ""}, []string{"Established"}},
+		{`/^CUST-\d+$/`, []string{"CUST-1", "CUST-42"}, []string{"CUST-A", "XCUST-1"}},
+		{`!/^650\d\d$/`, []string{"64512", "6500"}, []string{"65001"}},
+		{"/", []string{"/"}, []string{"x"}}, // too short for a regexp
+	}
+	for _, c := range cases {
+		m, err := parseMatcher(c.spec)
+		if err != nil {
+			t.Errorf("parseMatcher(%q): %v", c.spec, err)
+			continue
+		}
+		for _, v := range c.match {
+			if !m.match(v) {
+				t.Errorf("%q does not match %q", c.spec, v)
+			}
+		}
+		for _, v := range c.miss {
+			if m.match(v) {
+				t.Errorf("%q matches %q", c.spec, v)
+			}
+		}
+	}
+	for _, bad := range []string{"/(/", "CUST-[", "!/a[/"} {
+		if _, err := parseMatcher(bad); err == nil {
+			t.Errorf("parseMatcher(%q): want error", bad)
+		}
+	}
+}
+
+func TestFilterFlags(t *testing.T) {
+	table := map[string]*neigh{}
+	for _, n := range []*neigh{
+		{Addr: "198.51.100.1", VRF: "CUST-A", RemoteAS: "65001", State: "Established", Link: "external", TCP: &tcpSession{options: true, MD5: true},
+			Policies: []*afPolicy{{AddressFamily: "IPv4 Unicast", Prefixes: 10}, {AddressFamily: "IPv6 Unicast", Prefixes: 4}}},
+		{Addr: "198.51.100.2", VRF: "CUST-A", RemoteAS: "65002", State: "Idle", Link: "external", Shutdown: true},
+		{Addr: "198.51.100.3", VRF: "CUST-B", RemoteAS: "65003", State: "Established", Link: "external", TCP: &tcpSession{options: true},
+			Policies: []*afPolicy{{AddressFamily: "IPv4 Unicast", Prefixes: 7}}},
+		{Addr: "10.0.0.2", VRF: "default", RemoteAS: "64512", State: "Active", Link: "internal", TCP: &tcpSession{options: true, AO: true}},
+	} {
+		table[neighKey(n)] = n
+	}
+	cases := []struct {
+		name string
+		f    filterFlags
+		want string // addresses, sorted
+	}{
+		{"all", filterFlags{}, "10.0.0.2 198.51.100.1 198.51.100.2 198.51.100.3"},
+		{"vrf", filterFlags{vrf: "CUST-*"}, "198.51.100.1 198.51.100.2 198.51.100.3"},
+		{"state", filterFlags{state: "!Established"}, "10.0.0.2 198.51.100.2"},
+		{"asn", filterFlags{asn: "/^6500[12]$/"}, "198.51.100.1 198.51.100.2"},
+		{"link", filterFlags{link: "internal"}, "10.0.0.2"},
+		{"auth", filterFlags{auth: "!md5,tcp-ao"}, "198.51.100.2 198.51.100.3"},
+		{"auth unknown", filterFlags{auth: "unknown"}, "198.51.100.2"},
+		{"afi", filterFlags{afi: "ipv6"}, "198.51.100.1"},
+		{"not afi", filterFlags{afi: "!ipv6"}, "10.0.0.2 198.51.100.2 198.51.100.3"},
+		{"shutdown exclude", filterFlags{shutdown: "exclude"}, "10.0.0.2 198.51.100.1 198.51.100.3"},
+		{"shutdown only", filterFlags{shutdown: "only"}, "198.51.100.2"},
+		{"combined", filterFlags{vrf: "CUST-A", state: "Established", shutdown: "exclude"}, "198.51.100.1"},
+	}
+	for _, c := range cases {
+		if c.f.shutdown == "" {
+			c.f.shutdown = "include"
+		}
+		filter, _, err := c.f.build()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		var got []string
+		for _, n := range filterTable(table, filter) {
+			got = append(got, n.Addr)
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, strings.Join(got, " "), c.want)
+		}
+	}
+
+	// -afi narrows the prefix count
+	filter, _, err := (&filterFlags{afi: "ipv4", shutdown: "include"}).build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := tableKey("", "198.51.100.1", "CUST-A")
+	if n := filterTable(table, filter)[key]; n == nil || len(n.Policies) != 1 || n.Prefixes != 10 {
+		t.Errorf("-afi ipv4: got %+v", n)
+	}
+	if n := table[key]; len(n.Policies) != 2 {
+		t.Errorf("-afi ipv4 modified the table: %+v", n)
+	}
+
+	for _, bad := range []filterFlags{{shutdown: "yes"}, {vrf: "/(/", shutdown: "include"}, {link: "[", shutdown: "include"}, {sort: "bogus", shutdown: "include"}} {
+		if _, _, err := bad.build(); err == nil {
+			t.Errorf("%+v: want error", bad)
+		}
+	}
+}
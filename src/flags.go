@@ -0,0 +1,529 @@
+package main
+
+// flag groups shared by the subcommands.
+// Each group registers its flags on the subcommand flag set.
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type commonFlags struct {
+	logLevel  string
+	logFormat string
+	quiet     bool
+	config    string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.logLevel, "log-level", "info", "log level: debug, info, warn, error")
+	fs.StringVar(&c.logFormat, "log-format", "text", "log format: text or json (one object per line)")
+	fs.BoolVar(&c.quiet, "quiet", false, "log errors only")
+	fs.StringVar(&c.config, "config", "", "YAML file with flag defaults, SSH credentials and the device inventory for -devices")
+}
+
+func (c *commonFlags) setup() error {
+	return setupLogging(c.logLevel, c.logFormat, c.quiet)
+}
+
+// inputFlags select how captures are parsed.
+type inputFlags struct {
+	opts        parseOptions
+	dialect     string
+	dialectFile string
+	coverage    bool
+}
+
+func (f *inputFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.opts.strict, "strict", false, "stop parsing at the first malformed line (default: skip malformed lines and report them)")
+	fs.StringVar(&f.dialect, "dialect", "", "input dialect: "+strings.Join(dialectNames(), ",")+" (default "+dialectAuto+": detected from the capture, or the one loaded by -dialect-file)")
+	fs.StringVar(&f.dialectFile, "dialect-file", "", "load a custom dialect from JSON file")
+	fs.BoolVar(&f.coverage, "coverage", false, "write the detected platform, the dialect and the share of lines recognized of each capture to stderr")
+	fs.BoolVar(&f.opts.textfsm, "textfsm", false, "input is JSON parsed by ntc-templates/TextFSM (show ip bgp neighbors or summary)")
+	fs.IntVar(&f.opts.maxLineLength, "max-line", scanMaxLineLength, "skip capture lines longer than this many bytes, e.g. console logs without newlines (with -strict, stop)")
+	fs.StringVar(&f.opts.enrich, "enrich", "", "run shell command with the neighbors JSON on stdin, reading them back with extra fields (columns extra.NAME)")
+	fs.StringVar(&f.opts.backupDir, "backup-dir", "", "read the neighbors section of every device file in a RANCID or Oxidized backup tree (arguments then select devices by glob)")
+}
+
+// parseOptions loads the dialect and returns the resulting options.
+func (f *inputFlags) parseOptions() (parseOptions, error) {
+	if f.dialectFile != "" {
+		name, err := loadDialectFile(f.dialectFile)
+		if err != nil {
+			return f.opts, err
+		}
+		if f.dialect == "" {
+			f.dialect = name
+		}
+	}
+	d, err := lookupDialect(f.dialect)
+	if err != nil {
+		return f.opts, err
+	}
+	f.opts.dialect = d
+	f.opts.dialectName = f.dialect
+	if f.coverage {
+		f.opts.report = func(r captureReport) { fmt.Fprintln(os.Stderr, r) }
+	}
+	return f.opts, nil
+}
+
+type filterFlags struct {
+	sort     string
+	vrf      string
+	state    string
+	asn      string
+	link     string
+	auth     string
+	afi      string
+	shutdown string
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.sort, "sort", "vrf,addr", "sort keys: device, addr, vrf, asn, state, prefixes, uptime (suffix :desc for descending)")
+	fs.StringVar(&f.vrf, "vrf", "", "show only matching VRFs (glob, comma list, /regexp/, ! to negate)")
+	fs.StringVar(&f.state, "state", "", "show only matching states, e.g. '!Established'")
+	fs.StringVar(&f.asn, "asn", "", "show only matching remote ASNs")
+	fs.StringVar(&f.afi, "afi", "", "show only neighbors with matching address families, counting only their prefixes: ipv4, ipv6, vpnv4, vpnv6, l2vpn-evpn...")
+	fs.StringVar(&f.link, "link", "", "show only matching link types: external, internal")
+	fs.StringVar(&f.auth, "auth", "", "show only matching session authentication: md5, tcp-ao, none or unknown (no TCP connection), e.g. '!md5,tcp-ao'")
+	fs.StringVar(&f.shutdown, "shutdown", "include", "administratively shut down neighbors: include, exclude or only")
+}
+
+func (f *filterFlags) build() (*neighFilter, []sortKey, error) {
+	keys, err := parseSortKeys(f.sort)
+	if err != nil {
+		return nil, nil, err
+	}
+	filter, err := newNeighFilter(f.vrf, f.state, f.asn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if filter.link, err = parseMatcher(f.link); err != nil {
+		return nil, nil, fmt.Errorf("filterFlags: link: %v", err)
+	}
+	if filter.auth, err = parseMatcher(f.auth); err != nil {
+		return nil, nil, fmt.Errorf("filterFlags: auth: %v", err)
+	}
+	if filter.afi, err = parseMatcher(f.afi); err != nil {
+		return nil, nil, fmt.Errorf("filterFlags: afi: %v", err)
+	}
+	switch f.shutdown {
+	case "include", "exclude", "only":
+		filter.shutdown = f.shutdown
+	default:
+		return nil, nil, fmt.Errorf("filterFlags: bad -shutdown: [%s] (expecting include, exclude or only)", f.shutdown)
+	}
+	return filter, keys, nil
+}
+
+type outputFlags struct {
+	columns     string
+	json        bool
+	csv         bool
+	yaml        bool
+	format      string
+	summary     bool
+	summaryTop  int
+	top         int
+	topPrevious string
+	flaps       string
+	flapsWindow time.Duration
+	nearLimit   string
+	timers      string
+	rr          bool
+	color       string
+
+	templateFile   string
+	templateString string
+	tmpl           *template.Template // from -template or -template-string, set by parseColumns
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.columns, "columns", "", "output columns: "+strings.Join(columnNames(), ",")+" (default "+defaultColumns+")")
+	fs.BoolVar(&o.json, "json", false, "write JSON output")
+	fs.BoolVar(&o.csv, "csv", false, "write CSV output")
+	fs.BoolVar(&o.yaml, "yaml", false, "write YAML output")
+	fs.StringVar(&o.format, "format", "", "output format: "+strings.Join(exporterNames(), ",")+" (default "+exporterDefault+")")
+	fs.BoolVar(&o.summary, "summary", false, "write aggregated statistics per state, VRF and ASN instead of the neighbor list")
+	fs.IntVar(&o.summaryTop, "summary-top", 10, "number of top neighbors by prefix count in -summary output")
+	fs.IntVar(&o.top, "top", 0, "list the N neighbors with the most prefixes, and with -top-previous the largest increases and decreases, instead of the neighbor list")
+	fs.StringVar(&o.topPrevious, "top-previous", "", "previous run for -top deltas: JSON table (parse -json, -alert-state) or history store (sqlite:history.db, postgres:connstring)")
+	fs.StringVar(&o.flaps, "flaps", "", "list neighbors whose session reset (down, or uptime restarted) across the runs in this history store (sqlite:history.db, postgres:connstring) or directory of JSON tables, instead of the neighbor list")
+	fs.DurationVar(&o.flapsWindow, "flaps-window", 24*time.Hour, "runs considered by -flaps")
+	fs.StringVar(&o.color, "color", "auto", "color the text table: never, auto (when stdout is a terminal) or always")
+	fs.BoolVar(&o.rr, "rr", false, "report route reflector clients, iBGP non-clients and confederation peers per device instead of the neighbor list")
+	fs.StringVar(&o.timers, "timers", "", "report neighbors whose hold time/keepalive in use differ from this standard (e.g. 180/60) instead of the neighbor list")
+	fs.StringVar(&o.templateFile, "template", "", "render the neighbor list through this Go text/template file instead of -format")
+	fs.StringVar(&o.templateString, "template-string", "", "render the neighbor list through this Go text/template text, e.g. '{{range .Neighbors}}{{.Addr}} {{.State}}{{\"\\n\"}}{{end}}'")
+	fs.StringVar(&o.nearLimit, "near-limit", "", "report address families within this percentage of their max-prefix limit (e.g. 20%) instead of the neighbor list")
+}
+
+// formatName returns the exporter selected by -format, -json, -csv or -yaml.
+func (o *outputFlags) formatName() string {
+	switch {
+	case o.json:
+		return "json"
+	case o.csv:
+		return "csv"
+	case o.yaml:
+		return "yaml"
+	}
+	return o.format
+}
+
+// parseColumns returns the selected columns, checks the output format,
+// loads the -template and applies -color. The device column is added by default when several
+// devices are merged.
+func (o *outputFlags) parseColumns(multiDevice bool) ([]*column, error) {
+	if err := checkExporter(o.formatName()); err != nil {
+		return nil, err
+	}
+	if err := setupColor(o.color); err != nil {
+		return nil, err
+	}
+	t, err := loadTemplate(o.templateFile, o.templateString)
+	if err != nil {
+		return nil, err
+	}
+	o.tmpl = t
+	return columnsFor(o.columns, o.formatName() == "csv", multiDevice)
+}
+
+func columnsFor(spec string, csvOutput, multiDevice bool) ([]*column, error) {
+	if spec == "" {
+		spec = defaultColumns
+		if csvOutput {
+			spec = defaultCSVColumns
+		}
+		if multiDevice {
+			spec = "device," + spec
+		}
+	}
+	return parseColumns(spec)
+}
+
+func (o *outputFlags) write(w io.Writer, cols []*column, list []*neigh) error {
+	if o.nearLimit != "" {
+		within, err := parsePercent(o.nearLimit)
+		if err != nil {
+			return err
+		}
+		rows := nearLimit(list, within)
+		if o.formatName() == "json" {
+			return writeNearLimitJSON(w, rows)
+		}
+		writeNearLimit(w, rows)
+		return nil
+	}
+	if o.rr {
+		rows := newRRReport(list)
+		if o.formatName() == "json" {
+			return writeRRReportJSON(w, rows)
+		}
+		writeRRReport(w, rows)
+		return nil
+	}
+	if o.timers != "" {
+		hold, keepalive, err := parseTimersStandard(o.timers)
+		if err != nil {
+			return err
+		}
+		rows := timersDeviations(list, hold, keepalive)
+		if o.formatName() == "json" {
+			return writeTimersDeviationsJSON(w, rows)
+		}
+		writeTimersDeviations(w, rows, hold, keepalive)
+		return nil
+	}
+	if o.flaps != "" {
+		now := time.Now()
+		runs, err := loadRuns(o.flaps, now.Add(-o.flapsWindow))
+		if err != nil {
+			return err
+		}
+		entries := newFlapReport(runs, list, now)
+		if o.formatName() == "json" {
+			return writeFlapsJSON(w, entries)
+		}
+		writeFlaps(w, entries, len(runs), o.flapsWindow)
+		return nil
+	}
+	if o.top > 0 {
+		prev, err := loadPrevious(o.topPrevious)
+		if err != nil {
+			return err
+		}
+		report := newTopReport(list, prev, o.top)
+		if o.formatName() == "json" {
+			return writeTopJSON(w, report)
+		}
+		writeTop(w, report)
+		return nil
+	}
+	if o.summary {
+		report := newSummaryReport(list, o.summaryTop)
+		if o.formatName() == "json" {
+			return writeSummaryJSON(w, report)
+		}
+		writeSummary(w, report)
+		return nil
+	}
+	if o.tmpl != nil {
+		return writeTemplate(w, o.tmpl, list, time.Now())
+	}
+	e, err := lookupExporter(o.formatName(), w, cols)
+	if err != nil {
+		return err
+	}
+	return e.export(list)
+}
+
+type anonFlags struct {
+	enabled bool
+	key     string
+}
+
+func (f *anonFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.enabled, "anonymize", false, "rewrite addresses, AS numbers, VRF names, descriptions and device names with stable hashes")
+	fs.StringVar(&f.key, "anonymize-key", "", "secret key for -anonymize, keeping replacements stable across runs (default: random per run)")
+}
+
+// anonymizer returns nil when anonymization is disabled.
+func (f *anonFlags) anonymizer() *anonymizer {
+	if !f.enabled {
+		return nil
+	}
+	if f.key == "" {
+		infof("anonFlags: no -anonymize-key: replacements are not stable across runs")
+	}
+	return newAnonymizer(f.key)
+}
+
+// sourceFlags select a live device to collect from.
+type sourceFlags struct {
+	command      string
+	devices      string
+	terminal     string
+	terminalCmd  string
+	terminalWait time.Duration
+	snmp         string
+	snmpOpts     snmpOptions
+	restconf     string
+	restconfOpts restconfOptions
+	gnmi         string
+	gnmiOpts     gnmiOptions
+}
+
+func (s *sourceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.command, "cmd", "", "collect by running shell command and parsing its output, e.g. \"ssh pe1 'show bgp vpnv4 unicast all neighbors'\"")
+	fs.StringVar(&s.devices, "devices", "", "collect over SSH from the -config inventory devices matching these comma-separated globs ('*' for all)")
+	fs.StringVar(&s.terminal, "terminal", "", "collect through an interactive session to telnet://[user@]host[:port] or ssh://[user@]host[:port], kept open between collections; passwords from $"+terminalPasswordEnv+" and $"+enablePasswordEnv)
+	fs.StringVar(&s.terminalCmd, "terminal-command", defaultSSHCommand, "command typed into the -terminal session")
+	fs.DurationVar(&s.terminalWait, "terminal-timeout", 30*time.Second, "-terminal wait for prompts and output")
+	fs.StringVar(&s.snmp, "snmp", "", "collect from host[:port] via SNMP (CISCO-BGP4-MIB)")
+	fs.StringVar(&s.snmpOpts.version, "snmp-version", "2c", "SNMP version: 2c or 3")
+	fs.StringVar(&s.snmpOpts.community, "snmp-community", "public", "SNMP v2c community")
+	fs.StringVar(&s.snmpOpts.user, "snmp-user", "", "SNMP v3 user")
+	fs.StringVar(&s.snmpOpts.authProto, "snmp-auth-proto", "", "SNMP v3 authentication protocol: MD5 or SHA")
+	fs.StringVar(&s.snmpOpts.authPass, "snmp-auth-pass", "", "SNMP v3 authentication passphrase")
+	fs.StringVar(&s.snmpOpts.privProto, "snmp-priv-proto", "AES", "SNMP v3 privacy protocol: AES")
+	fs.StringVar(&s.snmpOpts.privPass, "snmp-priv-pass", "", "SNMP v3 privacy passphrase (empty for no privacy)")
+	fs.DurationVar(&s.snmpOpts.timeout, "snmp-timeout", 5*time.Second, "SNMP request timeout")
+	fs.IntVar(&s.snmpOpts.retries, "snmp-retries", 2, "SNMP request retries")
+	fs.StringVar(&s.restconf, "restconf", "", "collect from https://host via RESTCONF (OpenConfig network-instance model)")
+	fs.StringVar(&s.restconfOpts.user, "restconf-user", "", "RESTCONF user")
+	fs.StringVar(&s.restconfOpts.pass, "restconf-pass", "", "RESTCONF password")
+	fs.BoolVar(&s.restconfOpts.insecure, "restconf-insecure", false, "skip RESTCONF TLS certificate verification")
+	fs.DurationVar(&s.restconfOpts.timeout, "restconf-timeout", 30*time.Second, "RESTCONF request timeout")
+	fs.StringVar(&s.gnmi, "gnmi", "", "collect from host[:port] via gNMI Get (OpenConfig network-instance model, default port "+gnmiDefaultPort+")")
+	fs.StringVar(&s.gnmiOpts.user, "gnmi-user", "", "gNMI user")
+	fs.StringVar(&s.gnmiOpts.pass, "gnmi-pass", "", "gNMI password")
+	fs.BoolVar(&s.gnmiOpts.insecure, "gnmi-insecure", false, "skip gNMI TLS certificate verification")
+	fs.BoolVar(&s.gnmiOpts.plaintext, "gnmi-plaintext", false, "gNMI over cleartext HTTP/2, without TLS")
+	fs.DurationVar(&s.gnmiOpts.timeout, "gnmi-timeout", 30*time.Second, "gNMI request timeout")
+}
+
+// device returns the collectFunc for the selected device, or nil if none.
+func (s *sourceFlags) device(opts parseOptions) collectFunc {
+	switch {
+	case s.snmp != "":
+		return func() (map[string]*neigh, error) { return snmpCollect(s.snmp, s.snmpOpts) }
+	case s.restconf != "":
+		return func() (map[string]*neigh, error) { return restconfCollect(s.restconf, s.restconfOpts) }
+	case s.gnmi != "":
+		return func() (map[string]*neigh, error) { return gnmiCollect(s.gnmi, s.gnmiOpts) }
+	case s.command != "":
+		return func() (map[string]*neigh, error) { return commandCollect(s.command, opts) }
+	case s.devices != "":
+		return func() (map[string]*neigh, error) { return inventoryCollect(s.devices, opts) }
+	case s.terminal != "":
+		var session *termSession
+		return func() (map[string]*neigh, error) {
+			if session == nil {
+				t, err := parseTerminalURL(s.terminal)
+				if err != nil {
+					return nil, err
+				}
+				t.command, t.timeout = s.terminalCmd, s.terminalWait
+				session = t
+			}
+			return session.collect(session.host, opts)
+		}
+	}
+	return nil
+}
+
+// collector returns the selected device, or else reads the capture files
+// or stdin. stdin reports that input comes from stdin, which is read only once.
+func (s *sourceFlags) collector(files []string, opts parseOptions) (collect collectFunc, stdin bool, err error) {
+	if collect := s.device(opts); collect != nil {
+		if len(files) > 0 || opts.backupDir != "" {
+			return nil, false, fmt.Errorf("collector: capture files or -backup-dir given with -cmd, -devices, -terminal, -snmp, -restconf or -gnmi: %v", files)
+		}
+		return enriched(collect, opts.enrich), false, nil
+	}
+	collect, stdin = inputCollector(files, opts)
+	return collect, stdin, nil
+}
+
+// inputCollector parses the capture files, or stdin if there are none.
+// With -backup-dir the files are globs selecting devices in the backup tree.
+func inputCollector(files []string, opts parseOptions) (collect collectFunc, stdin bool) {
+	switch {
+	case opts.backupDir != "":
+		collect = func() (map[string]*neigh, error) { return parseBackupDir(opts.backupDir, files, opts) }
+	case len(files) > 0:
+		collect = func() (map[string]*neigh, error) {
+			table, _, err := parseFiles(files, opts)
+			return table, err
+		}
+	default:
+		collect, stdin = func() (map[string]*neigh, error) {
+			table, _, err := parseInput(os.Stdin, "stdin", opts)
+			if err != nil {
+				return nil, fmt.Errorf("inputCollector: %v", err)
+			}
+			return table, nil
+		}, true
+	}
+	return enriched(collect, opts.enrich), stdin
+}
+
+const storeUsage = "record each collection in sqlite:history.db or postgres:connstring (uses the sqlite3 or psql client)"
+
+// hookFlags configure alerts, history recording and event publishing after each collection.
+type hookFlags struct {
+	rules      alertRules
+	webhook    string
+	exec       string
+	alertState string
+	store      string
+	publish    string
+	push       string
+	notify     string
+
+	keepPrevious bool // load and save -alert-state for the caller, see once
+
+	alerts    *alerter
+	db        *historyStore
+	publisher publisher
+	pusher    *metricsPusher
+	notifier  *notifier
+}
+
+func (h *hookFlags) register(fs *flag.FlagSet) {
+	fs.Var(&h.rules, "alert", "alert rule, may be repeated: 'state != Established', 'prefixes == 0', 'prefix_delta > 20%'")
+	fs.StringVar(&h.webhook, "alert-webhook", "", "POST alert JSON to URL")
+	fs.StringVar(&h.exec, "alert-exec", "", "run shell command with alert JSON on stdin")
+	fs.StringVar(&h.alertState, "alert-state", "", "file keeping the previous collection between runs, for prefix_delta and -notify without -watch")
+	fs.StringVar(&h.store, "store", "", storeUsage)
+	fs.StringVar(&h.publish, "publish", "", "publish neighbor change events as JSON to nats://host:4222/subject or kafka://restproxy:8082/topic (Kafka REST Proxy)")
+	fs.StringVar(&h.push, "push", "", "push neighbor metrics after each collection to prometheus+https://host/api/v1/write (remote write) or influx+https://host:8086/api/v2/write?org=O&bucket=B (line protocol); token from $"+pushTokenEnv)
+	fs.StringVar(&h.notify, "notify", "", "post session state changes to a chat webhook: slack+https://hooks.slack.com/services/... or teams+https://host/path")
+}
+
+func (h *hookFlags) setup() error {
+	if len(h.rules) > 0 {
+		h.alerts = newAlerter(h.rules, h.webhook, h.exec)
+	}
+	if h.store != "" {
+		db, err := parseStore(h.store)
+		if err != nil {
+			return err
+		}
+		h.db = db
+	}
+	if h.publish != "" {
+		p, err := parsePublisher(h.publish)
+		if err != nil {
+			return err
+		}
+		h.publisher = p
+	}
+	if h.push != "" {
+		p, err := parsePusher(h.push)
+		if err != nil {
+			return err
+		}
+		h.pusher = p
+	}
+	if h.notify != "" {
+		nt, err := parseNotifier(h.notify)
+		if err != nil {
+			return err
+		}
+		h.notifier = nt
+	}
+	return nil
+}
+
+// onCollect runs alerts, history recording and change publishing after each collection.
+func (h *hookFlags) onCollect(prev, curr map[string]*neigh) {
+	if h.alerts != nil {
+		h.alerts.evaluate(prev, curr)
+	}
+	if h.db != nil {
+		if err := h.db.save(curr, time.Now()); err != nil {
+			errorf("hookFlags.onCollect: %v", err)
+		}
+	}
+	if h.publisher != nil {
+		publishChanges(h.publisher, prev, curr)
+	}
+	if h.pusher != nil {
+		if err := h.pusher.push(neighborList(curr), time.Now()); err != nil {
+			errorf("hookFlags.onCollect: %v", err)
+		}
+	}
+	if h.notifier != nil {
+		h.notifier.notifyChanges(prev, curr)
+	}
+}
+
+// once runs the hooks for a single collection, keeping the previous
+// collection in -alert-state between runs. It returns the previous
+// collection, nil on the first run or without -alert-state.
+func (h *hookFlags) once(table map[string]*neigh) (map[string]*neigh, error) {
+	keepState := h.alertState != "" && (h.alerts != nil || h.notifier != nil || h.keepPrevious)
+	var prev map[string]*neigh
+	if keepState {
+		var err error
+		if prev, err = loadTableJSON(h.alertState); err != nil {
+			return nil, err
+		}
+		if h.alerts != nil {
+			h.alerts.prime(prev)
+		}
+	}
+	h.onCollect(prev, table)
+	if keepState {
+		return prev, saveTableJSON(h.alertState, table)
+	}
+	return prev, nil
+}
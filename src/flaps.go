@@ -0,0 +1,156 @@
+package main
+
+// flap detection across runs (history store or a directory of JSON
+// tables). A neighbor was reset between two runs when it was Established
+// in the first and, in the second, is down or its uptime restarted, so
+// flaps that recovered before the next collection still show.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type flapEntry struct {
+	Device    string    `json:"device,omitempty"`
+	Addr      string    `json:"addr"`
+	VRF       string    `json:"vrf"`
+	RemoteAS  string    `json:"remote_as"`
+	State     string    `json:"state"`
+	Uptime    string    `json:"uptime"`
+	Resets    int       `json:"resets"`
+	LastReset time.Time `json:"last_reset"` // estimated from the uptime when back up
+}
+
+// loadRuns loads the runs since the given time from a history store spec
+// (sqlite:path, postgres:connstring) or a directory of JSON tables
+// (parse -json), each taken at its file modification time.
+func loadRuns(spec string, since time.Time) ([]*historyRun, error) {
+	if strings.HasPrefix(spec, "sqlite:") || strings.HasPrefix(spec, "postgres") {
+		s, err := parseStore(spec)
+		if err != nil {
+			return nil, fmt.Errorf("loadRuns: %v", err)
+		}
+		runs, err := s.runsSince(since)
+		if err != nil {
+			return nil, fmt.Errorf("loadRuns: %v", err)
+		}
+		return runs, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(spec, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("loadRuns: %v", err)
+	}
+	if files == nil {
+		if info, err := os.Stat(spec); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("loadRuns: expecting history store or directory of JSON tables: [%s]", spec)
+		}
+	}
+	var runs []*historyRun
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("loadRuns: %v", err)
+		}
+		if info.ModTime().Before(since) {
+			continue
+		}
+		table, err := loadTableJSON(f)
+		if err != nil {
+			return nil, fmt.Errorf("loadRuns: %v", err)
+		}
+		runs = append(runs, &historyRun{at: info.ModTime(), table: table})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].at.Before(runs[j].at) })
+	return runs, nil
+}
+
+// newFlapReport counts the resets of every neighbor in list across runs
+// followed by list itself, taken at now. Neighbors without resets are
+// not reported; the most reset come first.
+func newFlapReport(runs []*historyRun, list []*neigh, now time.Time) []*flapEntry {
+	current := map[string]*neigh{}
+	for _, n := range list {
+		current[neighKey(n)] = n
+	}
+	runs = append(runs, &historyRun{at: now, table: current})
+
+	var entries []*flapEntry
+	for _, n := range list {
+		key := neighKey(n)
+		e := &flapEntry{Device: n.Device, Addr: n.Addr, VRF: n.VRF, RemoteAS: n.RemoteAS, State: n.State, Uptime: n.Uptime}
+		var prev *neigh
+		var prevAt time.Time
+		for _, r := range runs {
+			curr, found := r.table[key]
+			if !found {
+				continue
+			}
+			if prev != nil && !r.at.After(prevAt) {
+				continue // the current collection may be in the store already
+			}
+			if prev != nil && prev.State == "Established" {
+				switch {
+				case curr.State != "Established":
+					e.Resets++
+					e.LastReset = r.at
+				case uptimeRestarted(prev, curr, r.at.Sub(prevAt)):
+					e.Resets++
+					e.LastReset = r.at.Add(-time.Duration(*curr.UptimeSeconds) * time.Second)
+				}
+			}
+			prev, prevAt = curr, r.at
+		}
+		if e.Resets > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Resets != entries[j].Resets {
+			return entries[i].Resets > entries[j].Resets
+		}
+		return entries[i].LastReset.After(entries[j].LastReset)
+	})
+	return entries
+}
+
+// uptimeRestarted reports whether curr has been up for less than the time
+// elapsed since prev, or has lost uptime since prev. Uptimes printed in
+// weeks and days are truncated to the day, hence the slack.
+func uptimeRestarted(prev, curr *neigh, elapsed time.Duration) bool {
+	if curr.UptimeSeconds == nil {
+		return false
+	}
+	up := time.Duration(*curr.UptimeSeconds) * time.Second
+	if up < elapsed {
+		return true
+	}
+	return prev.UptimeSeconds != nil && up+24*time.Hour < time.Duration(*prev.UptimeSeconds)*time.Second+elapsed
+}
+
+func writeFlaps(w io.Writer, entries []*flapEntry, runs int, window time.Duration) {
+	fmt.Fprintf(w, "%d neighbors reset in the last %v (%d runs)\n", len(entries), window, runs)
+	fmt.Fprintf(w, "%-12s %-15s %-14s %-10s %-12s %-10s %6s %s\n", "Device", "Neighbor", "VRF", "ASN", "State", "Uptime", "Resets", "Last reset")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%-12s %-15s %-14s %-10s %-12s %-10s %6d %s\n", e.Device, e.Addr, e.VRF, e.RemoteAS, e.State, e.Uptime, e.Resets, e.LastReset.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func writeFlapsJSON(w io.Writer, entries []*flapEntry) error {
+	if entries == nil {
+		entries = []*flapEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		return fmt.Errorf("writeFlapsJSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package main
+
+// collect neighbors via gNMI Get from the OpenConfig network-instance
+// model, as served by IOS-XE 17, IOS-XR and NX-OS, with the gRPC framing
+// and protobuf helpers of grpc.go:
+//
+//	/gnmi.gNMI/Get  path openconfig:/network-instances/network-instance/protocols/protocol/bgp/neighbors
+//	                type STATE, encoding JSON_IETF
+//
+// Devices answer with the neighbors container of each network instance or
+// with one update per neighbor; both decode into the RESTCONF types.
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	gnmiDefaultPort = "9339" // IANA gNMI port
+	gnmiGetMethod   = "/gnmi.gNMI/Get"
+	gnmiMaxMessage  = 256 << 20 // neighbors of a big route reflector
+)
+
+// gNMI enums
+const (
+	gnmiDataTypeState    = 2
+	gnmiEncodingJSONIETF = 4
+)
+
+// gnmiNeighborsPath is requested without keys, a wildcard for every
+// network instance and protocol.
+var gnmiNeighborsPath = []string{"network-instances", "network-instance", "protocols", "protocol", "bgp", "neighbors"}
+
+type gnmiOptions struct {
+	user      string
+	pass      string
+	insecure  bool // skip TLS certificate verification
+	plaintext bool // cleartext HTTP/2, without TLS
+	timeout   time.Duration
+}
+
+// gnmiPathElem is a gNMI path element, e.g. network-instance[name=CUST-A].
+type gnmiPathElem struct {
+	name string
+	keys map[string]string
+}
+
+// gnmiCollect sends a Get for the BGP neighbors to target (host[:port])
+// and returns the same table the text parser would.
+func gnmiCollect(target string, opts gnmiOptions) (map[string]*neigh, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, gnmiDefaultPort)
+	}
+	var protocols http.Protocols
+	scheme := "https"
+	if opts.plaintext {
+		protocols.SetUnencryptedHTTP2(true)
+		scheme = "http"
+	} else {
+		protocols.SetHTTP2(true)
+	}
+	url := scheme + "://" + target + gnmiGetMethod
+
+	debugf("gnmiCollect: fetching %s", url)
+
+	var body bytes.Buffer
+	msg := gnmiGetRequest()
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	body.Write(prefix[:])
+	body.Write(msg)
+
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if opts.user != "" {
+		req.Header.Set("username", opts.user) // gNMI authentication metadata
+		req.Header.Set("password", opts.pass)
+	}
+
+	transport := &http.Transport{Protocols: &protocols}
+	if opts.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Timeout: opts.timeout, Transport: transport}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gnmiCollect: %s: %s", url, resp.Status)
+	}
+	if err := gnmiStatus(resp.Header); err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %s: %v", url, err) // trailers-only response
+	}
+	reply, err := readGRPCMessage(resp.Body, gnmiMaxMessage)
+	if err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %s: %v", url, err)
+	}
+	io.Copy(io.Discard, resp.Body) // trailers follow the body
+	if err := gnmiStatus(resp.Trailer); err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %s: %v", url, err)
+	}
+	if reply == nil {
+		return nil, fmt.Errorf("gnmiCollect: %s: empty response", url)
+	}
+
+	table, err := parseGNMIResponse(reply, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("gnmiCollect: %v", err)
+	}
+
+	infof("gnmiCollect: found %d neighbors", len(table))
+
+	return table, nil
+}
+
+// gnmiStatus returns the error of a non-zero grpc-status, if present.
+func gnmiStatus(h http.Header) error {
+	status := h.Get("Grpc-Status")
+	if status == "" || status == "0" {
+		return nil
+	}
+	return fmt.Errorf("grpc-status %s: %s", status, h.Get("Grpc-Message"))
+}
+
+// gnmiGetRequest encodes the GetRequest for gnmiNeighborsPath.
+func gnmiGetRequest() []byte {
+	var path []byte
+	path = protoAppendString(path, 2, "openconfig") // origin
+	for _, name := range gnmiNeighborsPath {
+		path = protoAppendMessage(path, 3, protoAppendString(nil, 1, name))
+	}
+	var req []byte
+	req = protoAppendMessage(req, 2, path)
+	req = protoAppendVarint(req, 3, gnmiDataTypeState)
+	req = protoAppendVarint(req, 5, gnmiEncodingJSONIETF)
+	return req
+}
+
+// parseGNMIResponse decodes the notifications of a GetResponse.
+func parseGNMIResponse(msg []byte, now time.Time) (map[string]*neigh, error) {
+	table := map[string]*neigh{}
+	var errs []error
+	err := protoFields(msg, func(field, wire int, v uint64, b []byte) {
+		if field == 1 && wire == 2 { // notification
+			if err := parseGNMINotification(table, b, now); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	if err == nil && len(errs) > 0 {
+		err = errs[0]
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parseGNMIResponse: %v", err)
+	}
+	return table, nil
+}
+
+func parseGNMINotification(table map[string]*neigh, msg []byte, now time.Time) error {
+	var prefix []gnmiPathElem
+	var updates [][]byte
+	var errs []error
+	err := protoFields(msg, func(field, wire int, v uint64, b []byte) {
+		switch {
+		case field == 2 && wire == 2:
+			p, err := parseGNMIPath(b)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			prefix = p
+		case field == 4 && wire == 2:
+			updates = append(updates, b)
+		}
+	})
+	if err == nil && len(errs) > 0 {
+		err = errs[0]
+	}
+	if err != nil {
+		return fmt.Errorf("notification: %v", err)
+	}
+	for _, u := range updates {
+		var path []gnmiPathElem
+		var value []byte
+		err := protoFields(u, func(field, wire int, v uint64, b []byte) {
+			switch {
+			case field == 1 && wire == 2:
+				p, err := parseGNMIPath(b)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				path = p
+			case field == 3 && wire == 2:
+				value = gnmiJSONValue(b)
+			}
+		})
+		if err == nil && len(errs) > 0 {
+			err = errs[0]
+		}
+		if err != nil {
+			return fmt.Errorf("update: %v", err)
+		}
+		full := append(append([]gnmiPathElem(nil), prefix...), path...)
+		if err := gnmiUpdate(table, full, value, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseGNMIPath decodes the elements of a Path.
+func parseGNMIPath(msg []byte) ([]gnmiPathElem, error) {
+	var elems []gnmiPathElem
+	err := protoFields(msg, func(field, wire int, v uint64, b []byte) {
+		if field != 3 || wire != 2 {
+			return // origin, target, deprecated element
+		}
+		e := gnmiPathElem{keys: map[string]string{}}
+		protoFields(b, func(field, wire int, v uint64, b []byte) {
+			switch {
+			case field == 1 && wire == 2:
+				e.name = string(b)
+			case field == 2 && wire == 2: // map entry
+				var k, v string
+				protoFields(b, func(field, wire int, _ uint64, b []byte) {
+					switch field {
+					case 1:
+						k = string(b)
+					case 2:
+						v = string(b)
+					}
+				})
+				e.keys[k] = v
+			}
+		})
+		elems = append(elems, e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("path: %v", err)
+	}
+	return elems, nil
+}
+
+// gnmiJSONValue returns the json_ietf_val or json_val of a TypedValue, nil
+// for other encodings.
+func gnmiJSONValue(msg []byte) []byte {
+	var value []byte
+	protoFields(msg, func(field, wire int, v uint64, b []byte) {
+		if (field == 10 || field == 11) && wire == 2 {
+			value = b
+		}
+	})
+	return value
+}
+
+// gnmiUpdate adds the neighbors of the update at path to table: a
+// neighbors container, or a single neighbor.
+func gnmiUpdate(table map[string]*neigh, path []gnmiPathElem, value []byte, now time.Time) error {
+	vrf := vrfDefault
+	for _, e := range path {
+		if e.name == "network-instance" && e.keys["name"] != "" {
+			vrf = e.keys["name"]
+		}
+	}
+	if len(path) == 0 || value == nil {
+		return nil
+	}
+	last := path[len(path)-1]
+
+	var list []ocNeighbor
+	switch last.name {
+	case "neighbors":
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(value, &doc); err != nil {
+			return fmt.Errorf("gnmiUpdate: neighbors: %v", err)
+		}
+		if member := ocMember(doc, "neighbor"); member != nil {
+			if err := json.Unmarshal(member, &list); err != nil {
+				return fmt.Errorf("gnmiUpdate: neighbors: %v", err)
+			}
+		}
+	case "neighbor":
+		var on ocNeighbor
+		if err := json.Unmarshal(value, &on); err != nil {
+			return fmt.Errorf("gnmiUpdate: neighbor: %v", err)
+		}
+		if on.Address == "" {
+			on.Address = last.keys["neighbor-address"]
+		}
+		list = append(list, on)
+	default:
+		debugf("gnmiUpdate: skipping update at %s", last.name)
+		return nil
+	}
+	for i := range list {
+		n := list[i].neigh(vrf, now)
+		table[neighKey(n)] = n
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gnmiTestPath encodes a Path from elements and their keys.
+func gnmiTestPath(elems ...gnmiPathElem) []byte {
+	var path []byte
+	for _, e := range elems {
+		elem := protoAppendString(nil, 1, e.name)
+		for k, v := range e.keys {
+			elem = protoAppendMessage(elem, 2, protoAppendString(protoAppendString(nil, 1, k), 2, v))
+		}
+		path = protoAppendMessage(path, 3, elem)
+	}
+	return path
+}
+
+func gnmiTestUpdate(path []byte, json string) []byte {
+	u := protoAppendMessage(nil, 1, path)
+	return protoAppendMessage(u, 3, protoAppendString(nil, 11, json))
+}
+
+// serveGNMITest answers Get with reply, or with grpc-status code if not 0.
+func serveGNMITest(t *testing.T, reply []byte, code int) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Protocols: &protocols,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != gnmiGetMethod || r.Header.Get("username") != "monitor" {
+				code = grpcUnimplemented
+			}
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			if code == grpcOK {
+				writeGRPCMessage(w, reply)
+			}
+			w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+		}),
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestGNMICollect(t *testing.T) {
+	vrf := func(name string) []gnmiPathElem {
+		return []gnmiPathElem{
+			{name: "network-instances"},
+			{name: "network-instance", keys: map[string]string{"name": name}},
+			{name: "protocols"},
+			{name: "protocol", keys: map[string]string{"identifier": "BGP", "name": "bgp"}},
+			{name: "bgp"},
+		}
+	}
+
+	// neighbors container of CUST-A
+	custA := protoAppendMessage(nil, 2, gnmiTestPath(vrf("CUST-A")...))
+	custA = protoAppendMessage(custA, 4, gnmiTestUpdate(gnmiTestPath(gnmiPathElem{name: "neighbors"}),
+		`{"openconfig-network-instance:neighbor":[{"neighbor-address":"198.51.100.1","state":{"peer-as":65001,"session-state":"ESTABLISHED","description":"CUST-A-1"},`+
+			`"afi-safis":{"afi-safi":[{"state":{"prefixes":{"received":"12"}}},{"state":{"prefixes":{"received":3}}}]}}]}`))
+
+	// one update per neighbor of the default instance, shut down
+	global := protoAppendMessage(nil, 2, gnmiTestPath(vrf("default")...))
+	global = protoAppendMessage(global, 4, gnmiTestUpdate(gnmiTestPath(gnmiPathElem{name: "neighbors"},
+		gnmiPathElem{name: "neighbor", keys: map[string]string{"neighbor-address": "10.0.0.2"}}),
+		`{"state":{"peer-as":"64512","session-state":"openconfig-bgp-types:IDLE","enabled":false}}`))
+
+	reply := protoAppendMessage(protoAppendMessage(nil, 1, custA), 1, global)
+	addr := serveGNMITest(t, reply, grpcOK)
+
+	table, err := gnmiCollect(addr, gnmiOptions{user: "monitor", pass: "s3cret", plaintext: true, timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 2 {
+		t.Fatalf("got %d neighbors, want 2", len(table))
+	}
+	a := table[tableKey("", "198.51.100.1", "CUST-A")]
+	if a == nil || a.RemoteAS != "65001" || a.State != "Established" || a.Prefixes != 15 || a.Description != "CUST-A-1" || a.Shutdown {
+		t.Errorf("CUST-A neighbor: %+v", a)
+	}
+	b := table[tableKey("", "10.0.0.2", "default")]
+	if b == nil || b.RemoteAS != "64512" || b.State != "Idle" || !b.Shutdown {
+		t.Errorf("default neighbor: %+v", b)
+	}
+}
+
+func TestGNMICollectStatus(t *testing.T) {
+	addr := serveGNMITest(t, nil, 7) // PERMISSION_DENIED
+	if _, err := gnmiCollect(addr, gnmiOptions{user: "monitor", plaintext: true, timeout: 5 * time.Second}); err == nil {
+		t.Error("want error for grpc-status 7")
+	}
+}
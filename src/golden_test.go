@@ -0,0 +1,161 @@
+package main
+
+// golden file test: every capture in testdata/ is parsed as
+// "parse -json < capture" would, with the auto dialect, and compared
+// against the expected output <capture>.json next to it. goldenStreams
+// are parsed as "parse -stream -json" with filters.
+//
+//	go test -run TestGolden          # compare
+//	go test -run TestGolden -update  # rewrite after a reviewed parser change
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/")
+
+// TestMain quiets the parser log, but with -v.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Verbose() {
+		setupLogging("error", "text", false)
+	}
+	os.Exit(m.Run())
+}
+
+func TestGolden(t *testing.T) {
+	captures, err := filepath.Glob("testdata/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) == 0 {
+		t.Fatal("no captures in testdata/")
+	}
+	for _, capture := range captures {
+		capture := capture
+		t.Run(filepath.Base(capture), func(t *testing.T) {
+			out, platform := parseGolden(t, capture)
+			checkGolden(t, strings.TrimSuffix(capture, ".txt")+".json", out)
+			if want := goldenPlatform(filepath.Base(capture)); platform != want {
+				t.Errorf("detected platform %s, want %s", platform, want)
+			}
+		})
+	}
+}
+
+// goldenStreams are parsed with -stream, written one JSON object per line.
+var goldenStreams = []struct {
+	capture string
+	filter  filterFlags
+	golden  string
+}{
+	{"ios-rr-multi-afi-neighbors.txt", filterFlags{afi: "vpnv4"}, "ios-rr-multi-afi-neighbors.stream-afi-vpnv4.jsonl"},
+	{"ios-rr-multi-afi-neighbors.txt", filterFlags{afi: "ipv6,l2vpn-evpn"}, "ios-rr-multi-afi-neighbors.stream-afi-ipv6-evpn.jsonl"},
+}
+
+func TestGoldenStream(t *testing.T) {
+	for _, g := range goldenStreams {
+		g := g
+		t.Run(g.golden, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", g.capture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			g.filter.sort, g.filter.shutdown = "vrf,addr", "include"
+			filter, _, err := g.filter.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := parseOptions{dialect: dialects[dialectAuto], dialectName: dialectAuto}
+			var out bytes.Buffer
+			if err := streamOutput(f, &out, opts, nil, filter, true, false, false); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", g.golden), out.Bytes())
+		})
+	}
+}
+
+// goldenPlatform returns the platform to detect in a capture from its name
+// prefix, e.g. nxos-bgp-vrf-all-neighbors.txt. IOS-XE captures without a
+// banner are reported as ios.
+func goldenPlatform(name string) string {
+	switch strings.SplitN(name, "-", 2)[0] {
+	case "eos":
+		return platformEOS
+	case "iosxr":
+		return platformIOSXR
+	case "nxos":
+		return platformNXOS
+	}
+	return platformIOS
+}
+
+// parseGolden returns the JSON output of parsing capture from stdin, and
+// the platform detected.
+func parseGolden(t *testing.T, capture string) ([]byte, string) {
+	f, err := os.Open(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var platform string
+	opts := parseOptions{dialect: dialects[dialectAuto], dialectName: dialectAuto}
+	opts.report = func(r captureReport) { platform = r.Platform }
+	table, _, err := parseInput(f, "stdin", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseSortKeys("vrf,addr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := neighborList(table)
+	sortNeighbors(list, keys)
+	var out bytes.Buffer
+	if err := writeJSON(&out, list); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes(), platform
+}
+
+// checkGolden compares got with the golden file, or rewrites it with -update.
+func checkGolden(t *testing.T, golden string, got []byte) {
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (review, then go test -update):\n%s", golden, lineDiff(want, got))
+	}
+}
+
+// lineDiff lists the lines of want and got from the first one differing.
+func lineDiff(want, got []byte) string {
+	w := strings.Split(string(want), "\n")
+	g := strings.Split(string(got), "\n")
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
+	var b strings.Builder
+	for j := i; j < len(w) && j < i+10; j++ {
+		b.WriteString("- " + w[j] + "\n")
+	}
+	for j := i; j < len(g) && j < i+10; j++ {
+		b.WriteString("+ " + g[j] + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+// graceful restart state:
+//
+//	    Graceful Restart Capability: advertised and received
+//	      Remote Restart timer is 120 seconds
+//	  Graceful-Restart is enabled, restart-time 120 seconds, stalepath-time 360 seconds
+//	  Stalepath timer is running, expires in 00:05:40
+
+import (
+	"fmt"
+	"strings"
+)
+
+type grState struct {
+	Enabled           bool `json:"enabled"`                       // configured locally
+	Negotiated        bool `json:"negotiated"`                    // capability advertised and received
+	RestartTime       int  `json:"restart_time,omitempty"`        // seconds
+	StalepathTime     int  `json:"stalepath_time,omitempty"`      // seconds
+	RemoteRestartTime int  `json:"remote_restart_time,omitempty"` // seconds, advertised by the peer
+	InRestart         bool `json:"in_restart,omitempty"`          // restart or stalepath timer running
+}
+
+func (n *neigh) grState() *grState {
+	if n.GracefulRestart == nil {
+		n.GracefulRestart = &grState{}
+	}
+	return n.GracefulRestart
+}
+
+// parseGRLine records a graceful restart line.
+// It returns false when line is not about graceful restart.
+func parseGRLine(n *neigh, line string) bool {
+	s := strings.TrimSpace(line)
+	if strings.HasPrefix(s, "Graceful-Restart is ") {
+		gr := n.grState()
+		gr.Enabled = strings.HasPrefix(s, "Graceful-Restart is enabled")
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			fmt.Sscanf(part, "restart-time %d", &gr.RestartTime)
+			fmt.Sscanf(part, "stalepath-time %d", &gr.StalepathTime)
+		}
+		return true
+	}
+	lower := strings.ToLower(s)
+	if lineIndent(line) == 2 && strings.Contains(lower, "timer") && strings.Contains(lower, "running") &&
+		(strings.Contains(lower, "restart") || strings.Contains(lower, "stalepath") || strings.Contains(lower, "stale path")) {
+		n.grState().InRestart = true
+		return true
+	}
+	return false
+}
+
+// parseGRCapabilityLine parses the detail lines under the graceful restart capability.
+func parseGRCapabilityLine(n *neigh, line string) {
+	var secs int
+	if _, err := fmt.Sscanf(strings.TrimSpace(line), "Remote Restart timer is %d seconds", &secs); err == nil {
+		n.grState().RemoteRestartTime = secs
+	}
+}
+
+// finishGR fills Negotiated from the capabilities once the block is complete.
+func (n *neigh) finishGR() {
+	negotiated := n.hasCapability("Graceful Restart")
+	if n.GracefulRestart == nil && !negotiated {
+		return
+	}
+	n.grState().Negotiated = negotiated
+}
+
+func grSeconds(n *neigh, get func(gr *grState) int) string {
+	if n.GracefulRestart == nil || get(n.GracefulRestart) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%ds", get(n.GracefulRestart))
+}
+
+// grStatus summarizes graceful restart: restarting, negotiated, enabled or no.
+func grStatus(n *neigh) string {
+	gr := n.GracefulRestart
+	switch {
+	case gr == nil:
+		return "no"
+	case gr.InRestart:
+		return "restarting"
+	case gr.Negotiated:
+		return "negotiated"
+	case gr.Enabled:
+		return "enabled"
+	}
+	return "no"
+}
@@ -0,0 +1,332 @@
+package main
+
+// gRPC API over the collected neighbor table, defined in neighbors.proto:
+// /bgpneigh.v1.NeighborService/ListNeighbors
+// /bgpneigh.v1.NeighborService/StreamChanges
+// Served over cleartext HTTP/2 (h2c) by net/http, with the few protobuf
+// messages encoded by hand to keep the tool free of generated code.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const grpcService = "/bgpneigh.v1.NeighborService/"
+
+// gRPC status codes
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcUnimplemented   = 12
+	grpcInternal        = 13
+	grpcUnavailable     = 14
+)
+
+const grpcMaxMessage = 4 << 20
+
+// NeighborChange.Kind
+var grpcChangeKinds = map[string]uint64{"added": 1, "removed": 2, "changed": 3}
+
+func serveGRPC(addr string, s *apiServer) error {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true) // gRPC clients use prior knowledge h2c
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   http.HandlerFunc(s.handleGRPC),
+		Protocols: &protocols,
+	}
+	return srv.ListenAndServe()
+}
+
+func (s *apiServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires POST over HTTP/2", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc+proto")
+
+	var code int
+	var err error
+	switch r.URL.Path {
+	case grpcService + "ListNeighbors":
+		code, err = s.grpcListNeighbors(w, r)
+	case grpcService + "StreamChanges":
+		code, err = s.grpcStreamChanges(w, r)
+	default:
+		code, err = grpcUnimplemented, fmt.Errorf("unknown method: %s", r.URL.Path)
+	}
+
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if err != nil {
+		debugf("apiServer.handleGRPC: %s: %v", r.URL.Path, err)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(err.Error()))
+	}
+}
+
+// grpcPercentEncode escapes grpc-message as the gRPC spec requires.
+func grpcPercentEncode(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (s *apiServer) grpcListNeighbors(w http.ResponseWriter, r *http.Request) (int, error) {
+	req, err := readGRPCMessage(r.Body, grpcMaxMessage)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	filter, device, _, err := grpcRequestFilter(req)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	list, collected, err := s.neighbors(filter, device)
+	if err != nil {
+		return grpcUnavailable, err
+	}
+
+	var resp []byte
+	for _, n := range list {
+		resp = protoAppendMessage(resp, 1, neighborProto(n))
+	}
+	resp = protoAppendString(resp, 2, collected.UTC().Format(time.RFC3339))
+
+	if err := writeGRPCMessage(w, resp); err != nil {
+		return grpcInternal, err
+	}
+	return grpcOK, nil
+}
+
+func (s *apiServer) grpcStreamChanges(w http.ResponseWriter, r *http.Request) (int, error) {
+	req, err := readGRPCMessage(r.Body, grpcMaxMessage)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	filter, device, initial, err := grpcRequestFilter(req)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	match := func(n *neigh) bool { return filter.match(n) && (device == nil || device(n)) }
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush()
+
+	if initial {
+		list, collected, err := s.neighbors(filter, device)
+		if err != nil {
+			return grpcUnavailable, err
+		}
+		for _, n := range list {
+			if err := writeGRPCMessage(w, changeProto(&neighChange{Kind: "added", Neigh: n}, collected)); err != nil {
+				return grpcInternal, err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return grpcOK, nil // client went away
+		case e := <-ch:
+			for _, c := range e.changes {
+				if !match(c.Neigh) && (c.Prev == nil || !match(c.Prev)) {
+					continue
+				}
+				if err := writeGRPCMessage(w, changeProto(c, e.collected)); err != nil {
+					return grpcInternal, err
+				}
+			}
+		}
+	}
+}
+
+// grpcRequestFilter decodes the filter fields shared by ListNeighborsRequest
+// and StreamChangesRequest.
+func grpcRequestFilter(req []byte) (*neighFilter, func(n *neigh) bool, bool, error) {
+	var vrf, state, asn, deviceName string
+	var initial bool
+	err := protoFields(req, func(field int, wire int, v uint64, b []byte) {
+		switch {
+		case field == 1 && wire == 2:
+			vrf = string(b)
+		case field == 2 && wire == 2:
+			state = string(b)
+		case field == 3 && wire == 2:
+			asn = string(b)
+		case field == 4 && wire == 2:
+			deviceName = string(b)
+		case field == 5 && wire == 0:
+			initial = v != 0
+		}
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	filter, err := newNeighFilter(vrf, state, asn)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	var device func(n *neigh) bool
+	if deviceName != "" {
+		device = func(n *neigh) bool { return n.Device == deviceName }
+	}
+	return filter, device, initial, nil
+}
+
+// readGRPCMessage reads one length-prefixed message of up to maxSize bytes:
+// 1 byte compressed flag, 4 bytes big-endian length, message.
+func readGRPCMessage(r io.Reader, maxSize uint32) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil // empty request
+		}
+		return nil, fmt.Errorf("readGRPCMessage: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("readGRPCMessage: compressed messages not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxSize {
+		return nil, fmt.Errorf("readGRPCMessage: message too large: %d bytes", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("readGRPCMessage: %v", err)
+	}
+	return msg, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(append(prefix[:], msg...)); err != nil {
+		return fmt.Errorf("writeGRPCMessage: %v", err)
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return fmt.Errorf("writeGRPCMessage: %v", err)
+	}
+	return nil
+}
+
+func neighborProto(n *neigh) []byte {
+	var b []byte
+	b = protoAppendString(b, 1, n.Device)
+	b = protoAppendString(b, 2, n.Addr)
+	b = protoAppendString(b, 3, n.VRF)
+	b = protoAppendString(b, 4, n.RemoteAS)
+	b = protoAppendString(b, 5, n.State)
+	b = protoAppendString(b, 6, n.Uptime)
+	if n.UptimeSeconds != nil {
+		b = protoAppendTag(b, 7, 0)
+		b = binary.AppendUvarint(b, uint64(*n.UptimeSeconds))
+	}
+	b = protoAppendVarint(b, 8, uint64(n.Prefixes))
+	b = protoAppendString(b, 9, n.Description)
+	b = protoAppendString(b, 10, n.RouterID)
+	b = protoAppendString(b, 11, n.LocalAS)
+	b = protoAppendString(b, 12, n.PeerGroup)
+	if n.Dynamic {
+		b = protoAppendVarint(b, 13, 1)
+	}
+	b = protoAppendString(b, 14, n.LastReset)
+	b = protoAppendString(b, 15, n.ResetReason)
+	return b
+}
+
+func changeProto(c *neighChange, collected time.Time) []byte {
+	var b []byte
+	b = protoAppendVarint(b, 1, grpcChangeKinds[c.Kind])
+	b = protoAppendMessage(b, 2, neighborProto(c.Neigh))
+	if c.Prev != nil {
+		b = protoAppendMessage(b, 3, neighborProto(c.Prev))
+	}
+	b = protoAppendString(b, 4, collected.UTC().Format(time.RFC3339))
+	return b
+}
+
+func protoAppendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+// protoAppendVarint skips zero values, as proto3 does.
+func protoAppendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(protoAppendTag(b, field, 0), v)
+}
+
+func protoAppendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(protoAppendTag(b, field, 2), uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoAppendString(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return protoAppendBytes(b, field, []byte(v))
+}
+
+func protoAppendMessage(b []byte, field int, msg []byte) []byte {
+	return protoAppendBytes(b, field, msg)
+}
+
+// protoFields calls fn for each field of a protobuf message: v holds
+// varints (wire type 0), b the payload of length-delimited fields (wire
+// type 2). Fixed-size fields are skipped.
+func protoFields(msg []byte, fn func(field, wire int, v uint64, b []byte)) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errors.New("protoFields: bad tag")
+		}
+		msg = msg[n:]
+		field, wire := int(tag>>3), int(tag&7)
+		switch wire {
+		case 0:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return fmt.Errorf("protoFields: field %d: bad varint", field)
+			}
+			msg = msg[n:]
+			fn(field, wire, v, nil)
+		case 1, 5:
+			size := 8
+			if wire == 5 {
+				size = 4
+			}
+			if len(msg) < size {
+				return fmt.Errorf("protoFields: field %d: truncated", field)
+			}
+			msg = msg[size:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return fmt.Errorf("protoFields: field %d: bad length", field)
+			}
+			msg = msg[n:]
+			fn(field, wire, 0, msg[:size])
+			msg = msg[size:]
+		default:
+			return fmt.Errorf("protoFields: field %d: unsupported wire type %d", field, wire)
+		}
+	}
+	return nil
+}
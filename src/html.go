@@ -0,0 +1,147 @@
+package main
+
+// standalone HTML report: no external assets, sorting and filtering in embedded JS.
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+type htmlCell struct {
+	Value string
+	Sort  string // sort key, numeric when the column is numeric
+	Right bool
+}
+
+type htmlRow struct {
+	State string // css class
+	Cells []htmlCell
+}
+
+type htmlReport struct {
+	Generated string
+	Total     int
+	Headers   []string
+	Rows      []htmlRow
+	VRFs      []*groupStat
+}
+
+// htmlStateClass color-codes a neighbor state. Neighbors shut down on
+// purpose are not colored as down.
+func htmlStateClass(n *neigh) string {
+	if n.Shutdown {
+		return "shutdown"
+	}
+	switch n.State {
+	case "Established":
+		return "up"
+	case "Idle", "Active", "Idle (PfxCt)":
+		return "down"
+	}
+	return "other"
+}
+
+func htmlSortKey(c *column, n *neigh) string {
+	if c.name == "uptime" {
+		if n.UptimeSeconds == nil {
+			return "-1"
+		}
+		return uptimeSeconds(n)
+	}
+	return c.value(n)
+}
+
+func writeHTML(w io.Writer, cols []*column, list []*neigh, now time.Time) error {
+	report := htmlReport{
+		Generated: now.Format(time.RFC3339),
+		Total:     len(list),
+		VRFs:      vrfStats(list),
+	}
+	for _, c := range cols {
+		report.Headers = append(report.Headers, c.header)
+	}
+	for _, n := range list {
+		row := htmlRow{State: htmlStateClass(n)}
+		for _, c := range cols {
+			row.Cells = append(row.Cells, htmlCell{Value: c.value(n), Sort: htmlSortKey(c, n), Right: c.right})
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if err := htmlTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("writeHTML: %v", err)
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>BGP neighbors</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; }
+th { background: #eee; }
+#neighbors th { cursor: pointer; }
+td.r { text-align: right; }
+tr.up td { background: #e6f4e6; }
+tr.down td { background: #f8dddd; }
+tr.other td { background: #fdf1d6; }
+tr.shutdown td { background: #eee; color: #777; }
+</style>
+</head>
+<body>
+<h1>BGP neighbors</h1>
+<p>Generated {{.Generated}}, {{.Total}} neighbors.</p>
+
+<h2>VRF summary</h2>
+<table>
+<tr><th>VRF</th><th>Neighbors</th><th>Established</th><th>Prefixes</th></tr>
+{{range .VRFs}}<tr><td>{{.Name}}</td><td class="r">{{.Neighbors}}</td><td class="r">{{.Established}}</td><td class="r">{{.Prefixes}}</td></tr>
+{{end}}</table>
+
+<h2>Neighbors</h2>
+<p>Filter: <input id="filter" type="text" size="40" oninput="filterRows()"> <span id="shown"></span></p>
+<table id="neighbors">
+<thead><tr>{{range $i, $h := .Headers}}<th onclick="sortRows({{$i}})">{{$h}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr class="{{.State}}">{{range .Cells}}<td{{if .Right}} class="r"{{end}} data-sort="{{.Sort}}">{{.Value}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+
+<script>
+var sortCol = -1, sortAsc = true;
+function sortRows(col) {
+	sortAsc = (col === sortCol) ? !sortAsc : true;
+	sortCol = col;
+	var body = document.querySelector("#neighbors tbody");
+	var rows = Array.prototype.slice.call(body.rows);
+	rows.sort(function(a, b) {
+		var x = a.cells[col].getAttribute("data-sort"), y = b.cells[col].getAttribute("data-sort");
+		var nx = parseFloat(x), ny = parseFloat(y), r;
+		if (!isNaN(nx) && !isNaN(ny) && String(nx) === x && String(ny) === y) {
+			r = nx - ny;
+		} else {
+			r = x.localeCompare(y, undefined, {numeric: true});
+		}
+		return sortAsc ? r : -r;
+	});
+	rows.forEach(function(row) { body.appendChild(row); });
+}
+function filterRows() {
+	var text = document.getElementById("filter").value.toLowerCase();
+	var rows = document.querySelectorAll("#neighbors tbody tr"), shown = 0;
+	for (var i = 0; i < rows.length; i++) {
+		var match = rows[i].textContent.toLowerCase().indexOf(text) >= 0;
+		rows[i].style.display = match ? "" : "none";
+		if (match) shown++;
+	}
+	document.getElementById("shown").textContent = shown + " of " + rows.length + " shown";
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,60 @@
+package main
+
+// string interning for large captures: the same VRF names, states, ASNs
+// and reset reasons repeat across thousands of neighbors. Fields parsed
+// from a line are substrings of it, so each one would also keep its
+// whole line alive; interning stores one copy per distinct value.
+
+import "strings"
+
+// maxInterned bounds the table, a capture with more distinct values
+// keeps the rest as parsed.
+const maxInterned = 100000
+
+type internTable map[string]string
+
+// intern returns the stored copy of s, storing a copy of s if new.
+func (t internTable) intern(s string) string {
+	if s == "" {
+		return s
+	}
+	if v, ok := t[s]; ok {
+		return v
+	}
+	if len(t) >= maxInterned {
+		return s
+	}
+	v := strings.Clone(s)
+	t[v] = v
+	return v
+}
+
+// internNeighbor replaces the repeated fields of n with interned copies.
+func (t internTable) internNeighbor(n *neigh) {
+	for _, p := range []*string{
+		&n.Device, &n.VRF, &n.RemoteAS, &n.LocalAS, &n.Link, &n.LocalRouterID,
+		&n.LocalHost, &n.State, &n.ResetReason, &n.BFDMode, &n.PeerGroup,
+		&n.ListenRange, &n.ClusterID, &n.RD,
+	} {
+		*p = t.intern(*p)
+	}
+	if n.TCP != nil {
+		n.TCP.State = t.intern(n.TCP.State)
+		n.TCP.Open = t.intern(n.TCP.Open)
+	}
+	for i := range n.Capabilities {
+		n.Capabilities[i].Name = t.intern(n.Capabilities[i].Name)
+	}
+	for i := range n.AddressFamilies {
+		n.AddressFamilies[i].Name = t.intern(n.AddressFamilies[i].Name)
+	}
+	for _, p := range n.Policies {
+		for _, s := range []*string{
+			&p.AddressFamily, &p.RouteMapIn, &p.RouteMapOut, &p.PrefixListIn,
+			&p.PrefixListOut, &p.FilterListIn, &p.FilterListOut,
+			&p.DistributeListIn, &p.DistributeListOut,
+		} {
+			*s = t.intern(*s)
+		}
+	}
+}
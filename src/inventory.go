@@ -0,0 +1,185 @@
+package main
+
+// collection from the -config device inventory through the OpenSSH client.
+// The show command is sent to the device shell on stdin, after enable and
+// terminal length 0; with transport terminal or telnet it is typed into an
+// interactive session instead (see termSession). Passwords are handed to ssh through SSH_ASKPASS,
+// answered by this same program (see askpass), so they never show up in
+// the process list or shell history.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const askpassEnv = "BGPN_ASKPASS_SECRET"
+
+// inventoryCollect collects from the inventory devices matching globs,
+// up to the configured number of devices at a time. Failed devices are
+// logged and skipped.
+func inventoryCollect(globs string, opts parseOptions) (map[string]*neigh, error) {
+	if inventory == nil || len(inventory.devices) == 0 {
+		return nil, fmt.Errorf("inventoryCollect: -devices requires -config with a device inventory")
+	}
+	devices := inventory.selectDevices(strings.Split(globs, ","))
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("inventoryCollect: no inventory device matches: [%s]", globs)
+	}
+
+	table := map[string]*neigh{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed int
+	sem := make(chan struct{}, inventory.parallel)
+
+	for _, d := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *inventoryDevice) {
+			defer func() { <-sem; wg.Done() }()
+			t, err := deviceCollect(d, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errorf("inventoryCollect: %v", err)
+				failed++
+				return
+			}
+			for k, n := range t {
+				table[k] = n
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	infof("inventoryCollect: %d devices, %d failed", len(devices), failed)
+	if failed == len(devices) {
+		return nil, fmt.Errorf("inventoryCollect: all %d devices failed", failed)
+	}
+
+	return table, nil
+}
+
+// deviceCollect collects from d through its transport.
+func deviceCollect(d *inventoryDevice, opts parseOptions) (map[string]*neigh, error) {
+	s := d.ssh
+	if s.dialect != "" {
+		dia, err := lookupDialect(s.dialect)
+		if err != nil {
+			return nil, fmt.Errorf("deviceCollect: %s: %v", d.name, err)
+		}
+		opts.dialect = dia
+		opts.dialectName = s.dialect
+	}
+	if s.transport == "exec" {
+		return sshCollect(d, opts)
+	}
+	if d.session == nil {
+		d.session = &termSession{
+			transport:      "ssh",
+			host:           d.host,
+			port:           s.port,
+			user:           s.user,
+			password:       s.password,
+			enablePassword: s.enablePassword,
+			command:        s.command,
+			timeout:        30 * time.Second,
+		}
+		if s.transport == "telnet" {
+			d.session.transport = "telnet"
+			if s.port == 22 {
+				d.session.port = 0 // ssh default, telnet defaults to 23
+			}
+		}
+	}
+	return d.session.collect(d.name, opts)
+}
+
+// sshCollect runs the device command over ssh and parses its output.
+func sshCollect(d *inventoryDevice, opts parseOptions) (map[string]*neigh, error) {
+	s := d.ssh
+	opts.device = d.name
+
+	cmd := exec.Command("ssh", sshArgs(d)...)
+	cmd.Stdin = strings.NewReader(sshScript(s))
+	cmd.Stderr = os.Stderr
+	if s.password != "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("sshCollect: %s: %v", d.name, err)
+		}
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS="+exe, "SSH_ASKPASS_REQUIRE=force", askpassEnv+"="+s.password)
+	}
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("sshCollect: %s: %v", d.name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("sshCollect: %s: %v", d.name, err)
+	}
+
+	table, _, parseErr := parseInput(out, d.name, opts)
+	if parseErr != nil {
+		io.Copy(io.Discard, out) // let ssh finish
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("sshCollect: %s: ssh %s: %v", d.name, d.host, err)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("sshCollect: %v", parseErr)
+	}
+
+	return table, nil
+}
+
+func sshArgs(d *inventoryDevice) []string {
+	s := d.ssh
+	args := []string{"-T", "-p", strconv.Itoa(s.port)}
+	if s.user != "" {
+		args = append(args, "-l", s.user)
+	}
+	if s.identity != "" {
+		args = append(args, "-i", s.identity)
+	}
+	if s.password == "" {
+		args = append(args, "-o", "BatchMode=yes") // fail instead of prompting
+	} else {
+		args = append(args, "-o", "NumberOfPasswordPrompts=1")
+	}
+	args = append(args, s.options...)
+	return append(args, d.host)
+}
+
+// sshScript is the device shell input.
+func sshScript(s sshSettings) string {
+	var b strings.Builder
+	if s.enablePassword != "" {
+		fmt.Fprintf(&b, "enable\n%s\n", s.enablePassword)
+	}
+	fmt.Fprintf(&b, "terminal length 0\n%s\nexit\n", s.command)
+	return b.String()
+}
+
+// askpass answers the ssh password prompt when this program runs as
+// SSH_ASKPASS. Other prompts, e.g. unknown host keys, are refused.
+func askpass() bool {
+	secret, found := os.LookupEnv(askpassEnv)
+	if !found || os.Getenv("SSH_ASKPASS") == "" {
+		return false
+	}
+	prompt := strings.Join(os.Args[1:], " ")
+	if !strings.Contains(strings.ToLower(prompt), "password") {
+		fmt.Fprintf(os.Stderr, "askpass: refusing prompt: %s\n", prompt)
+		os.Exit(1)
+	}
+	fmt.Println(secret)
+	return true
+}
@@ -0,0 +1,96 @@
+package main
+
+// route leak guard: neighbors whose received prefixes jumped beyond a
+// threshold since the previous collection, the signature of a peer
+// leaking a full table or a customer re-advertising its transit:
+//
+//	LEAK: 1 neighbor with a prefix jump beyond 1000,50%
+//	  pe1          198.51.100.1    CUST-A          65001 26 -> 5026 +5000 (+19231%)
+//
+// Only increases of sessions Established in both collections count: a
+// session coming up has no baseline.
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// leakGuard holds the thresholds a jump must all exceed, e.g. 1000,50%
+// for more than 1000 prefixes and more than half the previous count.
+type leakGuard []threshold
+
+func parseLeakGuard(spec string) (leakGuard, error) {
+	var g leakGuard
+	for _, s := range strings.Split(spec, ",") {
+		t, err := parseThreshold(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("parseLeakGuard: %v", err)
+		}
+		g = append(g, t)
+	}
+	return g, nil
+}
+
+// jumped reports whether the prefixes of n grew beyond every threshold
+// since prev, the same neighbor at the previous collection (or nil).
+func (g leakGuard) jumped(prev, n *neigh) bool {
+	if prev == nil || prev.State != "Established" || n.State != "Established" || n.Prefixes <= prev.Prefixes {
+		return false
+	}
+	for _, t := range g {
+		if !t.exceeded(prev.Prefixes, n.Prefixes) {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixLeak is a neighbor flagged by the leak guard.
+type prefixLeak struct {
+	Neigh *neigh
+	Prev  *neigh
+}
+
+// findLeaks returns the neighbors of curr that jumped since prev, largest
+// jump first.
+func findLeaks(prev, curr map[string]*neigh, g leakGuard) []prefixLeak {
+	var leaks []prefixLeak
+	for k, n := range curr {
+		if p := prev[k]; g.jumped(p, n) {
+			leaks = append(leaks, prefixLeak{Neigh: n, Prev: p})
+		}
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		di := leaks[i].Neigh.Prefixes - leaks[i].Prev.Prefixes
+		dj := leaks[j].Neigh.Prefixes - leaks[j].Prev.Prefixes
+		if di != dj {
+			return di > dj
+		}
+		return neighKey(leaks[i].Neigh) < neighKey(leaks[j].Neigh)
+	})
+	return leaks
+}
+
+// prefixJump formats a prefix count change: 26 -> 5026 +5000 (+19231%)
+func prefixJump(oldCount, newCount int) string {
+	s := fmt.Sprintf("%d -> %d %+d", oldCount, newCount, newCount-oldCount)
+	if oldCount > 0 {
+		s += fmt.Sprintf(" (%+.0f%%)", 100*float64(newCount-oldCount)/float64(oldCount))
+	}
+	return s
+}
+
+// writeLeaks reports the leaks found by findLeaks.
+func writeLeaks(w io.Writer, leaks []prefixLeak, spec string) {
+	noun := "neighbors"
+	if len(leaks) == 1 {
+		noun = "neighbor"
+	}
+	fmt.Fprintf(w, "LEAK: %d %s with a prefix jump beyond %s\n", len(leaks), noun, spec)
+	for _, l := range leaks {
+		n := l.Neigh
+		fmt.Fprintf(w, "  %-12s %-15s %-14s %6s %s\n", n.Device, n.Addr, n.VRF, n.RemoteAS, prefixJump(l.Prev.Prefixes, n.Prefixes))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLeakGuard(t *testing.T) {
+	cases := []struct {
+		spec              string
+		state             string
+		prev, curr        int
+		prevState, jumped bool
+	}{
+		{"1000,50%", "Established", 26, 5026, true, true},
+		{"1000,50%", "Established", 4000, 5026, true, false}, // 25%
+		{"1000,50%", "Established", 26, 526, true, false},    // +500
+		{"1000", "Established", 0, 1001, true, true},
+		{"1000", "Established", 5026, 26, true, false}, // decrease
+		{"1000", "Established", 0, 5026, false, false}, // session coming up
+		{"1000", "Idle", 26, 5026, true, false},
+		{" 10 , 10% ", "Established", 100, 120, true, true},
+	}
+	for _, c := range cases {
+		g, err := parseLeakGuard(c.spec)
+		if err != nil {
+			t.Errorf("parseLeakGuard(%q): %v", c.spec, err)
+			continue
+		}
+		prev := &neigh{State: "Established", Prefixes: c.prev}
+		if !c.prevState {
+			prev.State = "Active"
+		}
+		n := &neigh{State: c.state, Prefixes: c.curr}
+		if got := g.jumped(prev, n); got != c.jumped {
+			t.Errorf("%q: %d -> %d jumped = %v, want %v", c.spec, c.prev, c.curr, got, c.jumped)
+		}
+	}
+	g, _ := parseLeakGuard("1")
+	if g.jumped(nil, &neigh{State: "Established", Prefixes: 5026}) {
+		t.Error("jumped without a previous collection")
+	}
+	for _, bad := range []string{"", "1000,", "-5", "lots"} {
+		if _, err := parseLeakGuard(bad); err == nil {
+			t.Errorf("parseLeakGuard(%q): want error", bad)
+		}
+	}
+}
+
+func TestFindLeaks(t *testing.T) {
+	n := func(addr string, prefixes int) *neigh {
+		return &neigh{Device: "pe1", Addr: addr, VRF: "CUST-A", RemoteAS: "65001", State: "Established", Prefixes: prefixes}
+	}
+	table := func(list ...*neigh) map[string]*neigh {
+		t := map[string]*neigh{}
+		for _, n := range list {
+			t[neighKey(n)] = n
+		}
+		return t
+	}
+	prev := table(n("198.51.100.1", 26), n("198.51.100.2", 10), n("198.51.100.3", 100))
+	curr := table(n("198.51.100.1", 5026), n("198.51.100.2", 20010), n("198.51.100.3", 110), n("198.51.100.4", 9000))
+	g, _ := parseLeakGuard("1000,50%")
+	leaks := findLeaks(prev, curr, g)
+	var got []string
+	for _, l := range leaks {
+		got = append(got, l.Neigh.Addr)
+	}
+	if strings.Join(got, " ") != "198.51.100.2 198.51.100.1" {
+		t.Errorf("leaks %v, want the largest jump first", got)
+	}
+
+	var out bytes.Buffer
+	writeLeaks(&out, leaks[1:], "1000,50%")
+	want := "LEAK: 1 neighbor with a prefix jump beyond 1000,50%\n" +
+		"  pe1          198.51.100.1    CUST-A          65001 26 -> 5026 +5000 (+19231%)\n"
+	if out.String() != want {
+		t.Errorf("writeLeaks:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if got := prefixJump(0, 800); got != "0 -> 800 +800" {
+		t.Errorf("prefixJump from zero: %s", got)
+	}
+}
+
+// TestCheckLeakExitStatus runs check -leak twice in a child process: the
+// first run sees a jump from the saved state, the second one does not.
+func TestCheckLeakExitStatus(t *testing.T) {
+	if args := os.Getenv("BGPN_TEST_DISPATCH"); args != "" {
+		dispatch(strings.Split(args, "\n"))
+		os.Exit(exitOK)
+	}
+
+	capture := filepath.Join("testdata", "ios-console-garbage.txt")
+	table, _, err := parseFile(capture, parseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range table {
+		if n.Prefixes == 236 {
+			n.Prefixes = 100
+		}
+	}
+	state := filepath.Join(t.TempDir(), "state.json")
+	if err := saveTableJSON(state, table); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		code int
+		want string
+	}{
+		{exitLeak, "LEAK: 1 neighbor with a prefix jump beyond 100,50%"},
+		{exitOK, "OK: 2 neighbors, all Established"},
+	}
+	for i, c := range cases {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckLeakExitStatus$")
+		cmd.Env = append(os.Environ(), "BGPN_TEST_DISPATCH="+strings.Join([]string{"check", "-alert-state", state, "-leak", "100,50%", capture}, "\n"))
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		err := cmd.Run()
+		code := exitOK
+		if e, ok := err.(*exec.ExitError); ok {
+			code = e.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != c.code || !strings.Contains(out.String(), c.want) {
+			t.Errorf("run %d: exit status %d, want %d, output:\n%s", i+1, code, c.code, out.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+// line reader tolerant of captures logged from serial consoles: NUL bytes
+// from line noise, bytes of another encoding and lines running for
+// megabytes when the newlines got lost. Such lines are repaired or skipped
+// with a warning, instead of aborting the capture:
+//
+//	  BGP state = Estab\x00lished, up for 5w2d    NUL dropped
+//	 Description: S\xe3o Paulo                    invalid UTF-8 replaced by U+FFFD
+//	(20 MB without newline)                      skipped, see -max-line
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// lineRepairs counts the lines scanLines skipped or changed.
+type lineRepairs struct {
+	long          int // skipped, longer than the maximum
+	firstLong     int // line number of the first one
+	repaired      int // NUL bytes dropped or invalid UTF-8 replaced
+	firstRepaired int
+}
+
+func (rep lineRepairs) String() string {
+	var s string
+	if rep.long > 0 {
+		s = fmt.Sprintf("skipped %d lines longer than the maximum (first at line %d, see -max-line)", rep.long, rep.firstLong)
+	}
+	if rep.repaired > 0 {
+		if s != "" {
+			s += ", "
+		}
+		s += fmt.Sprintf("repaired %d lines with NUL bytes or invalid UTF-8 (first at line %d)", rep.repaired, rep.firstRepaired)
+	}
+	return s
+}
+
+// scanLines calls consumer for each line of r, without the line ending.
+// Lines longer than maxLen bytes are skipped, or fail in strict mode.
+func scanLines(r io.Reader, maxLen int, strict bool, consumer lineConsumerFunc) (lineRepairs, error) {
+	var rep lineRepairs
+	br := decodeInput(bufio.NewReaderSize(r, scanBufferSize))
+	var buf []byte
+	long := false
+	i := 0
+
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(buf)+len(chunk) > maxLen+2 { // room for \r\n
+			long = true
+			buf = buf[:0]
+		}
+		if !long {
+			buf = append(buf, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue // line goes on
+		}
+		if err != nil && err != io.EOF {
+			return rep, fmt.Errorf("scanLines: error reading line %d: %v", i+1, err)
+		}
+		if err == io.EOF && len(buf) == 0 && !long {
+			return rep, nil
+		}
+
+		i++
+		if long {
+			if strict {
+				return rep, fmt.Errorf("scanLines: line %d: longer than %d bytes (see -max-line)", i, maxLen)
+			}
+			if rep.long == 0 {
+				rep.firstLong = i
+			}
+			rep.long++
+			long = false
+		} else {
+			line, repaired := repairLine(dropLineEnding(buf))
+			if repaired {
+				if rep.repaired == 0 {
+					rep.firstRepaired = i
+				}
+				rep.repaired++
+			}
+			if err := consumer(line, i); err != nil {
+				err = fmt.Errorf("scanLines: error consuming line %d [%s]: %v", i, line, err)
+				debugf("%v", err)
+				return rep, err
+			}
+		}
+		buf = buf[:0]
+
+		if err == io.EOF {
+			return rep, nil
+		}
+	}
+}
+
+func dropLineEnding(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte{'\n'})
+	return bytes.TrimSuffix(b, []byte{'\r'})
+}
+
+// repairLine drops NUL bytes and replaces invalid UTF-8 with U+FFFD.
+func repairLine(b []byte) (string, bool) {
+	if bytes.IndexByte(b, 0) < 0 && utf8.Valid(b) {
+		return string(b), false // fast path
+	}
+	b = bytes.Replace(b, []byte{0}, nil, -1)
+	return string(bytes.ToValidUTF8(b, []byte("\uFFFD"))), true
+}
@@ -0,0 +1,95 @@
+package main
+
+// local side of the session:
+// BGP neighbor is 10.0.0.1,  remote AS 65001, local AS 65010 no-prepend, external link
+// BGP neighbor is 10.0.0.5,  vrf CUST-B,  remote AS 64512, internal link (VPN client)
+//   BGP version 4, remote router ID 10.0.0.1
+// Local host: 10.0.0.2, Local port: 179
+// Foreign host: 10.0.0.1, Foreign port: 34511
+// BGP router identifier 192.0.2.10, local AS number 64512   (summary output)
+
+import (
+	"strconv"
+	"strings"
+)
+
+// headerLocalAS returns the local-as override from the fields of a
+// neighbor header line.
+func headerLocalAS(f lineFields) string {
+	return f.after("local", "AS")
+}
+
+// headerLink returns the session type from a neighbor header line, e.g.
+// "external" from "..., external link" or "internal" from
+// "..., internal link (VPN client), keepalive 60s" (IOS-XE 17.x).
+func headerLink(f lineFields) string {
+	for i := 1; i < len(f); i++ {
+		if strings.TrimRight(f[i], ",") == "link" {
+			return f.word(i - 1)
+		}
+	}
+	return ""
+}
+
+// parseRouterIDLine parses "  BGP version 4, remote router ID X[, local router ID Y]".
+func parseRouterIDLine(n *neigh, line string) bool {
+	if !strings.HasPrefix(line, "  BGP version ") {
+		return false
+	}
+	for _, part := range strings.Split(strings.TrimSpace(line), ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "remote router ID "):
+			n.RouterID = strings.TrimSpace(part[len("remote router ID "):])
+		case strings.HasPrefix(part, "local router ID "):
+			n.LocalRouterID = strings.TrimSpace(part[len("local router ID "):])
+		}
+	}
+	return true
+}
+
+// parseEndpointLine parses "Local host: A, Local port: P" and "Foreign host: A, Foreign port: P".
+func parseEndpointLine(n *neigh, line string) bool {
+	var host *string
+	var port *int
+	switch {
+	case strings.HasPrefix(line, "Local host: "):
+		host, port = &n.LocalHost, &n.LocalPort
+	case strings.HasPrefix(line, "Foreign host: "):
+		host, port = &n.ForeignHost, &n.ForeignPort
+	default:
+		return false
+	}
+	for _, part := range strings.Split(line, ", ") {
+		i := strings.Index(part, ": ")
+		if i < 0 {
+			continue
+		}
+		key, value := part[:i], strings.TrimSpace(part[i+2:])
+		switch {
+		case strings.HasSuffix(key, " host"):
+			*host = value
+		case strings.HasSuffix(key, " port"):
+			*port, _ = strconv.Atoi(value)
+		}
+	}
+	return true
+}
+
+// parseRouterIdentifier parses the summary line
+// "BGP router identifier 192.0.2.10, local AS number 64512".
+func parseRouterIdentifier(line string) (id, asn string, ok bool) {
+	if !strings.HasPrefix(line, "BGP router identifier ") {
+		return "", "", false
+	}
+	f := strings.Fields(strings.Replace(line, ",", " ", -1))
+	for i := 0; i+1 < len(f); i++ {
+		switch {
+		case f[i] == "identifier":
+			id = f[i+1]
+		case f[i] == "number" && i > 1 && f[i-1] == "AS":
+			asn = f[i+1]
+		}
+	}
+	return id, asn, true
+}
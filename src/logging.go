@@ -0,0 +1,118 @@
+package main
+
+// leveled logging to stderr, as text or JSON lines:
+// 2026/01/02 15:04:05 INFO main: found 4 neighbors
+// {"time":"2026-01-02T15:04:05.123Z","level":"info","component":"main","msg":"main: found 4 neighbors"}
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("parseLogLevel: unknown log level: [%s] (available: %s)", s, strings.Join(logLevelNames, ","))
+}
+
+var logger = struct {
+	mu    sync.Mutex
+	level logLevel
+	json  bool
+	out   io.Writer
+}{level: levelInfo, out: os.Stderr}
+
+// setupLogging configures the logger. quiet keeps only errors.
+func setupLogging(level, format string, quiet bool) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return fmt.Errorf("setupLogging: %v", err)
+	}
+	if quiet {
+		l = levelError
+	}
+	switch format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("setupLogging: unknown log format: [%s] (available: text,json)", format)
+	}
+	logger.mu.Lock()
+	logger.level = l
+	logger.json = format == "json"
+	logger.mu.Unlock()
+	return nil
+}
+
+// logComponent matches the "funcName: " prefix used by messages.
+var logComponent = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_.]*): `)
+
+func logf(level logLevel, format string, args ...interface{}) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	if level < logger.level {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	now := time.Now()
+
+	if !logger.json {
+		fmt.Fprintf(logger.out, "%s %s %s\n", now.Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()), msg)
+		return
+	}
+
+	entry := struct {
+		Time      string `json:"time"`
+		Level     string `json:"level"`
+		Component string `json:"component,omitempty"`
+		Msg       string `json:"msg"`
+	}{Time: now.UTC().Format(time.RFC3339Nano), Level: level.String(), Msg: msg}
+	if m := logComponent.FindStringSubmatch(msg); m != nil {
+		entry.Component = m[1]
+	}
+	b, _ := json.Marshal(entry)
+	logger.out.Write(append(b, '\n'))
+}
+
+func debugf(format string, args ...interface{}) { logf(levelDebug, format, args...) }
+func infof(format string, args ...interface{})  { logf(levelInfo, format, args...) }
+func warnf(format string, args ...interface{})  { logf(levelWarn, format, args...) }
+func errorf(format string, args ...interface{}) { logf(levelError, format, args...) }
+
+// fatalHandler, if set, is called by fatalf instead of exiting with
+// exitError, see nagiosFatal.
+var fatalHandler func(err error)
+
+// fatalf logs an error and exits with exitError.
+func fatalf(format string, args ...interface{}) {
+	logf(levelError, format, args...)
+	if fatalHandler != nil {
+		fatalHandler(fmt.Errorf(format, args...))
+	}
+	os.Exit(exitError)
+}
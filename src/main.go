@@ -2,162 +2,96 @@ package main
 
 // parser for cisco command output:
 // show bgp vpnv4 unicast all neighbors
+// show ip bgp neighbors
+// show bgp vpnv4 unicast all summary
 
 import (
-	"bufio"
-	"fmt"
-	"log"
 	"os"
-	"strings"
+	"time"
 )
 
 type neigh struct {
-	addr        string
-	vrf         string
-	remoteAs    string
-	state       string
-	uptime      string
-	prefixCount string
+	Device        string `json:"device,omitempty"` // from the capture prompt or file name
+	Addr          string `json:"addr"`
+	VRF           string `json:"vrf"`
+	RemoteAS      string `json:"remote_as"`
+	RouterID      string `json:"router_id,omitempty"` // remote BGP identifier
+	LocalAS       string `json:"local_as,omitempty"`  // local-as override, or from summary output
+	Link          string `json:"link,omitempty"`      // external (eBGP) or internal (iBGP)
+	LocalRouterID string `json:"local_router_id,omitempty"`
+	LocalHost     string `json:"local_host,omitempty"`
+	LocalPort     int    `json:"local_port,omitempty"`
+	ForeignHost   string `json:"foreign_host,omitempty"`
+	ForeignPort   int    `json:"foreign_port,omitempty"`
+	State         string `json:"state"`
+	Uptime        string `json:"uptime"`
+	UptimeSeconds *int64 `json:"uptime_seconds"` // nil when uptime is unknown or never
+	Prefixes      int    `json:"prefixes"`
+	Description   string `json:"description,omitempty"`
+	LastReset     string `json:"last_reset,omitempty"`
+	ResetReason   string `json:"reset_reason,omitempty"`
+
+	NotificationSent *bgpNotification `json:"notification_sent,omitempty"` // last notification sent to the neighbor
+	NotificationRcvd *bgpNotification `json:"notification_rcvd,omitempty"` // last notification received from the neighbor
+
+	BFD           bool   `json:"bfd,omitempty"`      // Using BFD to detect fast fallover
+	BFDMode       string `json:"bfd_mode,omitempty"` // single-hop or multi-hop
+	FallOver      bool   `json:"fall_over,omitempty"`
+	PeerGroup     string `json:"peer_group,omitempty"`    // peer-group, or inherited session template
+	Dynamic       bool   `json:"dynamic,omitempty"`       // created from a bgp listen range
+	ListenRange   string `json:"listen_range,omitempty"`  // subnet range group of a dynamic peer
+	Shutdown      bool   `json:"shutdown,omitempty"`      // administratively shut down
+	Passive       bool   `json:"passive,omitempty"`       // waits for the peer to open the TCP session
+	ClusterID     string `json:"cluster_id,omitempty"`    // neighbor-specific route reflector cluster ID
+	Confederation bool   `json:"confederation,omitempty"` // peer in another member AS of our confederation
+
+	RD       string   `json:"rd,omitempty"`        // VRF route distinguisher, from show vrf detail or the bgp table
+	ImportRT []string `json:"import_rt,omitempty"` // VRF import route targets, from show vrf detail
+	ExportRT []string `json:"export_rt,omitempty"`
+
+	GracefulRestart *grState    `json:"graceful_restart,omitempty"`
+	Timers          *bgpTimers  `json:"timers,omitempty"` // detailed output only
+	TCP             *tcpSession `json:"tcp,omitempty"`    // detailed output only
+
+	MsgRcvd  int       `json:"msg_rcvd,omitempty"`
+	MsgSent  int       `json:"msg_sent,omitempty"`
+	TblVer   int       `json:"tbl_ver,omitempty"` // summary output only
+	InQ      int       `json:"in_q,omitempty"`
+	OutQ     int       `json:"out_q,omitempty"`
+	Messages *msgStats `json:"messages,omitempty"` // detailed output only
+
+	Connections *connStats `json:"connections,omitempty"` // detailed output only
+
+	Capabilities    []capability `json:"capabilities,omitempty"`
+	AddressFamilies []capability `json:"address_families,omitempty"`
+	Policies        []*afPolicy  `json:"policies,omitempty"` // per address family
+
+	Extra map[string]string `json:"extra,omitempty"` // added by -enrich, see enrich.go
+
+	summary bool // parsed from a summary row, see mergeNeighbor
 }
 
-type neighScanner struct {
-	table map[string]*neigh
-	curr  *neigh
-}
-
-func main() {
-	log.Printf("main: reading from stdin")
-
-	linesFound := 0
-	scanner := &neighScanner{table: map[string]*neigh{}}
-
-	consume := func(line string, lineNumber int) error {
-		linesFound++
-		return lineParser(scanner, line, lineNumber)
-	}
-
-	if err := scanFile(os.Stdin, consume); err != nil {
-		log.Printf("main: %v", err)
-	}
-
-	log.Printf("main: reading from stdin: done: %d lines", linesFound)
-
-	log.Printf("main: found %d neighbors", len(scanner.table))
-
-	format := "%-15s %-14s %6s %-11s %-6s %8s\n"
-
-	fmt.Printf(format, "Neighbor", "VRF", "ASN", "State", "Uptime", "Prefixes")
-	for _, n := range scanner.table {
-		fmt.Printf(format, n.addr, n.vrf, n.remoteAs, n.state, n.uptime, n.prefixCount)
+func (n *neigh) setUptime(uptime string) {
+	n.Uptime = uptime
+	n.UptimeSeconds = nil
+	if d, err := parseUptime(uptime); err == nil {
+		secs := int64(d / time.Second)
+		n.UptimeSeconds = &secs
 	}
 }
 
-//BGP neighbor is 1.1.1.1,  vrf VRFNAME,  remote AS 65000, external link
-//  BGP state = Established, up for 5w2d
-//  Session state = Established, up for 1y8w
-//(...)
-//    Prefixes Current:               0         26 (Consumes 2080 bytes)
-
-func lineParser(scanner *neighScanner, line string, lineNum int) error {
-
-	if strings.HasPrefix(line, "BGP neighbor is ") {
-
-		f := strings.Fields(line)
-		if len(f) < 4 {
-			return fmt.Errorf("lineParser: short bgp neighbor line: line=%d [%s]", lineNum, line)
-		}
-
-		id := f[3][:len(f[3])-1]
-
-		var vrf, asn string
-
-		if f[4] == "vrf" {
-			if len(f) < 9 {
-				return fmt.Errorf("lineParser: bad bgp neighbor vrf line: line=%d [%s]", lineNum, line)
-			}
-
-			vrf = f[5][:len(f[5])-1]
-			asn = f[8][:len(f[8])-1]
-		} else {
-			if len(f) < 7 {
-				return fmt.Errorf("lineParser: bad bgp neighbor line: line=%d [%s]", lineNum, line)
-			}
-			vrf = "--"
-			asn = f[6][:len(f[6])-1]
-		}
-
-		key := fmt.Sprintf("%s:%s", id, vrf)
-
-		n, ok := scanner.table[key]
-		if !ok {
-			n = &neigh{addr: id}
-			scanner.table[key] = n
-		}
-
-		n.vrf = vrf
-		n.remoteAs = asn
-
-		scanner.curr = n
-
-		return nil
-	}
-
-	if strings.HasPrefix(line, "  BGP state = ") || strings.HasPrefix(line, "  Session state = ") {
-		if scanner.curr == nil {
-			return fmt.Errorf("lineParser: hit state without neighbor: line=%d [%s]", lineNum, line)
-		}
-		f := strings.Fields(line)
-		if len(f) < 4 {
-			return fmt.Errorf("lineParser: bad bgp state line: line=%d [%s]", lineNum, line)
-		}
-		if len(f) < 7 {
-			scanner.curr.state = f[3]
-			scanner.curr.uptime = "?"
-		} else {
-			scanner.curr.state = f[3][:len(f[3])-1]
-			scanner.curr.uptime = f[6]
-		}
-		return nil
-	}
-
-	if strings.HasPrefix(line, "    Prefixes Current:") {
-		if scanner.curr == nil {
-			return fmt.Errorf("lineParser: hit prefix count without neighbor: line=%d [%s]", lineNum, line)
-		}
-		f := strings.Fields(line)
-		if len(f) < 4 {
-			return fmt.Errorf("lineParser: bad bgp prefixes line: line=%d [%s]", lineNum, line)
-		}
-		scanner.curr.prefixCount = f[3]
-		return nil
+// uptimeDuration returns the parsed uptime.
+// ok is false when uptime is unknown or never.
+func (n *neigh) uptimeDuration() (d time.Duration, ok bool) {
+	if n.UptimeSeconds == nil {
+		return 0, false
 	}
-
-	return nil // no error
+	return time.Duration(*n.UptimeSeconds) * time.Second, true
 }
 
-type lineConsumerFunc func(line string, lineNumber int) error
-
-func scanFile(f *os.File, consumer lineConsumerFunc) error {
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-
-	var lastErr error
-	i := 0
-
-	for scanner.Scan() {
-		i++
-		line := scanner.Text()
-		if err := consumer(line, i); err != nil {
-			lastErr = fmt.Errorf("scanFile: error consuming line %d [%s]: %v", i, line, err)
-			log.Printf("%v", lastErr)
-			return lastErr
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		lastErr = fmt.Errorf("scanFile: error scanning: %v", err)
+func main() {
+	if askpass() {
+		return
 	}
-
-	return lastErr
+	dispatch(os.Args[1:])
 }
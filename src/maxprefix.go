@@ -0,0 +1,114 @@
+package main
+
+// max-prefix limit usage per address family:
+// the near-limit report lists the address families whose prefixes
+// received are within a given percentage of the configured limit.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type prefixLimit struct {
+	Device        string  `json:"device,omitempty"`
+	Addr          string  `json:"addr"`
+	VRF           string  `json:"vrf"`
+	AddressFamily string  `json:"address_family"`
+	Prefixes      int     `json:"prefixes"`
+	MaxPrefix     int     `json:"max_prefix"`
+	Usage         float64 `json:"usage"` // percent of MaxPrefix
+	Threshold     int     `json:"threshold,omitempty"`
+	Restart       int     `json:"restart,omitempty"` // minutes
+	WarningOnly   bool    `json:"warning_only,omitempty"`
+}
+
+// parsePercent parses 20 or 20% into 20.
+func parsePercent(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, fmt.Errorf("parsePercent: expecting percentage from 0 to 100: [%s]", s)
+	}
+	return pct, nil
+}
+
+// nearLimit returns the address families whose usage is at least
+// 100-within percent of the max-prefix limit, highest usage first.
+func nearLimit(list []*neigh, within float64) []*prefixLimit {
+	var rows []*prefixLimit
+	for _, n := range list {
+		for _, p := range n.Policies {
+			usage, ok := p.maxPrefixUsage()
+			if !ok || usage < 100-within {
+				continue
+			}
+			rows = append(rows, &prefixLimit{
+				Device:        n.Device,
+				Addr:          n.Addr,
+				VRF:           n.VRF,
+				AddressFamily: p.AddressFamily,
+				Prefixes:      p.Prefixes,
+				MaxPrefix:     p.MaxPrefix,
+				Usage:         usage,
+				Threshold:     p.MaxPrefixThreshold,
+				Restart:       p.MaxPrefixRestart,
+				WarningOnly:   p.MaxPrefixWarningOnly,
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Usage > rows[j].Usage })
+	return rows
+}
+
+func writeNearLimit(w io.Writer, rows []*prefixLimit) {
+	fmt.Fprintf(w, "%-12s %-15s %-14s %-16s %8s %8s %6s %5s %s\n", "Device", "Neighbor", "VRF", "Address family", "Prefixes", "Limit", "Used", "Warn", "Restart")
+	for _, r := range rows {
+		warn := ""
+		if r.Threshold > 0 {
+			warn = strconv.Itoa(r.Threshold) + "%"
+		}
+		restart := ""
+		switch {
+		case r.WarningOnly:
+			restart = "warning-only"
+		case r.Restart > 0:
+			restart = strconv.Itoa(r.Restart) + "m"
+		}
+		fmt.Fprintf(w, "%-12s %-15s %-14s %-16s %8d %8d %5.1f%% %5s %s\n", r.Device, r.Addr, r.VRF, r.AddressFamily, r.Prefixes, r.MaxPrefix, r.Usage, warn, restart)
+	}
+}
+
+func writeNearLimitJSON(w io.Writer, rows []*prefixLimit) error {
+	if rows == nil {
+		rows = []*prefixLimit{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		return fmt.Errorf("writeNearLimitJSON: %v", err)
+	}
+	return nil
+}
+
+// maxPrefixLimits lists the max-prefix limits over all address families.
+func maxPrefixLimits(n *neigh) string {
+	var limits []string
+	for _, p := range n.Policies {
+		if p.MaxPrefix > 0 {
+			limits = append(limits, strconv.Itoa(p.MaxPrefix))
+		}
+	}
+	return strings.Join(limits, ",")
+}
+
+// maxPrefixPct formats the highest max-prefix usage over all address families.
+func maxPrefixPct(n *neigh) string {
+	usage, ok := n.maxPrefixUsage()
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(usage, 'f', 1, 64) + "%"
+}
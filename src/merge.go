@@ -0,0 +1,95 @@
+package main
+
+// merge of the summary and detailed views of the same neighbor, from
+// both commands in one capture or from separate captures of a device:
+//
+//	pe1#show bgp vpnv4 unicast all summary
+//	198.51.100.1    4        65001   52064   52037    99871    0    0 5w2d           26
+//	pe1#show bgp vpnv4 unicast all neighbors
+//	BGP neighbor is 198.51.100.1,  vrf CUST-A,  remote AS 65001, external link
+//
+// The summary row supplies the message counters, queues, table version and
+// prefix count; the detailed block supplies everything else. Rows of the
+// all summary carry no vrf, and join the detailed block of the same device
+// and address, unless the address is found in several VRFs.
+
+// mergeNeighbor returns the neighbor for a key found twice. A summary row
+// and a detailed block are merged, otherwise the later one replaces the
+// earlier one.
+func mergeNeighbor(earlier, later *neigh) *neigh {
+	switch {
+	case earlier.summary && !later.summary:
+		mergeSummaryRow(later, earlier)
+		return later
+	case later.summary && !earlier.summary:
+		mergeSummaryRow(earlier, later)
+		return earlier
+	}
+	return later
+}
+
+// mergeSummaryRow copies the summary counters of row onto the detailed n.
+func mergeSummaryRow(n, row *neigh) {
+	n.MsgRcvd = row.MsgRcvd
+	n.MsgSent = row.MsgSent
+	n.TblVer = row.TblVer
+	n.InQ = row.InQ
+	n.OutQ = row.OutQ
+	if row.State == "Established" && n.State == "Established" {
+		if len(row.Policies) == 1 && len(n.Policies) > 0 {
+			af := row.Policies[0] // one address family of a multi-AF neighbor
+			n.afPolicy(af.AddressFamily).Prefixes = af.Prefixes
+			n.sumPrefixes()
+		} else {
+			n.Prefixes = row.Prefixes
+		}
+	}
+	if n.RemoteAS == "" {
+		n.RemoteAS = row.RemoteAS
+	}
+	if n.LocalAS == "" {
+		n.LocalAS = row.LocalAS
+	}
+	if n.LocalRouterID == "" {
+		n.LocalRouterID = row.LocalRouterID
+	}
+	n.Dynamic = n.Dynamic || row.Dynamic
+}
+
+// mergeTable stores n into table, merging it with a neighbor of the same key.
+func mergeTable(table map[string]*neigh, n *neigh) {
+	k := neighKey(n)
+	if old, found := table[k]; found {
+		n = mergeNeighbor(old, n)
+	}
+	table[k] = n
+}
+
+// mergeSummaryRows joins the summary rows without vrf onto the detailed
+// block of the same device and address in a VRF.
+func mergeSummaryRows(table map[string]*neigh) {
+	detailed := map[string][]*neigh{} // device/addr
+	for _, n := range table {
+		if !n.summary {
+			k := tableKey(n.Device, n.Addr, "")
+			detailed[k] = append(detailed[k], n)
+		}
+	}
+	if len(detailed) == 0 {
+		return
+	}
+	for k, row := range table {
+		if !row.summary || row.VRF != vrfGlobalVPN {
+			continue
+		}
+		matches := detailed[tableKey(row.Device, row.Addr, "")]
+		if len(matches) != 1 {
+			if len(matches) > 1 {
+				debugf("mergeSummaryRows: %s found in %d VRFs, summary row kept apart", row.Addr, len(matches))
+			}
+			continue
+		}
+		mergeSummaryRow(matches[0], row)
+		delete(table, k)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+// "Message statistics:" section:
+//    InQ depth is 0
+//    OutQ depth is 0
+//
+//                         Sent       Rcvd
+//    Opens:                  1          1
+//    Notifications:          0          0
+//    Updates:               10         26
+//    Keepalives:         52020      52031
+//    Route Refresh:          0          0
+//    Total:              52031      52058
+//
+// Session level counter, after the address family sections:
+//  Connections established 5; dropped 4
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const sectionMessages = "messages"
+
+type msgCounter struct {
+	Sent int `json:"sent"`
+	Rcvd int `json:"rcvd"`
+}
+
+type msgStats struct {
+	Opens         msgCounter `json:"opens"`
+	Notifications msgCounter `json:"notifications"`
+	Updates       msgCounter `json:"updates"`
+	Keepalives    msgCounter `json:"keepalives"`
+	RouteRefresh  msgCounter `json:"route_refresh"`
+	Total         msgCounter `json:"total"`
+}
+
+func (m *msgStats) counter(name string) *msgCounter {
+	switch name {
+	case "Opens":
+		return &m.Opens
+	case "Notifications":
+		return &m.Notifications
+	case "Updates":
+		return &m.Updates
+	case "Keepalives":
+		return &m.Keepalives
+	case "Route Refresh", "Route-Refresh": // IOS, EOS
+		return &m.RouteRefresh
+	case "Total", "Total messages":
+		return &m.Total
+	}
+	return nil
+}
+
+// connStats counts the TCP sessions that reached Established, and those
+// that later went down. Every drop after the first session is a flap.
+type connStats struct {
+	Established int `json:"established"`
+	Dropped     int `json:"dropped"`
+}
+
+// dropRatio returns dropped/established, 0 for a session never established.
+func (c *connStats) dropRatio() float64 {
+	if c.Established == 0 {
+		return 0
+	}
+	return float64(c.Dropped) / float64(c.Established)
+}
+
+// parseConnectionsLine parses "  Connections established 5; dropped 4".
+// It returns false for any other line.
+func parseConnectionsLine(n *neigh, line string) bool {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "Connections established ") {
+		return false
+	}
+	var c connStats
+	if _, err := fmt.Sscanf(s, "Connections established %d; dropped %d", &c.Established, &c.Dropped); err != nil {
+		return false
+	}
+	n.Connections = &c
+	return true
+}
+
+// dropRatio returns the connection drop ratio of n.
+// ok is false without connection counters (summary output).
+func (n *neigh) dropRatio() (ratio float64, ok bool) {
+	if n.Connections == nil {
+		return 0, false
+	}
+	return n.Connections.dropRatio(), true
+}
+
+// parseMessageLine parses one line inside the "Message statistics:" section.
+func parseMessageLine(n *neigh, line string, lineNum int) error {
+	s := strings.TrimSpace(line)
+
+	if strings.HasPrefix(s, "InQ depth is ") || strings.HasPrefix(s, "OutQ depth is ") {
+		f := strings.Fields(s)
+		depth, err := strconv.Atoi(f[len(f)-1])
+		if err != nil {
+			return fmt.Errorf("parseMessageLine: bad queue depth: line=%d [%s]", lineNum, line)
+		}
+		if f[0] == "InQ" {
+			n.InQ = depth
+		} else {
+			n.OutQ = depth
+		}
+		return nil
+	}
+
+	i := strings.Index(s, ":")
+	if i < 0 || n.Messages == nil {
+		return nil // column header or blank
+	}
+	c := n.Messages.counter(s[:i])
+	if c == nil {
+		return nil // unknown message type
+	}
+	f := strings.Fields(s[i+1:])
+	if len(f) < 2 {
+		return fmt.Errorf("parseMessageLine: short counter line: line=%d [%s]", lineNum, line)
+	}
+	sent, errSent := strconv.Atoi(f[0])
+	rcvd, errRcvd := strconv.Atoi(f[1])
+	if errSent != nil || errRcvd != nil {
+		return fmt.Errorf("parseMessageLine: bad counter: line=%d [%s]", lineNum, line)
+	}
+	c.Sent, c.Rcvd = sent, rcvd
+
+	if c == &n.Messages.Total {
+		n.MsgSent, n.MsgRcvd = sent, rcvd
+	}
+
+	return nil
+}
+
+func connValue(n *neigh, get func(c *connStats) int) string {
+	if n.Connections == nil {
+		return ""
+	}
+	return strconv.Itoa(get(n.Connections))
+}
+
+func dropRatioValue(n *neigh) string {
+	ratio, ok := n.dropRatio()
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(ratio, 'f', 2, 64)
+}
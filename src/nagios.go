@@ -0,0 +1,162 @@
+package main
+
+// Nagios/Icinga plugin mode, check -nagios: a one-line status with
+// perfdata, the offending neighbors as long output, and the plugin API
+// exit code. -warning and -critical take alert rules (see alert.go):
+//
+//	BGP CRITICAL - 1 critical, 1 warning, 4 neighbors | neighbors=4;;;0 established=3;;;0 'pe1/198.51.100.1:CUST-A'=26;10:;;0
+//	CRITICAL pe1 198.51.100.5 CUST-B: state != Established (Idle)
+//	WARNING pe1 198.51.100.9 CUST-B: prefixes < 10 (3)
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// plugin API exit codes
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStatus = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// defaultNagiosCritical applies when neither -warning nor -critical is given.
+const defaultNagiosCritical = "state != Established"
+
+// nagiosCheck writes the plugin output for list and returns the exit code.
+// A neighbor matching a critical rule is not reported again as warning.
+// prev is the previous collection for prefix_jump and prefix_delta rules, or nil.
+func nagiosCheck(w io.Writer, list []*neigh, prev map[string]*neigh, warning, critical alertRules, allowEmpty bool) int {
+	var details []string
+	counts := [3]int{}
+	established := 0
+	for _, n := range list {
+		if n.State == "Established" {
+			established++
+		}
+		p := prev[neighKey(n)]
+		status, rule := nagiosOK, (*alertRule)(nil)
+		if rule = firstFiring(critical, n, p); rule != nil {
+			status = nagiosCritical
+		} else if rule = firstFiring(warning, n, p); rule != nil {
+			status = nagiosWarning
+		}
+		if rule == nil {
+			continue
+		}
+		counts[status]++
+		details = append(details, fmt.Sprintf("%s %s %s %s: %s (%s)", nagiosStatus[status], n.Device, n.Addr, n.VRF, rule.spec, nagiosRuleValue(rule, n, p)))
+	}
+
+	code := nagiosOK
+	switch {
+	case counts[nagiosCritical] > 0:
+		code = nagiosCritical
+	case counts[nagiosWarning] > 0:
+		code = nagiosWarning
+	}
+
+	summary := fmt.Sprintf("%d neighbors, %d established", len(list), established)
+	if code != nagiosOK {
+		summary = fmt.Sprintf("%d critical, %d warning, %d neighbors", counts[nagiosCritical], counts[nagiosWarning], len(list))
+	}
+	if len(list) == 0 && !allowEmpty {
+		code = nagiosCritical
+		summary = "no neighbors found"
+	}
+	fmt.Fprintf(w, "BGP %s - %s | %s\n", nagiosStatus[code], summary, nagiosPerfdata(list, established, warning, critical))
+	for _, d := range details {
+		fmt.Fprintln(w, d)
+	}
+	return code
+}
+
+func firstFiring(rules alertRules, n, prev *neigh) *alertRule {
+	for _, r := range rules {
+		if r.fires(n, prev) {
+			return r
+		}
+	}
+	return nil
+}
+
+// nagiosRuleValue returns the value of the rule field for n.
+func nagiosRuleValue(r *alertRule, n, prev *neigh) string {
+	if prev != nil && (r.field == "prefix_jump" || r.field == "prefix_delta") {
+		return prefixJump(prev.Prefixes, n.Prefixes)
+	}
+	if get, ok := alertStringFields[r.field]; ok {
+		return get(n)
+	}
+	if get, ok := alertNumericFields[r.field]; ok {
+		if v, known := get(n); known {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return "?"
+}
+
+// nagiosPerfdata returns the neighbor totals and the prefix count of each
+// neighbor, with the thresholds of the prefixes rules.
+func nagiosPerfdata(list []*neigh, established int, warning, critical alertRules) string {
+	warn, crit := nagiosRange(warning), nagiosRange(critical)
+	perf := []string{
+		fmt.Sprintf("neighbors=%d;;;0", len(list)),
+		fmt.Sprintf("established=%d;;;0", established),
+	}
+	for _, n := range list {
+		label := strings.NewReplacer("'", "", "=", "").Replace(neighKey(n))
+		perf = append(perf, fmt.Sprintf("'%s'=%d;%s;%s;0", label, n.Prefixes, warn, crit))
+	}
+	return strings.Join(perf, " ")
+}
+
+// nagiosRange returns the plugin threshold range of the first prefixes
+// rule using < or >, e.g. "10:" for prefixes < 10, or "" for none.
+func nagiosRange(rules alertRules) string {
+	for _, r := range rules {
+		if r.field != "prefixes" {
+			continue
+		}
+		v := strconv.FormatFloat(r.value, 'f', -1, 64)
+		switch r.op {
+		case "<":
+			return v + ":"
+		case ">":
+			return v
+		}
+	}
+	return ""
+}
+
+// nagiosArg reports whether args select -nagios, before they are parsed
+// (and possibly fail to): flag values and file names are looked at too.
+func nagiosArg(args []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		name := strings.TrimLeft(a, "-")
+		if name == "nagios" {
+			return true
+		}
+		if v := strings.TrimPrefix(name, "nagios="); v != name {
+			on, err := strconv.ParseBool(v)
+			return on || err != nil
+		}
+	}
+	return false
+}
+
+// nagiosFatal reports err as UNKNOWN, since the exit code 1 of fatalf means
+// WARNING to the monitoring system.
+func nagiosFatal(err error) {
+	fmt.Printf("BGP UNKNOWN - %v\n", err)
+	os.Exit(nagiosUnknown)
+}
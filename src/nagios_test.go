@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNagiosCheck(t *testing.T) {
+	up := &neigh{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", State: "Established", Prefixes: 26}
+	few := &neigh{Device: "pe1", Addr: "198.51.100.9", VRF: "CUST-B", State: "Established", Prefixes: 3}
+	down := &neigh{Device: "pe1", Addr: "198.51.100.5", VRF: "CUST-B", State: "Idle"}
+	cases := []struct {
+		name              string
+		list              []*neigh
+		warning, critical []string
+		allowEmpty        bool
+		code              int
+		status            string   // first line up to the perfdata
+		details, perfdata []string // in the output
+	}{
+		{
+			name:     "ok",
+			list:     []*neigh{up, few},
+			critical: []string{defaultNagiosCritical},
+			code:     nagiosOK,
+			status:   "BGP OK - 2 neighbors, 2 established",
+			perfdata: []string{"neighbors=2;;;0", "established=2;;;0", "'pe1/198.51.100.1:CUST-A'=26;;;0"},
+		},
+		{
+			name:     "warning",
+			list:     []*neigh{up, few},
+			warning:  []string{"prefixes < 10"},
+			critical: []string{"prefixes < 1"},
+			code:     nagiosWarning,
+			status:   "BGP WARNING - 0 critical, 1 warning, 2 neighbors",
+			details:  []string{"WARNING pe1 198.51.100.9 CUST-B: prefixes < 10 (3)"},
+			perfdata: []string{"'pe1/198.51.100.9:CUST-B'=3;10:;1:;0"},
+		},
+		{
+			name:     "critical not reported as warning",
+			list:     []*neigh{up, few, down},
+			warning:  []string{"prefixes < 10"},
+			critical: []string{defaultNagiosCritical},
+			code:     nagiosCritical,
+			status:   "BGP CRITICAL - 1 critical, 1 warning, 3 neighbors",
+			details:  []string{"WARNING pe1 198.51.100.9 CUST-B: prefixes < 10 (3)", "CRITICAL pe1 198.51.100.5 CUST-B: state != Established (Idle)"},
+		},
+		{
+			name:     "empty",
+			critical: []string{defaultNagiosCritical},
+			code:     nagiosCritical,
+			status:   "BGP CRITICAL - no neighbors found",
+		},
+		{
+			name:       "empty allowed",
+			critical:   []string{defaultNagiosCritical},
+			allowEmpty: true,
+			code:       nagiosOK,
+			status:     "BGP OK - 0 neighbors, 0 established",
+		},
+	}
+	for _, c := range cases {
+		var warning, critical alertRules
+		for _, r := range c.warning {
+			if err := warning.Set(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, r := range c.critical {
+			if err := critical.Set(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var out bytes.Buffer
+		code := nagiosCheck(&out, c.list, nil, warning, critical, c.allowEmpty)
+		if code != c.code {
+			t.Errorf("%s: exit code %d, want %d", c.name, code, c.code)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if status := strings.SplitN(lines[0], " | ", 2)[0]; status != c.status {
+			t.Errorf("%s: status %q, want %q", c.name, status, c.status)
+		}
+		if got := strings.Join(lines[1:], "\n"); got != strings.Join(c.details, "\n") {
+			t.Errorf("%s: details:\n%s\nwant:\n%s", c.name, got, strings.Join(c.details, "\n"))
+		}
+		for _, p := range c.perfdata {
+			if !strings.Contains(lines[0], " "+p) {
+				t.Errorf("%s: perfdata without %s: %s", c.name, p, lines[0])
+			}
+		}
+	}
+}
+
+func TestNagiosArg(t *testing.T) {
+	cases := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-nagios"}, true},
+		{[]string{"--nagios", "pe1.txt"}, true},
+		{[]string{"-critical", "prefixes < 1", "-nagios=true"}, true},
+		{[]string{"-nagios=false"}, false},
+		{[]string{"-nagios=bogus"}, true}, // fails parsing, as UNKNOWN
+		{[]string{"-json"}, false},
+		{[]string{"--", "-nagios"}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if got := nagiosArg(c.args); got != c.want {
+			t.Errorf("nagiosArg(%q) = %v, want %v", c.args, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+// last BGP notification sent to and received from the neighbor, decoded
+// to the RFC 4271/4486 error code and subcode:
+//
+//	Last reset 00:42:17, due to BGP Notification sent, hold time expired          (IOS)
+//	Last reset 5w2d, due to BGP Notification received of session 1, Administrative Reset
+//	Last reset 00:00:53, due to BGP Notification sent: hold time expired          (IOS-XR)
+//	Last sent notification:Cease/administrative reset, Last time 1d07h             (EOS)
+//	Last rcvd notification:Cease/peer de-configured, Last time 00:02:41
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type bgpNotification struct {
+	Code    int    `json:"code,omitempty"`    // 0 when the reason was not recognized
+	Subcode int    `json:"subcode,omitempty"` // 0 for unspecific
+	Error   string `json:"error"`             // decoded, e.g. Cease/administrative shutdown, or as reported
+	Age     string `json:"age,omitempty"`     // time since the notification, e.g. 1d07h
+}
+
+type notificationCode struct {
+	name     string
+	subcodes map[int]string
+}
+
+// notificationCodes are the BGP error codes and subcodes (RFC 4271, 4486, 7313, 9234).
+var notificationCodes = map[int]notificationCode{
+	1: {"Message Header Error", map[int]string{1: "connection not synchronized", 2: "bad message length", 3: "bad message type"}},
+	2: {"OPEN Message Error", map[int]string{1: "unsupported version number", 2: "bad peer AS", 3: "bad BGP identifier", 4: "unsupported optional parameter", 6: "unacceptable hold time", 7: "unsupported capability", 11: "role mismatch"}},
+	3: {"UPDATE Message Error", map[int]string{1: "malformed attribute list", 2: "unrecognized well-known attribute", 3: "missing well-known attribute", 4: "attribute flags error", 5: "attribute length error", 6: "invalid ORIGIN attribute", 8: "invalid NEXT_HOP attribute", 9: "optional attribute error", 10: "invalid network field", 11: "malformed AS_PATH"}},
+	4: {"Hold Timer Expired", nil},
+	5: {"Finite State Machine Error", nil},
+	6: {"Cease", map[int]string{1: "maximum number of prefixes reached", 2: "administrative shutdown", 3: "peer de-configured", 4: "administrative reset", 5: "connection rejected", 6: "other configuration change", 7: "connection collision resolution", 8: "out of resources", 9: "hard reset", 10: "BFD down"}},
+	7: {"ROUTE-REFRESH Message Error", map[int]string{1: "invalid message length"}},
+}
+
+// notificationAliases maps wordings that differ from the RFC names.
+var notificationAliases = map[string][2]int{
+	"hold time expired":          {4, 0},
+	"holdtimer expired":          {4, 0},
+	"fsm error":                  {5, 0},
+	"admin shutdown":             {6, 2},
+	"admin reset":                {6, 4},
+	"peer deconfigured":          {6, 3},
+	"peer unconfigured":          {6, 3},
+	"maximum prefixes reached":   {6, 1},
+	"max prefixes exceeded":      {6, 1},
+	"maximum-prefix exceeded":    {6, 1},
+	"connection collision":       {6, 7},
+	"invalid or corrupt as path": {3, 11},
+	"bad as path":                {3, 11},
+}
+
+// notificationName returns the decoded error of code/subcode.
+func notificationName(code, subcode int) string {
+	c, ok := notificationCodes[code]
+	if !ok {
+		return fmt.Sprintf("error %d/%d", code, subcode)
+	}
+	if sub, ok := c.subcodes[subcode]; ok {
+		return c.name + "/" + sub
+	}
+	if subcode != 0 {
+		return fmt.Sprintf("%s/subcode %d", c.name, subcode)
+	}
+	return c.name
+}
+
+// decodeNotification returns the notification for a reported reason:
+// a subcode name (Administrative Reset), code/subcode names
+// (Cease/administrative reset) or numbers (6/4).
+// Unrecognized reasons are kept as reported, with code 0.
+func decodeNotification(reason string) *bgpNotification {
+	reason = strings.TrimSpace(reason)
+	if code, subcode, ok := lookupNotification(reason); ok {
+		return &bgpNotification{Code: code, Subcode: subcode, Error: notificationName(code, subcode)}
+	}
+	return &bgpNotification{Error: reason}
+}
+
+func lookupNotification(reason string) (code, subcode int, ok bool) {
+	s := strings.ToLower(reason)
+	if codeName, subName, found := strings.Cut(s, "/"); found {
+		c, errCode := strconv.Atoi(codeName)
+		sub, errSub := strconv.Atoi(subName)
+		if errCode == nil && errSub == nil {
+			return c, sub, true
+		}
+		s = subName // Cease/administrative reset: the subcode names the code
+		if subName == "" {
+			s = codeName
+		}
+	}
+	if v, found := notificationAliases[s]; found {
+		return v[0], v[1], true
+	}
+	for c, nc := range notificationCodes {
+		if strings.ToLower(nc.name) == s {
+			return c, 0, true
+		}
+		for sub, name := range nc.subcodes {
+			if strings.ToLower(name) == s {
+				return c, sub, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+var resetNotificationPattern = regexp.MustCompile(`^BGP Notification (sent|received)(?: of session \d+)?[,:]\s*(.+)$`)
+
+// finishNotification decodes the reset reason once the block is complete,
+// unless the dialect reported the notification itself.
+func (n *neigh) finishNotification() {
+	m := resetNotificationPattern.FindStringSubmatch(n.ResetReason)
+	if m == nil {
+		return
+	}
+	p := &n.NotificationSent
+	if m[1] == "received" {
+		p = &n.NotificationRcvd
+	}
+	if *p != nil {
+		return
+	}
+	*p = decodeNotification(m[2])
+	if n.LastReset != "never" {
+		(*p).Age = n.LastReset
+	}
+}
+
+var eosNotificationPattern = regexp.MustCompile(`^Last (sent|rcvd) notification:(.+), Last time (\S+)$`)
+
+// parseNotificationLine parses the EOS last notification lines.
+// It returns false for any other line.
+func parseNotificationLine(n *neigh, line string) bool {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "Last sent notification:") && !strings.HasPrefix(s, "Last rcvd notification:") {
+		return false
+	}
+	m := eosNotificationPattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	notif := decodeNotification(m[2])
+	notif.Age = m[3]
+	if m[1] == "sent" {
+		n.NotificationSent = notif
+	} else {
+		n.NotificationRcvd = notif
+	}
+	return true
+}
+
+// lastNotification returns the most recent notification and its
+// direction: sent or received.
+func (n *neigh) lastNotification() (notif *bgpNotification, direction string) {
+	sent, rcvd := n.NotificationSent, n.NotificationRcvd
+	switch {
+	case sent == nil && rcvd == nil:
+		return nil, ""
+	case rcvd == nil:
+		return sent, "sent"
+	case sent == nil:
+		return rcvd, "received"
+	}
+	sentAge, errSent := parseUptime(sent.Age)
+	rcvdAge, errRcvd := parseUptime(rcvd.Age)
+	if errRcvd != nil || (errSent == nil && sentAge < rcvdAge) {
+		return sent, "sent"
+	}
+	return rcvd, "received"
+}
+
+func notificationValue(n *neigh) string {
+	notif, direction := n.lastNotification()
+	if notif == nil {
+		return ""
+	}
+	return direction + " " + notif.Error
+}
+
+func notificationCodeValue(n *neigh) string {
+	notif, _ := n.lastNotification()
+	if notif == nil || notif.Code == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", notif.Code, notif.Subcode)
+}
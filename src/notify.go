@@ -0,0 +1,165 @@
+package main
+
+// session state change notifications posted to a chat webhook after each
+// re-collection (watch, serve), or between runs with -alert-state:
+// slack+https://hooks.slack.com/services/T000/B000/XXXX   Slack incoming webhook
+// teams+https://example.webhook.office.com/webhookb2/...  Teams incoming webhook or Workflows
+//
+// One message per collection lists the changes:
+//
+//	BGP: 2 sessions changed state
+//	pe1 198.51.100.5 vrf CUST-B (AS 65002): Established → Idle, last reset: BGP Notification sent, hold time expired
+//	pe1 203.0.113.9 vrf CUST-B (AS 65003): Active → Established
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxNotifyLines bounds the changes listed in one message.
+const maxNotifyLines = 50
+
+type notifier struct {
+	kind string // slack or teams
+	url  string
+}
+
+// parseNotifier returns the notifier for a slack+https:// or teams+https:// URL.
+func parseNotifier(spec string) (*notifier, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parseNotifier: %v", err)
+	}
+	i := strings.IndexByte(u.Scheme, '+')
+	if i < 0 || u.Host == "" {
+		return nil, fmt.Errorf("parseNotifier: expecting slack+https://host/path or teams+https://host/path: [%s]", spec)
+	}
+	kind, scheme := u.Scheme[:i], u.Scheme[i+1:]
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("parseNotifier: bad scheme: [%s]", u.Scheme)
+	}
+	switch kind {
+	case "slack", "teams":
+	default:
+		return nil, fmt.Errorf("parseNotifier: unknown scheme: [%s] (expecting slack or teams)", kind)
+	}
+	u.Scheme = scheme
+	return &notifier{kind: kind, url: u.String()}, nil
+}
+
+// stateChanges returns the neighbors added, removed or whose state
+// changed between prev and curr. Prefix count changes are left out.
+func stateChanges(prev, curr map[string]*neigh) []*neighChange {
+	var changes []*neighChange
+	for _, c := range tableChanges(prev, curr) {
+		if c.Kind == "changed" && c.Prev.State == c.Neigh.State {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// notifyLine describes a state change:
+// pe1 198.51.100.5 vrf CUST-B (AS 65002): Established → Idle, last reset: Peer closed the session
+func notifyLine(c *neighChange) string {
+	n := c.Neigh
+	from, to := "new", n.State
+	switch c.Kind {
+	case "removed":
+		from, to = n.State, "gone"
+	case "changed":
+		from = c.Prev.State
+	}
+	who := n.Addr
+	if n.Device != "" {
+		who = n.Device + " " + who
+	}
+	line := fmt.Sprintf("%s vrf %s (AS %s): %s → %s", who, n.VRF, n.RemoteAS, from, to)
+	if c.Kind != "removed" && n.ResetReason != "" {
+		line += ", last reset: " + n.ResetReason
+	}
+	return line
+}
+
+// notifyChanges posts the state changes between prev and curr.
+// Nothing is posted on the first collection, or without changes.
+func (nt *notifier) notifyChanges(prev, curr map[string]*neigh) {
+	if prev == nil {
+		return
+	}
+	changes := stateChanges(prev, curr)
+	if len(changes) == 0 {
+		return
+	}
+	title := fmt.Sprintf("BGP: %d sessions changed state", len(changes))
+	if len(changes) == 1 {
+		title = "BGP: 1 session changed state"
+	}
+	var lines []string
+	for i, c := range changes {
+		if i == maxNotifyLines {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(changes)-i))
+			break
+		}
+		lines = append(lines, notifyLine(c))
+	}
+
+	var body []byte
+	var err error
+	if nt.kind == "slack" {
+		body, err = slackMessage(title, lines)
+	} else {
+		body, err = teamsMessage(title, lines)
+	}
+	if err != nil {
+		errorf("notifier: %v", err)
+		return
+	}
+	if err := postAlert(nt.url, body); err != nil {
+		errorf("notifier: %s: %v", nt.kind, err)
+		return
+	}
+	infof("notifier: %s: posted %d state changes", nt.kind, len(changes))
+}
+
+// slackMessage returns the incoming webhook payload, escaped for mrkdwn.
+func slackMessage(title string, lines []string) ([]byte, error) {
+	esc := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	text := "*" + esc.Replace(title) + "*"
+	for _, l := range lines {
+		text += "\n" + esc.Replace(l)
+	}
+	return json.Marshal(map[string]string{"text": text})
+}
+
+// teamsMessage returns an Adaptive Card message, accepted by both the
+// incoming webhooks and the Workflows webhook trigger.
+func teamsMessage(title string, lines []string) ([]byte, error) {
+	type block struct {
+		Type   string `json:"type"`
+		Text   string `json:"text"`
+		Weight string `json:"weight,omitempty"`
+		Size   string `json:"size,omitempty"`
+		Wrap   bool   `json:"wrap"`
+	}
+	body := []block{{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Wrap: true}}
+	for _, l := range lines {
+		body = append(body, block{Type: "TextBlock", Text: l, Wrap: true})
+	}
+	msg := map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+	return json.Marshal(msg)
+}
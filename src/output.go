@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+func writeJSON(w io.Writer, list []*neigh) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		return fmt.Errorf("writeJSON: %v", err)
+	}
+	return nil
+}
+
+// writeCSV writes a header line with column names, then one record per neighbor.
+func writeCSV(w io.Writer, cols []*column, list []*neigh) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(cols))
+	for i, c := range cols {
+		record[i] = c.name
+	}
+	cw.Write(record)
+	for _, n := range list {
+		for i, c := range cols {
+			record[i] = c.value(n)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writeCSV: %v", err)
+	}
+	return nil
+}
+
+// streamOutput writes neighbors as they are parsed from r.
+// JSON output is written as one object per line.
+func streamOutput(r io.Reader, w io.Writer, opts parseOptions, cols []*column, filter *neighFilter, jsonOutput, csvOutput, yamlOutput bool) error {
+	var write func(n *neigh) error
+
+	switch {
+	case yamlOutput:
+		write = func(n *neigh) error {
+			var buf bytes.Buffer
+			yamlSeqItem(&buf, reflect.ValueOf(n), 0)
+			_, err := w.Write(buf.Bytes())
+			return err
+		}
+	case jsonOutput:
+		enc := json.NewEncoder(w)
+		write = func(n *neigh) error { return enc.Encode(n) }
+	case csvOutput:
+		cw := csv.NewWriter(w)
+		record := make([]string, len(cols))
+		for i, c := range cols {
+			record[i] = c.name
+		}
+		cw.Write(record)
+		write = func(n *neigh) error {
+			for i, c := range cols {
+				record[i] = c.value(n)
+			}
+			cw.Write(record)
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		writeTableRow(w, cols, func(c *column) string { return c.header })
+		write = func(n *neigh) error {
+			fmt.Fprintln(w, neighRow(cols, n))
+			return nil
+		}
+	}
+
+	err := parseStream(r, opts, func(n *neigh) error {
+		if !filter.match(n) {
+			return nil
+		}
+		return write(filter.afi.selectAFI(n))
+	})
+	if err != nil {
+		return fmt.Errorf("streamOutput: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,707 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// parseOptions control parsing behavior.
+type parseOptions struct {
+	strict      bool    // stop at the first malformed line
+	dialect     dialect // nil for the auto dialect
+	dialectName string
+	device      string // device label when the capture has no prompt
+	textfsm     bool   // input is ntc-templates/TextFSM JSON instead of command output
+
+	backupDir string // read captures from a RANCID or Oxidized tree instead of files
+
+	report func(r captureReport) // called with the coverage of each capture, if set
+
+	enrich string // command adding extra fields to each table collected, see enrich.go
+
+	maxLineLength int // longer lines are skipped, 0 for scanMaxLineLength
+}
+
+type neighScanner struct {
+	opts    parseOptions
+	emit    func(n *neigh) error // called when a neighbor block ends
+	emitErr error                // error from emit, always aborts parsing
+	errors  []error              // errors skipped in lenient mode
+	curr    *neigh
+	section string // current subsection within neighbor block
+	lines   int
+	vpn     bool   // current neighbor block has a VPN address family
+	af      string // current address family within neighbor block
+	block   blockState
+
+	vpnInput       bool   // input mentions vpnv4/vpnv6 (command echo or address family header)
+	summaryVRF     string // vrf selected by summary command echo
+	summaryAF      string // address family selected by summary command echo
+	summaryWrapped string // summary row address waiting for the rest of the row
+	device         string // device from the first prompt line (hostname#)
+	localRouterID  string // from "BGP router identifier" line
+	localAS        string // from "BGP router identifier" line
+
+	vrfs    map[string]*vrfInfo // from show vrf detail or route distinguisher headers
+	vrfName string              // vrf of the current show vrf detail section
+	vrfRTs  *[]string           // route target list being read
+
+	interned internTable // repeated neighbor fields, see internNeighbor
+
+	platform string // detected from the capture, see detectPlatform
+	nonBlank int    // non-blank lines read
+	ignored  int    // non-blank lines no rule used
+
+	repairs lineRepairs // lines skipped or repaired by scanLines
+}
+
+// VRF reported for neighbors without vrf in the header
+const (
+	vrfGlobalVPN = "--"      // show bgp vpnv4 unicast all neighbors
+	vrfDefault   = "default" // show ip bgp neighbors
+)
+
+const sectionCapabilities = "capabilities"
+
+// parseFile parses the capture at path, see parseInput.
+func parseFile(path string, opts parseOptions) (map[string]*neigh, []error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parseFile: %v", err)
+	}
+	defer f.Close()
+	table, skipped, err := parseInput(f, path, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parseFile: %v", err)
+	}
+	return table, skipped, nil
+}
+
+// parseFiles parses every capture and merges the neighbor tables, also
+// returning the malformed lines skipped in all of them.
+// Neighbors are tagged with the device from the capture prompt,
+// falling back to the file name without extension. Summary and
+// detailed captures of a device are merged, see mergeNeighbor.
+func parseFiles(paths []string, opts parseOptions) (map[string]*neigh, []error, error) {
+	table := map[string]*neigh{}
+	var skipped []error
+	for _, path := range paths {
+		fileOpts := opts
+		fileOpts.device = deviceFromPath(path)
+		t, s, err := parseFile(path, fileOpts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parseFiles: %v", err)
+		}
+		for _, n := range t {
+			mergeTable(table, n)
+		}
+		skipped = append(skipped, s...)
+	}
+	mergeSummaryRows(table)
+	return table, skipped, nil
+}
+
+func deviceFromPath(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// promptDevice returns the hostname from a prompt line like "pe1#show ...",
+// "pe1>" or "RP/0/RSP0/CPU0:pe2#show ..." (IOS-XR).
+func promptDevice(line string) string {
+	m := promptPattern.FindStringSubmatch(trimXRPrompt(line))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// promptCommand returns the command typed at a prompt line, "" for other
+// lines and for a bare prompt (an empty enter, common in paginated captures).
+func promptCommand(line string) string {
+	line = trimXRPrompt(line)
+	loc := promptPattern.FindStringIndex(line)
+	if loc == nil {
+		return ""
+	}
+	return strings.TrimSpace(line[loc[1]:])
+}
+
+var promptPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9._-]*)(\([^)]*\))?[#>]`)
+
+// trimXRPrompt drops the route processor part of an IOS-XR prompt.
+func trimXRPrompt(line string) string {
+	if loc := xrPromptPattern.FindStringIndex(line); loc != nil {
+		return line[loc[1]:]
+	}
+	return line
+}
+
+// parseInput reads a capture from r and returns the neighbor table and
+// the errors of the malformed lines skipped, labeled. In strict mode
+// parsing stops at the first malformed line and only its error is
+// returned, without a table. Otherwise malformed lines are logged, skipped
+// and counted in a warning. Read errors are returned either way.
+func parseInput(r io.Reader, label string, opts parseOptions) (map[string]*neigh, []error, error) {
+	debugf("main: reading from %s", label)
+
+	table := map[string]*neigh{}
+
+	scanner := newNeighScanner(opts, func(n *neigh) error {
+		mergeTable(table, n)
+		return nil
+	})
+
+	err := scanner.scan(r)
+	scanner.applyVRFs(table)
+	mergeSummaryRows(table)
+	report := scanner.report(label, len(table))
+	if err != nil {
+		err = fmt.Errorf("%s: %v", label, err)
+	}
+
+	debugf("main: reading from %s: done: %d lines", label, scanner.lines)
+
+	infof("main: found %d neighbors", len(table))
+	debugf("main: %v", report)
+	if report.Neighbors == 0 && report.Lines > 0 {
+		warnf("main: %s: no neighbors in %d lines (platform %s, dialect %s), see -dialect", label, report.Lines, report.Platform, report.Dialect)
+	}
+	if opts.report != nil {
+		opts.report(report)
+	}
+
+	if s := scanner.repairs.String(); s != "" {
+		warnf("main: %s: %s", label, s)
+	}
+	var skipped []error
+	for _, e := range scanner.errors {
+		skipped = append(skipped, fmt.Errorf("%s: %v", label, e))
+	}
+	if len(skipped) > 0 {
+		warnf("main: %s: skipped %d malformed lines (use -strict to stop at the first one)", label, len(skipped))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return table, skipped, nil
+}
+
+// parseStream parses a capture from r and calls fn for each neighbor
+// as soon as its block ends, without keeping the whole table in memory.
+// Parsing stops at the first error returned by fn.
+// In lenient mode malformed lines are logged and skipped, see parseInput.
+func parseStream(r io.Reader, opts parseOptions, fn func(n *neigh) error) error {
+	return newNeighScanner(opts, fn).scan(r)
+}
+
+func newNeighScanner(opts parseOptions, emit func(n *neigh) error) *neighScanner {
+	if opts.dialect == nil {
+		opts.dialect = dialects[dialectAuto]
+	}
+	return &neighScanner{opts: opts, emit: emit, interned: internTable{}}
+}
+
+// scan feeds every line from r to the dialect, then flushes the last neighbor.
+func (scanner *neighScanner) scan(r io.Reader) error {
+	if scanner.opts.textfsm {
+		return scanner.scanTextFSM(r)
+	}
+
+	parse := func(line string, lineNumber int) error {
+		err := scanner.parseLine(line, lineNumber)
+		if err == nil || scanner.opts.strict || scanner.emitErr != nil {
+			return err
+		}
+		warnf("scan: skipping: %v", err)
+		scanner.errors = append(scanner.errors, err)
+		return nil
+	}
+
+	var joiner headerJoiner
+	consume := func(line string, lineNumber int) error {
+		scanner.lines++
+		line = cleanLine(line)
+		if strings.TrimSpace(line) != "" {
+			scanner.nonBlank++
+		}
+		if scanner.platform == "" && scanner.lines <= maxPlatformLines {
+			scanner.platform = detectPlatform(line)
+		}
+		if scanner.device == "" && scanner.curr == nil {
+			scanner.device = promptDevice(line)
+		}
+		return joiner.feed(line, lineNumber, parse)
+	}
+
+	maxLen := scanner.opts.maxLineLength
+	if maxLen <= 0 {
+		maxLen = scanMaxLineLength
+	}
+	rep, err := scanLines(r, maxLen, scanner.opts.strict, consume)
+	scanner.repairs = rep
+	if err != nil {
+		if scanner.emitErr == nil {
+			scanner.flush() // keep the neighbor being parsed
+		}
+		return err
+	}
+	if err := joiner.flush(parse); err != nil {
+		return err
+	}
+
+	return scanner.flush()
+}
+
+// parseLine feeds one line to the dialect.
+func (scanner *neighScanner) parseLine(line string, lineNumber int) error {
+	return scanner.opts.dialect.parseLine(scanner, line, lineNumber)
+}
+
+// flush emits the neighbor currently being parsed, if any.
+func (scanner *neighScanner) flush() error {
+	n := scanner.curr
+	vpn := scanner.vpn
+	scanner.curr = nil
+	scanner.section = ""
+	scanner.vpn = false
+	scanner.af = ""
+	scanner.block = blockNone
+	if n == nil {
+		return nil
+	}
+	n.finishGR()
+	n.finishNotification()
+	if n.VRF == "" {
+		n.VRF = vrfDefault
+		if vpn || scanner.vpnInput {
+			n.VRF = vrfGlobalVPN
+		}
+	}
+	return scanner.emitNeighbor(n)
+}
+
+// startNeighbor flushes the current neighbor and starts a new one.
+// An empty vrf is resolved when the block ends.
+func (scanner *neighScanner) startNeighbor(addr string) error {
+	if err := scanner.flush(); err != nil {
+		return err
+	}
+	scanner.curr = &neigh{Addr: addr}
+	return nil
+}
+
+func (scanner *neighScanner) emitNeighbor(n *neigh) error {
+	if n.LocalRouterID == "" {
+		n.LocalRouterID = scanner.localRouterID
+	}
+	if n.LocalAS == "" {
+		n.LocalAS = scanner.localAS
+	}
+	if n.Device == "" {
+		n.Device = scanner.device
+		if n.Device == "" {
+			n.Device = scanner.opts.device
+		}
+	}
+	scanner.applyVRF(n)
+	scanner.interned.internNeighbor(n)
+	if err := scanner.emit(n); err != nil {
+		scanner.emitErr = err
+		return err
+	}
+	return nil
+}
+
+//BGP neighbor is 1.1.1.1,  vrf VRFNAME,  remote AS 65000, external link
+// Description: CIRCUIT-ID
+//  BGP state = Established, up for 5w2d
+//  Session state = Established, up for 1y8w
+//  Neighbor capabilities:
+//    Route refresh: advertised and received(new)
+//    Address family VPNv4 Unicast: advertised and received
+//(...)
+//    Prefixes Current:               0         26 (Consumes 2080 bytes)
+//(...)
+//  Last reset 5w2d, due to Peer closed the session of session 1
+//  Fall over configured for session
+//  Using BFD to detect fast fallover (single-hop)
+
+// parseNeighborHeader finds the fields of a "BGP neighbor is" line by
+// keyword, since the vrf and local AS parts are optional:
+// BGP neighbor is *10.1.1.5,  vrf CUST-A,  remote AS 65001, external link
+func parseNeighborHeader(f lineFields) (addr, vrf, asn string, err error) {
+	if err := f.want(4, "bgp neighbor line"); err != nil {
+		return "", "", "", err
+	}
+	addr = f.word(3)
+	vrf = f.after("vrf") // empty for the global table, resolved when block ends
+	asn = f.after("remote", "AS")
+	if asn == "" {
+		return "", "", "", fmt.Errorf("bad bgp neighbor line: missing remote AS")
+	}
+	return addr, vrf, asn, nil
+}
+
+// inheritedGroup returns the session template a neighbor inherits from:
+// Inherits from template CUST-SESSION for session parameters
+// Inherits from neighbor-group NG-CUST    (IOS-XR, also session-group)
+func inheritedGroup(line string) string {
+	f := splitFields(line)
+	for _, kind := range []string{"template", "session-group", "neighbor-group"} {
+		if group := f.after("from", kind); group != "" {
+			return group
+		}
+	}
+	return ""
+}
+
+// blockState is the part of a neighbor block being parsed. IOS prints the
+// parts in this order; a line of an earlier part means the header of the
+// next neighbor was lost (truncated or interleaved capture).
+type blockState int
+
+const (
+	blockNone          blockState = iota // outside neighbor blocks
+	blockSession                         // header through message statistics
+	blockAddressFamily                   // " For address family:" sections
+	blockCounters                        // connections, last reset, BFD
+	blockTCP                             // TCP connection details
+)
+
+var blockNames = [...]string{"none", "session", "address family", "session counters", "TCP"}
+
+// enterBlock moves the current neighbor block forward to part. A line
+// of a part already passed ends the block: its fields would otherwise be
+// attributed to the wrong neighbor.
+func (scanner *neighScanner) enterBlock(part blockState, what, line string, lineNum int) error {
+	if scanner.curr == nil {
+		return fmt.Errorf("lineParser: hit %s without neighbor: line=%d [%s]", what, lineNum, line)
+	}
+	if part < scanner.block {
+		err := fmt.Errorf("lineParser: hit %s after the %s section of neighbor %s (missing neighbor header?): line=%d [%s]", what, blockNames[scanner.block], scanner.curr.Addr, lineNum, line)
+		if flushErr := scanner.flush(); flushErr != nil {
+			return flushErr
+		}
+		return err
+	}
+	if part > blockAddressFamily {
+		scanner.af = "" // address family sections ended
+	}
+	scanner.block = part
+	return nil
+}
+
+// lineRule parses the lines starting with prefix, within a part of the
+// neighbor block.
+type lineRule struct {
+	prefix string
+	what   string // for errors
+	part   blockState
+	parse  func(scanner *neighScanner, line string, lineNum int) error
+}
+
+// blockRules are tried in order, after the neighbor header.
+var blockRules = []lineRule{
+	{" Member of peer-group ", "peer-group", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		group := splitFields(line).after("peer-group")
+		if group == "" {
+			return fmt.Errorf("lineParser: bad peer-group line: line=%d [%s]", lineNum, line)
+		}
+		scanner.curr.PeerGroup = group
+		return nil
+	}},
+	{" Inherits from ", "template", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		if group := inheritedGroup(line); group != "" && scanner.curr.PeerGroup == "" {
+			scanner.curr.PeerGroup = group
+		}
+		return nil
+	}},
+	{" Belongs to the subnet range group: ", "listen range", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.ListenRange = strings.TrimSpace(line[len(" Belongs to the subnet range group: "):])
+		scanner.curr.Dynamic = true
+		return nil
+	}},
+	{" Description: ", "description", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.Description = strings.TrimSpace(line[len(" Description: "):])
+		return nil
+	}},
+	{"  BGP state = ", "state", blockSession, parseStateLine},
+	{"  Session state = ", "state", blockSession, parseStateLine},
+	{"  Neighbor capabilities:", "capabilities", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.Capabilities = nil
+		scanner.curr.AddressFamilies = nil
+		scanner.section = sectionCapabilities
+		return nil
+	}},
+	{"  Message statistics:", "message statistics", blockSession, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.Messages = &msgStats{}
+		scanner.section = sectionMessages
+		return nil
+	}},
+	{" For address family: ", "address family", blockAddressFamily, func(scanner *neighScanner, line string, lineNum int) error {
+		af := strings.TrimSpace(line[len(" For address family: "):])
+		if strings.HasPrefix(af, "VPNv4") || strings.HasPrefix(af, "VPNv6") {
+			scanner.vpn = true
+		}
+		scanner.af = af
+		return nil
+	}},
+	{"    Prefixes Current:", "prefix count", blockAddressFamily, func(scanner *neighScanner, line string, lineNum int) error {
+		f := splitFields(line)
+		if err := f.want(4, "bgp prefixes line"); err != nil {
+			return fmt.Errorf("lineParser: %v: line=%d [%s]", err, lineNum, line)
+		}
+		count, err := f.number(3, "bgp prefixes count")
+		if err != nil {
+			return fmt.Errorf("lineParser: %v: line=%d [%s]", err, lineNum, line)
+		}
+		if scanner.af == "" {
+			scanner.curr.Prefixes = count
+			return nil
+		}
+		scanner.curr.afPolicy(scanner.af).Prefixes = count
+		scanner.curr.sumPrefixes() // over address families, see afi.go
+		return nil
+	}},
+	{"  Connections established ", "connection counters", blockCounters, func(scanner *neighScanner, line string, lineNum int) error {
+		parseConnectionsLine(scanner.curr, line)
+		return nil
+	}},
+	{"  Last reset ", "last reset", blockCounters, func(scanner *neighScanner, line string, lineNum int) error {
+		reset := strings.TrimSpace(line[len("  Last reset "):])
+		if i := strings.Index(reset, ", due to "); i >= 0 {
+			scanner.curr.LastReset = reset[:i]
+			scanner.curr.ResetReason = reset[i+len(", due to "):]
+		} else {
+			scanner.curr.LastReset = reset
+			scanner.curr.ResetReason = ""
+		}
+		return nil
+	}},
+	{"  Using BFD to detect fast fallover", "bfd", blockCounters, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.BFD = true
+		scanner.curr.BFDMode = ""
+		if i := strings.IndexByte(line, '('); i >= 0 {
+			if j := strings.IndexByte(line[i:], ')'); j >= 0 {
+				scanner.curr.BFDMode = line[i+1 : i+j]
+			}
+		}
+		return nil
+	}},
+	{"  Fall over configured for session", "fall over", blockCounters, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.FallOver = true
+		return nil
+	}},
+	{"  TCP session must be opened passively", "passive session", blockNone, func(scanner *neighScanner, line string, lineNum int) error {
+		scanner.curr.Passive = true
+		return nil
+	}},
+}
+
+// parseStateLine parses "  BGP state = Established, up for 5w2d".
+func parseStateLine(scanner *neighScanner, line string, lineNum int) error {
+	f := splitFields(line)
+	if err := f.want(4, "bgp state line"); err != nil {
+		return fmt.Errorf("lineParser: %v: line=%d [%s]", err, lineNum, line)
+	}
+	scanner.curr.State = f.word(3)
+	uptime := f.word(6) // "BGP state = Established, up for 5w2d"
+	if marker := f.word(4); strings.HasPrefix(marker, "(") {
+		applyStateMarker(scanner.curr, marker) // "BGP state = Idle (Admin)"
+		uptime = f.word(7)
+	}
+	if uptime == "" {
+		uptime = "?"
+	}
+	scanner.curr.setUptime(uptime)
+	return nil
+}
+
+// lineParser is a state machine over the capture: outside neighbor
+// blocks (summary tables, vrf detail, prompts) and, within a block, over
+// its parts in order (see blockState) and their subsections.
+func lineParser(scanner *neighScanner, line string, lineNum int) error {
+
+	if scanner.curr == nil && scanner.section == "" {
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "vpnv4") || strings.Contains(lower, "vpnv6") {
+			scanner.vpnInput = true
+		}
+		if strings.Contains(lower, "show ") && strings.Contains(lower, "summary") {
+			scanner.summaryVRF = summaryCommandVRF(line)
+			scanner.summaryAF = summaryCommandAF(line)
+		}
+	}
+
+	if id, asn, ok := parseRouterIdentifier(line); ok {
+		scanner.localRouterID = id
+		scanner.localAS = asn
+		return nil
+	}
+
+	if ok, err := parseVRFLine(scanner, line); ok {
+		return err
+	}
+
+	if isSummaryHeader(line) {
+		if err := scanner.flush(); err != nil {
+			return err
+		}
+		scanner.section = sectionSummary
+		return nil
+	}
+
+	if scanner.section == sectionSummary {
+		return parseSummaryLine(scanner, line, lineNum)
+	}
+
+	if promptCommand(line) != "" {
+		return scanner.flush() // next command: the neighbor block ended
+	}
+
+	if strings.HasPrefix(line, "BGP neighbor is ") {
+
+		f := splitFields(line)
+		id, vrf, asn, err := parseNeighborHeader(f)
+		if err != nil {
+			return fmt.Errorf("lineParser: %v: line=%d [%s]", err, lineNum, line)
+		}
+		return scanner.startBlock(f, id, vrf, asn)
+	}
+
+	if scanner.section != "" && lineIndent(line) <= 2 && strings.TrimSpace(line) != "" {
+		scanner.section = "" // subsection ended
+	}
+
+	switch scanner.section {
+	case sectionCapabilities:
+		parseCapabilityLine(scanner.curr, line)
+		return nil
+	case sectionMessages:
+		return parseMessageLine(scanner.curr, line, lineNum)
+	}
+
+	for _, r := range blockRules {
+		if !strings.HasPrefix(line, r.prefix) {
+			continue
+		}
+		part := r.part
+		if part == blockNone {
+			part = scanner.block // valid in any part
+		}
+		if err := scanner.enterBlock(part, r.what, line, lineNum); err != nil {
+			return err
+		}
+		return r.parse(scanner, line, lineNum)
+	}
+
+	if strings.TrimSpace(line) == "Administratively shut down" {
+		if scanner.curr == nil {
+			return fmt.Errorf("lineParser: hit shutdown without neighbor: line=%d [%s]", lineNum, line)
+		}
+		scanner.curr.Shutdown = true
+		return nil
+	}
+
+	if scanner.curr == nil {
+		scanner.ignore(line) // outside neighbor blocks
+		return nil
+	}
+
+	if parseRRLine(scanner.curr, scanner.af, line) {
+		return nil
+	}
+
+	if scanner.block == blockAddressFamily && scanner.af != "" && parsePolicyLine(scanner.curr, scanner.af, line) {
+		return nil
+	}
+
+	if parseRouterIDLine(scanner.curr, line) {
+		return nil
+	}
+
+	if parseEndpointLine(scanner.curr, line) || parseTCPLine(scanner.curr, line) {
+		if lineIndent(line) == 0 {
+			scanner.block = blockTCP
+			scanner.af = ""
+		}
+		return nil
+	}
+
+	if parseGRLine(scanner.curr, line) || parseTimersLine(scanner.curr, line) || parseNotificationLine(scanner.curr, line) || parseAuthLine(scanner.curr, line) {
+		return nil
+	}
+
+	scanner.ignore(line)
+	return nil // no error
+}
+
+// startBlock starts the block of neighbor id from the fields of its header line.
+func (scanner *neighScanner) startBlock(f lineFields, id, vrf, asn string) error {
+	dynamic := strings.HasPrefix(id, "*") // created from a listen range
+	id = strings.TrimPrefix(id, "*")
+
+	if err := scanner.startNeighbor(id); err != nil {
+		return err
+	}
+
+	scanner.block = blockSession
+	scanner.curr.VRF = vrf
+	scanner.curr.RemoteAS = asn
+	scanner.curr.LocalAS = headerLocalAS(f)
+	scanner.curr.Link = headerLink(f)
+	scanner.curr.Confederation = strings.Contains(scanner.curr.Link, "confed")
+	scanner.curr.Dynamic = dynamic
+	return nil
+}
+
+// applyStateMarker records the state suffix of the state line or summary
+// row: "(Admin)" for a neighbor shut down, "(passive)" for one waiting for
+// the peer to connect. Other markers, e.g. "(PfxCt)", are kept as part of
+// the state.
+func applyStateMarker(n *neigh, marker string) {
+	marker = strings.TrimRight(marker, ",")
+	switch marker {
+	case "(Admin)":
+		n.Shutdown = true
+	case "(passive)":
+		n.Passive = true
+	default:
+		n.State += " " + marker
+	}
+}
+
+func tableKey(device, addr, vrf string) string {
+	if device == "" {
+		return fmt.Sprintf("%s:%s", addr, vrf)
+	}
+	return fmt.Sprintf("%s/%s:%s", device, addr, vrf)
+}
+
+func neighKey(n *neigh) string {
+	return tableKey(n.Device, n.Addr, n.VRF)
+}
+
+type lineConsumerFunc func(line string, lineNumber int) error
+
+// Capture lines longer than bufio.MaxScanTokenSize are common in large
+// route reflector captures (wrapped community lists, unpaginated output).
+const (
+	scanBufferSize    = 256 * 1024
+	scanMaxLineLength = 16 * 1024 * 1024
+)
+
+// scanFile calls consumer for each line of r, see scanLines.
+func scanFile(r io.Reader, consumer lineConsumerFunc) error {
+	rep, err := scanLines(r, scanMaxLineLength, false, consumer)
+	if s := rep.String(); s != "" {
+		warnf("scanFile: %s", s)
+	}
+	return err
+}
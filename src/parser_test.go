@@ -0,0 +1,197 @@
+package main
+
+// fuzz targets for the parser, seeded from the captures in testdata/. A
+// malformed capture must end up as parse errors, never as a panic:
+//
+//	go test -run ^$ -fuzz FuzzLineParser -fuzztime 1m
+//	go test -run ^$ -fuzz FuzzDialectNXOS -fuzztime 1m
+//
+// and a benchmark on a generated capture of a big route reflector:
+//
+//	go test -run ^$ -bench Parse -benchmem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const malformedCapture = `BGP neighbor is 198.51.100.1,  vrf CUST-A,  remote AS 65001, external link
+  BGP state = Established, up for 1w1d
+    Prefixes Current:           120         abc (Consumes 12416 bytes)
+BGP neighbor is 198.51.100.2,  vrf CUST-A,  remote AS 65002, external link
+  BGP state = Established, up for 1w1d
+`
+
+func TestParseInputMalformed(t *testing.T) {
+	cases := []struct {
+		strict    bool
+		neighbors int
+		skipped   int
+		fail      bool
+	}{
+		{strict: false, neighbors: 2, skipped: 1},
+		{strict: true, fail: true},
+	}
+	for _, c := range cases {
+		table, skipped, err := parseInput(strings.NewReader(malformedCapture), "pe1.txt", parseOptions{strict: c.strict})
+		if (err != nil) != c.fail {
+			t.Errorf("strict=%v: error %v, want failure %v", c.strict, err, c.fail)
+		}
+		if len(table) != c.neighbors || len(skipped) != c.skipped {
+			t.Errorf("strict=%v: got %d neighbors, %d skipped, want %d, %d", c.strict, len(table), len(skipped), c.neighbors, c.skipped)
+		}
+		for _, e := range skipped {
+			if !strings.HasPrefix(e.Error(), "pe1.txt: ") || !strings.Contains(e.Error(), "line=3") {
+				t.Errorf("strict=%v: skipped error without label or line: %v", c.strict, e)
+			}
+		}
+	}
+}
+
+// addCaptureSeeds adds every capture in testdata/ to the seed corpus.
+func addCaptureSeeds(f *testing.F) {
+	captures, err := filepath.Glob("testdata/*.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, capture := range captures {
+		data, err := os.ReadFile(capture)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// FuzzLineParser feeds the lines straight to lineParser, without the line
+// repairs and header joining of scanLines.
+func FuzzLineParser(f *testing.F) {
+	addCaptureSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		scanner := newNeighScanner(parseOptions{}, func(n *neigh) error { return nil })
+		for i, line := range strings.Split(string(data), "\n") {
+			lineParser(scanner, line, i+1)
+		}
+		scanner.flush()
+	})
+}
+
+// fuzzDialect parses the input with dialect name, in lenient and strict mode.
+func fuzzDialect(f *testing.F, name string) {
+	d, err := lookupDialect(name)
+	if err != nil {
+		f.Fatal(err)
+	}
+	addCaptureSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, strict := range []bool{false, true} {
+			opts := parseOptions{dialect: d, dialectName: name, strict: strict}
+			parseInput(bytes.NewReader(data), "fuzz", opts)
+		}
+	})
+}
+
+func FuzzDialectAuto(f *testing.F)  { fuzzDialect(f, dialectAuto) }
+func FuzzDialectIOS(f *testing.F)   { fuzzDialect(f, "ios") }
+func FuzzDialectEOS(f *testing.F)   { fuzzDialect(f, "eos") }
+func FuzzDialectIOSXR(f *testing.F) { fuzzDialect(f, "iosxr") }
+func FuzzDialectNXOS(f *testing.F)  { fuzzDialect(f, "nxos") }
+
+// benchNeighbor is a neighbor block of "show bgp vpnv4 unicast all
+// neighbors" on a route reflector, with the address, vrf and counts
+// varying per neighbor.
+const benchNeighbor = `BGP neighbor is 10.%d.%d.%d,  vrf CUST-%03d,  remote AS %d, external link
+ Description: SITE-%d
+  BGP version 4, remote router ID 10.%d.%d.%d
+  BGP state = Established, up for 1w1d
+  Last read 00:00:01, last write 00:00:07, hold time is 180, keepalive interval is 60 seconds
+  Neighbor capabilities:
+    Route refresh: advertised and received(new)
+    Four-octets ASN Capability: advertised and received
+    Address family IPv4 Unicast: advertised and received
+    Graceful Restart Capability: advertised and received
+  Message statistics:
+    InQ depth is 0
+    OutQ depth is 0
+
+                         Sent       Rcvd
+    Opens:                  1          1
+    Notifications:          0          0
+    Updates:             1037       %d
+    Keepalives:         50001      50011
+    Route Refresh:          0          0
+    Total:              51039      52065
+
+ For address family: IPv4 Unicast
+  Route map for incoming advertisements is RM-CUST-IN
+  Route map for outgoing advertisements is RM-CUST-OUT
+                                 Sent       Rcvd
+  Prefix activity:               ----       ----
+    Prefixes Current:           120         %d (Consumes 12416 bytes)
+
+  Connections established 2; dropped 1
+  Last reset 1w1d, due to Peer closed the session
+Local host: 10.0.0.1, Local port: 179
+Foreign host: 10.%d.%d.%d, Foreign port: %d
+
+`
+
+// benchCapture returns a capture of at least lines lines.
+func benchCapture(lines int) []byte {
+	var b bytes.Buffer
+	b.WriteString("rr1#show bgp vpnv4 unicast all neighbors\n")
+	for i, n := 0, 1; n < lines; i++ {
+		x, y, z := i>>16&255, i>>8&255, i&255
+		fmt.Fprintf(&b, benchNeighbor, x, y, z, i%200, 65000+i%1000, i, x, y, z, i%5000, i%300, x, y, z, 30000+i%30000)
+		n += strings.Count(benchNeighbor, "\n")
+	}
+	return b.Bytes()
+}
+
+// BenchmarkParse parses a 1M-line capture into a table, reporting the
+// heap taken by the table per capture byte.
+func BenchmarkParse(b *testing.B) {
+	capture := benchCapture(1000000)
+	b.SetBytes(int64(len(capture)))
+	b.ReportAllocs()
+	setupLogging("error", "text", false)
+	var table map[string]*neigh
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		table = nil
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		var err error
+		table, _, err = parseInput(bytes.NewReader(capture), "bench", parseOptions{dialect: dialects["ios"]})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	heap := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	b.ReportMetric(float64(heap)/float64(len(capture)), "table-bytes/capture-byte")
+	b.ReportMetric(float64(len(table)), "neighbors")
+	runtime.KeepAlive(capture) // in both heap readings
+}
+
+// BenchmarkParseStream parses the same capture with -stream, one neighbor
+// at a time.
+func BenchmarkParseStream(b *testing.B) {
+	capture := benchCapture(1000000)
+	b.SetBytes(int64(len(capture)))
+	b.ReportAllocs()
+	setupLogging("error", "text", false)
+	for i := 0; i < b.N; i++ {
+		err := parseStream(bytes.NewReader(capture), parseOptions{dialect: dialects["ios"]}, func(n *neigh) error { return nil })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+// platform detection from the capture, for the "auto" dialect and the
+// coverage report: show version banners, prompts and lines only printed
+// by one platform:
+//
+//	Cisco IOS XE Software, Version 17.09.04a              (show version banner)
+//	Arista DCS-7280SR-48C6-R                              (EOS show version)
+//	RP/0/RSP0/CPU0:pe2#show bgp vrf all neighbors          (IOS-XR prompt)
+//	BGP neighbor is 10.1.1.2, remote AS 65002, ebgp link, Peer index 3   (NX-OS)
+//	  BGP version 4, remote router ID 10.255.0.2, VRF default            (EOS)
+//
+// IOS and IOS-XE print the same neighbor output: without a banner, both
+// are reported as ios.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const dialectAuto = "auto"
+
+const (
+	platformIOS   = "ios"
+	platformIOSXE = "ios-xe"
+	platformIOSXR = "ios-xr"
+	platformNXOS  = "nx-os"
+	platformEOS   = "eos"
+)
+
+// maxPlatformLines bounds the lines searched for the platform.
+const maxPlatformLines = 1000
+
+// platformSignatures are tried in order on each line: banners first, since
+// they name the platform, then lines only one platform prints. All are
+// anchored at the line start, so that no description or other free text
+// naming a platform matches.
+var platformSignatures = []struct {
+	match    func(line string) bool
+	platform string
+}{
+	{hasPrefix("Cisco IOS XR Software, Version "), platformIOSXR},
+	{hasPrefix("Cisco IOS XE Software, Version "), platformIOSXE},
+	{hasPrefix("Cisco IOS Software, IOS-XE Software"), platformIOSXE}, // IOS-XE 3
+	{hasPrefix("Cisco Nexus Operating System (NX-OS) Software"), platformNXOS},
+	{eosModelPattern.MatchString, platformEOS},
+	{hasPrefix("Software image version: "), platformEOS},
+	{hasPrefix("Cisco IOS Software, "), platformIOS},
+	{xrPromptPattern.MatchString, platformIOSXR},
+	{hasPrefix(" Remote AS "), platformIOSXR},
+	{hasPrefix(" For Address Family: "), platformIOSXR},
+	{nxosHeaderPattern.MatchString, platformNXOS},
+	{hasPrefix("  BGP state is "), platformEOS},
+	{hasPrefix("TCP Socket Information:"), platformEOS},
+	{eosVersionPattern.MatchString, platformEOS},
+}
+
+var (
+	xrPromptPattern   = regexp.MustCompile(`^RP/\d+/[^/]+/CPU\d+:`)
+	nxosHeaderPattern = regexp.MustCompile(`^BGP neighbor is \S+, +remote AS \S+, [ei]bgp link, +Peer index \d+`)
+	eosVersionPattern = regexp.MustCompile(`^  BGP version \d+, remote router ID \S+, VRF \S+$`)
+	eosModelPattern   = regexp.MustCompile(`^Arista (DCS|CCS|vEOS)[\w-]*$`) // first line of show version
+)
+
+func hasPrefix(s string) func(string) bool {
+	return func(line string) bool { return strings.HasPrefix(line, s) }
+}
+
+// platformDialects maps the platforms needing their own dialect, the
+// others are parsed by the default one.
+var platformDialects = map[string]string{
+	platformIOSXR: "iosxr",
+	platformNXOS:  "nxos",
+	platformEOS:   "eos",
+}
+
+func init() {
+	registerDialect(dialectAuto, dialectFunc(autoParser))
+}
+
+// detectPlatform returns the platform line belongs to, "" when unknown.
+func detectPlatform(line string) string {
+	for _, s := range platformSignatures {
+		if s.match(line) {
+			return s.platform
+		}
+	}
+	return ""
+}
+
+// autoParser passes line to the dialect of the platform detected so far.
+// Neighbor headers read alike on every platform (the IOS-XR one is held
+// until its remote AS line, a signature), so the dialect may change within
+// the first block.
+func autoParser(scanner *neighScanner, line string, lineNum int) error {
+	return dialects[scanner.autoDialect()].parseLine(scanner, line, lineNum)
+}
+
+func (scanner *neighScanner) autoDialect() string {
+	if name, ok := platformDialects[scanner.platform]; ok {
+		return name
+	}
+	return dialectDefault
+}
+
+// captureReport tells how much of a capture the parser recognized.
+type captureReport struct {
+	Label      string
+	Platform   string
+	Dialect    string
+	Lines      int // non-blank
+	Recognized int
+	Malformed  int
+	Neighbors  int
+}
+
+func (scanner *neighScanner) report(label string, neighbors int) captureReport {
+	r := captureReport{
+		Label:     label,
+		Platform:  scanner.platform,
+		Dialect:   scanner.opts.dialectName,
+		Lines:     scanner.nonBlank,
+		Malformed: len(scanner.errors),
+		Neighbors: neighbors,
+	}
+	if r.Platform == "" {
+		r.Platform = platformIOS
+	}
+	if r.Dialect == "" || r.Dialect == dialectAuto {
+		r.Dialect = dialectAuto + ": " + scanner.autoDialect()
+	}
+	r.Recognized = r.Lines - scanner.ignored - r.Malformed
+	if r.Recognized < 0 {
+		r.Recognized = 0
+	}
+	return r
+}
+
+// coverage returns the percentage of non-blank lines recognized.
+func (r captureReport) coverage() float64 {
+	if r.Lines == 0 {
+		return 100
+	}
+	return 100 * float64(r.Recognized) / float64(r.Lines)
+}
+
+func (r captureReport) String() string {
+	return fmt.Sprintf("%s: platform %s, dialect %s, %d of %d lines recognized (%.1f%%), %d malformed, %d neighbors",
+		r.Label, r.Platform, r.Dialect, r.Recognized, r.Lines, r.coverage(), r.Malformed, r.Neighbors)
+}
+
+// ignore counts a non-blank line no rule of the dialect used.
+func (scanner *neighScanner) ignore(line string) {
+	if strings.TrimSpace(line) != "" {
+		scanner.ignored++
+	}
+}
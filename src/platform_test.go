@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestDetectPlatform(t *testing.T) {
+	cases := []struct {
+		line, want string
+	}{
+		{"Cisco IOS XR Software, Version 7.5.2", platformIOSXR},
+		{"Cisco IOS XR Software, Version 6.1.4[Default]", platformIOSXR},
+		{"Cisco IOS XE Software, Version 17.09.04a", platformIOSXE},
+		{"Cisco IOS Software, IOS-XE Software, Catalyst 4500 L3 Switch Software (cat4500e-UNIVERSALK9-M), Version 03.06.04.E, RELEASE SOFTWARE (fc2)", platformIOSXE},
+		{"Cisco Nexus Operating System (NX-OS) Software", platformNXOS},
+		{"Cisco IOS Software, C3900 Software (C3900-UNIVERSALK9-M), Version 15.4(3)M2, RELEASE SOFTWARE (fc2)", platformIOS},
+		{"Arista DCS-7280SR-48C6-R", platformEOS},
+		{"Arista vEOS-lab", platformEOS},
+		{"Software image version: 4.28.3M", platformEOS},
+		{"RP/0/RSP0/CPU0:pe2#show bgp vrf all neighbors", platformIOSXR},
+		{" Remote AS 65033, local AS 64512, external link", platformIOSXR},
+		{" For Address Family: IPv4 Unicast", platformIOSXR},
+		{"BGP neighbor is 10.1.1.2, remote AS 65002, ebgp link, Peer index 3", platformNXOS},
+		{"  BGP state is Established, up for 5d03h", platformEOS},
+		{"  BGP version 4, remote router ID 10.255.0.2, VRF default", platformEOS},
+
+		// free text naming a platform
+		{" Description: Cisco IOS XR Software, Version 7.5.2 CPE", ""},
+		{" Description: Cisco IOS XE Software, Version 17.09", ""},
+		{" Description: Cisco Nexus Operating System (NX-OS) Software leaf", ""},
+		{" Description: Cisco IOS Software, CPE", ""},
+		{" Description: Arista DCS-7280 CPE rack 12", ""},
+		{" Description: EOS leaf, Software image version: 4.28", ""},
+		{" Description: ebgp link, Peer index 3", ""},
+		{"BGP neighbor is 198.51.100.1,  vrf CUST-A,  remote AS 65001, external link", ""},
+		{"Arista DCS-7280 CPE rack 12", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := detectPlatform(c.line); got != c.want {
+			t.Errorf("detectPlatform(%q) = %q, want %q", c.line, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+// inbound/outbound policy per address family, from the lines following
+// " For address family: VPNv4 Unicast":
+//
+//	Route map for incoming advertisements is RM-CUST-IN
+//	Route map for outgoing advertisements is RM-CUST-OUT
+//	Incoming update prefix filter list is PL-CUST-A-IN
+//	Outgoing update AS path filter list is 20
+//	Incoming update network filter list is 100
+//	Maximum prefixes allowed 100 (warning-only configured)
+//	Threshold for warning message 75%, restart interval 5 min
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type afPolicy struct {
+	AddressFamily     string `json:"address_family"`
+	Prefixes          int    `json:"prefixes"` // current prefixes received
+	RouteMapIn        string `json:"route_map_in,omitempty"`
+	RouteMapOut       string `json:"route_map_out,omitempty"`
+	PrefixListIn      string `json:"prefix_list_in,omitempty"`
+	PrefixListOut     string `json:"prefix_list_out,omitempty"`
+	FilterListIn      string `json:"filter_list_in,omitempty"` // AS path access list
+	FilterListOut     string `json:"filter_list_out,omitempty"`
+	DistributeListIn  string `json:"distribute_list_in,omitempty"` // network filter list
+	DistributeListOut string `json:"distribute_list_out,omitempty"`
+
+	MaxPrefix            int  `json:"max_prefix,omitempty"`
+	MaxPrefixThreshold   int  `json:"max_prefix_threshold,omitempty"` // warning threshold, percent of MaxPrefix
+	MaxPrefixRestart     int  `json:"max_prefix_restart,omitempty"`   // restart interval, minutes
+	MaxPrefixWarningOnly bool `json:"max_prefix_warning_only,omitempty"`
+
+	RRClient bool `json:"route_reflector_client,omitempty"`
+}
+
+var policyLines = []struct {
+	prefix string
+	field  func(p *afPolicy) *string
+}{
+	{"Route map for incoming advertisements is ", func(p *afPolicy) *string { return &p.RouteMapIn }},
+	{"Route map for outgoing advertisements is ", func(p *afPolicy) *string { return &p.RouteMapOut }},
+	{"Incoming update prefix filter list is ", func(p *afPolicy) *string { return &p.PrefixListIn }},
+	{"Outgoing update prefix filter list is ", func(p *afPolicy) *string { return &p.PrefixListOut }},
+	{"Incoming update AS path filter list is ", func(p *afPolicy) *string { return &p.FilterListIn }},
+	{"Outgoing update AS path filter list is ", func(p *afPolicy) *string { return &p.FilterListOut }},
+	{"Incoming update network filter list is ", func(p *afPolicy) *string { return &p.DistributeListIn }},
+	{"Outgoing update network filter list is ", func(p *afPolicy) *string { return &p.DistributeListOut }},
+}
+
+// afPolicy returns the policy record for address family af, adding it if needed.
+func (n *neigh) afPolicy(af string) *afPolicy {
+	for _, p := range n.Policies {
+		if p.AddressFamily == af {
+			return p
+		}
+	}
+	p := &afPolicy{AddressFamily: af}
+	n.Policies = append(n.Policies, p)
+	return p
+}
+
+// parsePolicyLine records a policy line for address family af.
+// It returns false when line is not a policy line.
+func parsePolicyLine(n *neigh, af, line string) bool {
+	if lineIndent(line) != 2 {
+		return false
+	}
+	s := strings.TrimSpace(line)
+	for _, pl := range policyLines {
+		if !strings.HasPrefix(s, pl.prefix) {
+			continue
+		}
+		*pl.field(n.afPolicy(af)) = strings.TrimSpace(s[len(pl.prefix):])
+		return true
+	}
+	if strings.HasPrefix(s, "Maximum prefixes allowed ") {
+		p := n.afPolicy(af)
+		f := strings.Fields(s[len("Maximum prefixes allowed "):])
+		if len(f) > 0 {
+			p.MaxPrefix, _ = strconv.Atoi(f[0])
+		}
+		p.MaxPrefixWarningOnly = strings.Contains(s, "warning-only")
+		return true
+	}
+	if strings.HasPrefix(s, "Threshold for warning message ") {
+		p := n.afPolicy(af)
+		var threshold, restart int
+		// restart interval is optional
+		fmt.Sscanf(s, "Threshold for warning message %d%%, restart interval %d min", &threshold, &restart)
+		p.MaxPrefixThreshold = threshold
+		p.MaxPrefixRestart = restart
+		return true
+	}
+	return false
+}
+
+// maxPrefixUsage returns the prefixes received as a percentage of the
+// max-prefix limit. ok is false when no limit is configured.
+func (p *afPolicy) maxPrefixUsage() (pct float64, ok bool) {
+	if p.MaxPrefix <= 0 {
+		return 0, false
+	}
+	return 100 * float64(p.Prefixes) / float64(p.MaxPrefix), true
+}
+
+// maxPrefixUsage returns the highest max-prefix usage over all address families.
+func (n *neigh) maxPrefixUsage() (pct float64, ok bool) {
+	for _, p := range n.Policies {
+		if u, found := p.maxPrefixUsage(); found && (!ok || u > pct) {
+			pct, ok = u, true
+		}
+	}
+	return pct, ok
+}
+
+// routeMaps lists the inbound and outbound route maps over all address families.
+func (n *neigh) routeMaps() (in, out []string) {
+	for _, p := range n.Policies {
+		if p.RouteMapIn != "" {
+			in = append(in, p.RouteMapIn)
+		}
+		if p.RouteMapOut != "" {
+			out = append(out, p.RouteMapOut)
+		}
+	}
+	return in, out
+}
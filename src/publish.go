@@ -0,0 +1,201 @@
+package main
+
+// neighbor change events published to a message bus after each
+// re-collection (watch and serve), one JSON document per change:
+// nats://[user:pass@]host:4222/subject
+// kafka://restproxy:8082/topic       (Kafka REST Proxy v2, kafka+https:// for TLS)
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// neighEvent is the JSON document published for each change.
+type neighEvent struct {
+	Event    string    `json:"event"` // added, removed, changed
+	Time     time.Time `json:"time"`
+	Neighbor *neigh    `json:"neighbor"`           // last known state for removed
+	Previous *neigh    `json:"previous,omitempty"` // changed only
+}
+
+type publisher interface {
+	publish(key string, event []byte) error // key identifies the neighbor
+}
+
+// parsePublisher returns the publisher for a nats:// or kafka:// URL.
+func parsePublisher(spec string) (publisher, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parsePublisher: %v", err)
+	}
+	topic := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || topic == "" {
+		return nil, fmt.Errorf("parsePublisher: expecting scheme://host:port/topic: [%s]", spec)
+	}
+	switch u.Scheme {
+	case "nats":
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "4222")
+		}
+		p := &natsPublisher{addr: addr, subject: topic}
+		if u.User != nil {
+			p.user = u.User.Username()
+			p.pass, _ = u.User.Password()
+		}
+		return p, nil
+	case "kafka", "kafka+http", "kafka+https":
+		scheme := "http"
+		if u.Scheme == "kafka+https" {
+			scheme = "https"
+		}
+		return &kafkaRESTPublisher{url: scheme + "://" + u.Host + "/topics/" + url.PathEscape(topic)}, nil
+	}
+	return nil, fmt.Errorf("parsePublisher: unknown scheme: [%s] (expecting nats or kafka)", u.Scheme)
+}
+
+// publishChanges publishes an event for each neighbor added, removed or
+// changed between prev and curr. Nothing is published on the first collection.
+func publishChanges(p publisher, prev, curr map[string]*neigh) {
+	if prev == nil {
+		return
+	}
+	now := time.Now()
+	changes := tableChanges(prev, curr)
+	for _, c := range changes {
+		body, err := json.Marshal(neighEvent{Event: c.Kind, Time: now, Neighbor: c.Neigh, Previous: c.Prev})
+		if err != nil {
+			errorf("publishChanges: %v", err)
+			return
+		}
+		if err := p.publish(neighKey(c.Neigh), body); err != nil {
+			errorf("publishChanges: %v", err)
+			return
+		}
+	}
+	if len(changes) > 0 {
+		infof("publishChanges: published %d neighbor events", len(changes))
+	}
+}
+
+// natsPublisher speaks the NATS client protocol over a connection kept
+// between collections and re-established after errors.
+type natsPublisher struct {
+	addr    string
+	subject string
+	user    string
+	pass    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// connect is called with p.mu held.
+func (p *natsPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("natsPublisher.connect: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	info, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("natsPublisher.connect: %s: expecting INFO: [%s] %v", p.addr, strings.TrimSpace(info), err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "cisco-vrf-bgp-neigh", "lang": "go", "version": "1"}
+	if p.user != "" {
+		opts["user"] = p.user
+		opts["pass"] = p.pass
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", connect); err != nil {
+		conn.Close()
+		return fmt.Errorf("natsPublisher.connect: %v", err)
+	}
+
+	// answer server pings and report protocol errors
+	go func() {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				p.mu.Lock()
+				fmt.Fprint(conn, "PONG\r\n")
+				p.mu.Unlock()
+			case strings.HasPrefix(line, "-ERR"):
+				errorf("natsPublisher: %s: %s", p.addr, strings.TrimSpace(line))
+			}
+		}
+	}()
+
+	p.conn = conn
+	return nil
+}
+
+func (p *natsPublisher) publish(key string, event []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "PUB %s %d\r\n", p.subject, len(event))
+	buf.Write(event)
+	buf.WriteString("\r\n")
+	p.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := p.conn.Write(buf.Bytes()); err != nil {
+		p.conn.Close()
+		p.conn = nil // reconnect on next publish
+		return fmt.Errorf("natsPublisher.publish: %s: %v", p.addr, err)
+	}
+	return nil
+}
+
+// kafkaRESTPublisher posts records to a Kafka REST Proxy (v2 API).
+type kafkaRESTPublisher struct {
+	url string
+}
+
+func (p *kafkaRESTPublisher) publish(key string, event []byte) error {
+	type record struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	var req struct {
+		Records []record `json:"records"`
+	}
+	req.Records = []record{{Key: key, Value: event}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("kafkaRESTPublisher.publish: %v", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(p.url, "application/vnd.kafka.json.v2+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("kafkaRESTPublisher.publish: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kafkaRESTPublisher.publish: %s: %s", p.url, resp.Status)
+	}
+	return nil
+}
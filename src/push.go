@@ -0,0 +1,204 @@
+package main
+
+// neighbor metrics pushed after each collection, for collectors that
+// cannot be scraped (cron jobs behind NAT):
+// prometheus+https://host/api/v1/write                 Prometheus remote write
+// influx+https://host:8086/api/v2/write?org=O&bucket=B  InfluxDB line protocol (or /write?db=D for 1.x)
+// Use +http for plain HTTP. Credentials come from the URL (basic auth) or
+// $BGPN_PUSH_TOKEN (bearer token, or InfluxDB token).
+//
+// Series per neighbor: bgp_neighbor_up, bgp_neighbor_prefixes and
+// bgp_neighbor_uptime_seconds, labeled device, vrf, neighbor, remote_as.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pushTokenEnv = "BGPN_PUSH_TOKEN"
+
+type metricsPusher struct {
+	url        string
+	user, pass string
+	authScheme string // for the token: Bearer or Token
+	header     map[string]string
+	encode     func(list []*neigh, at time.Time) []byte
+}
+
+// parsePusher returns the pusher for a prometheus:// or influx:// URL.
+func parsePusher(spec string) (*metricsPusher, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parsePusher: %v", err)
+	}
+	kind, scheme := u.Scheme, "http"
+	if i := strings.IndexByte(u.Scheme, '+'); i >= 0 {
+		kind, scheme = u.Scheme[:i], u.Scheme[i+1:]
+	}
+	if u.Host == "" || (scheme != "http" && scheme != "https") {
+		return nil, fmt.Errorf("parsePusher: expecting prometheus+https://host/path or influx+https://host/path: [%s]", spec)
+	}
+	p := &metricsPusher{}
+	if u.User != nil {
+		p.user = u.User.Username()
+		p.pass, _ = u.User.Password()
+		u.User = nil
+	}
+	u.Scheme = scheme
+	p.url = u.String()
+
+	switch kind {
+	case "prometheus":
+		p.authScheme = "Bearer"
+		p.header = map[string]string{
+			"Content-Type":                      "application/x-protobuf",
+			"Content-Encoding":                  "snappy",
+			"X-Prometheus-Remote-Write-Version": "0.1.0",
+		}
+		p.encode = func(list []*neigh, at time.Time) []byte { return snappyEncode(remoteWriteRequest(list, at)) }
+	case "influx":
+		p.authScheme = "Token"
+		p.header = map[string]string{"Content-Type": "text/plain; charset=utf-8"}
+		p.encode = influxLines
+	default:
+		return nil, fmt.Errorf("parsePusher: unknown scheme: [%s] (expecting prometheus or influx)", kind)
+	}
+	return p, nil
+}
+
+func (p *metricsPusher) push(list []*neigh, at time.Time) error {
+	req, err := http.NewRequest("POST", p.url, bytes.NewReader(p.encode(list, at)))
+	if err != nil {
+		return fmt.Errorf("metricsPusher.push: %v", err)
+	}
+	for k, v := range p.header {
+		req.Header.Set(k, v)
+	}
+	if p.user != "" {
+		req.SetBasicAuth(p.user, p.pass)
+	} else if token := os.Getenv(pushTokenEnv); token != "" {
+		req.Header.Set("Authorization", p.authScheme+" "+token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metricsPusher.push: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("metricsPusher.push: %s: %s", p.url, resp.Status)
+	}
+	debugf("metricsPusher.push: %d neighbors to %s", len(list), p.url)
+	return nil
+}
+
+type pushSample struct {
+	name  string
+	value float64
+}
+
+// pushSamples returns the metrics of n.
+func pushSamples(n *neigh) []pushSample {
+	up := 0.0
+	if n.State == "Established" {
+		up = 1
+	}
+	samples := []pushSample{{"bgp_neighbor_up", up}, {"bgp_neighbor_prefixes", float64(n.Prefixes)}}
+	if n.UptimeSeconds != nil {
+		samples = append(samples, pushSample{"bgp_neighbor_uptime_seconds", float64(*n.UptimeSeconds)})
+	}
+	return samples
+}
+
+// pushLabels returns the non-empty labels of n, sorted by name.
+func pushLabels(n *neigh) [][2]string {
+	var labels [][2]string
+	for _, l := range [][2]string{{"device", n.Device}, {"neighbor", n.Addr}, {"remote_as", n.RemoteAS}, {"vrf", n.VRF}} {
+		if l[1] != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// remoteWriteRequest encodes a prometheus.WriteRequest:
+// WriteRequest{timeseries=1}, TimeSeries{labels=1, samples=2},
+// Label{name=1, value=2}, Sample{value=1 (double), timestamp=2 (ms)}.
+func remoteWriteRequest(list []*neigh, at time.Time) []byte {
+	var req []byte
+	for _, n := range list {
+		for _, s := range pushSamples(n) {
+			labels := append([][2]string{{"__name__", s.name}}, pushLabels(n)...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+			var ts []byte
+			for _, l := range labels {
+				var label []byte
+				label = protoAppendString(label, 1, l[0])
+				label = protoAppendString(label, 2, l[1])
+				ts = protoAppendMessage(ts, 1, label)
+			}
+			sample := protoAppendTag(nil, 1, 1)
+			sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.value))
+			sample = protoAppendVarint(sample, 2, uint64(at.UnixMilli()))
+			ts = protoAppendMessage(ts, 2, sample)
+			req = protoAppendMessage(req, 1, ts)
+		}
+	}
+	return req
+}
+
+// snappyEncode returns src in the snappy block format, as literals only:
+// valid for any decoder, without compression.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	for len(src) > 0 {
+		n := len(src)
+		if n > 65536 {
+			n = 65536
+		}
+		switch {
+		case n <= 60:
+			dst = append(dst, byte(n-1)<<2)
+		case n <= 256:
+			dst = append(dst, 60<<2, byte(n-1))
+		default:
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
+
+var (
+	influxTagEscaper    = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// influxLines encodes one bgp_neighbor point per neighbor, e.g.
+// bgp_neighbor,device=pe1,neighbor=198.51.100.1,remote_as=65001,vrf=CUST-A state="Established",up=1i,prefixes=26i,uptime_seconds=3196800i 1700000000000000000
+func influxLines(list []*neigh, at time.Time) []byte {
+	var b bytes.Buffer
+	for _, n := range list {
+		b.WriteString("bgp_neighbor")
+		for _, l := range pushLabels(n) {
+			b.WriteString("," + l[0] + "=" + influxTagEscaper.Replace(l[1]))
+		}
+		fmt.Fprintf(&b, ` state="%s"`, influxStringEscaper.Replace(n.State))
+		for _, s := range pushSamples(n) {
+			b.WriteString("," + strings.TrimPrefix(s.name, "bgp_neighbor_") + "=" + strconv.FormatInt(int64(s.value), 10) + "i")
+		}
+		fmt.Fprintf(&b, " %d\n", at.UnixNano())
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,203 @@
+package main
+
+// collect neighbors via RESTCONF (RFC 8040) from the OpenConfig
+// network-instance model, as served by IOS-XE:
+// /restconf/data/openconfig-network-instance:network-instances
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const restconfPath = "/restconf/data/openconfig-network-instance:network-instances"
+
+type restconfOptions struct {
+	user     string
+	pass     string
+	insecure bool // skip TLS certificate verification
+	timeout  time.Duration
+}
+
+// yangUint64 accepts both JSON numbers and strings, since RFC 7951 encodes 64-bit integers as strings.
+type yangUint64 uint64
+
+func (v *yangUint64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" || s == "" {
+		*v = 0
+		return nil
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("yangUint64: bad value: %s", b)
+	}
+	*v = yangUint64(u)
+	return nil
+}
+
+type ocNetworkInstances struct {
+	NetworkInstance []struct {
+		Name      string `json:"name"`
+		Protocols struct {
+			Protocol []struct {
+				Identifier string `json:"identifier"`
+				BGP        struct {
+					Neighbors struct {
+						Neighbor []ocNeighbor `json:"neighbor"`
+					} `json:"neighbors"`
+				} `json:"bgp"`
+			} `json:"protocol"`
+		} `json:"protocols"`
+	} `json:"network-instance"`
+}
+
+type ocNeighbor struct {
+	Address string `json:"neighbor-address"`
+	State   struct {
+		PeerAS          yangUint64 `json:"peer-as"`
+		SessionState    string     `json:"session-state"`
+		Description     string     `json:"description"`
+		Enabled         *bool      `json:"enabled"`          // false when shut down, true if absent
+		LastEstablished yangUint64 `json:"last-established"` // nanoseconds since epoch
+	} `json:"state"`
+	AfiSafis struct {
+		AfiSafi []struct {
+			State struct {
+				Prefixes struct {
+					Received yangUint64 `json:"received"`
+				} `json:"prefixes"`
+			} `json:"state"`
+		} `json:"afi-safi"`
+	} `json:"afi-safis"`
+}
+
+var ocSessionStates = map[string]string{
+	"IDLE":        "Idle",
+	"CONNECT":     "Connect",
+	"ACTIVE":      "Active",
+	"OPENSENT":    "OpenSent",
+	"OPENCONFIRM": "OpenConfirm",
+	"ESTABLISHED": "Established",
+}
+
+// restconfCollect fetches the OpenConfig network instances from baseURL
+// (e.g. https://router1) and returns the same table the text parser would.
+func restconfCollect(baseURL string, opts restconfOptions) (map[string]*neigh, error) {
+	url := strings.TrimSuffix(baseURL, "/") + restconfPath
+
+	debugf("restconfCollect: fetching %s", url)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("restconfCollect: %v", err)
+	}
+	req.Header.Set("Accept", "application/yang-data+json")
+	if opts.user != "" {
+		req.SetBasicAuth(opts.user, opts.pass)
+	}
+
+	client := &http.Client{Timeout: opts.timeout}
+	if opts.insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("restconfCollect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("restconfCollect: %s: %s", url, resp.Status)
+	}
+
+	table, err := parseRestconf(resp.Body, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("restconfCollect: %v", err)
+	}
+
+	infof("restconfCollect: found %d neighbors", len(table))
+
+	return table, nil
+}
+
+// parseRestconf decodes a network-instances document.
+// Uptime is computed from last-established relative to now.
+func parseRestconf(r io.Reader, now time.Time) (map[string]*neigh, error) {
+	// the top level key carries the module prefix
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parseRestconf: %v", err)
+	}
+	body := ocMember(doc, "network-instances")
+	if body == nil {
+		return nil, fmt.Errorf("parseRestconf: missing network-instances")
+	}
+	var instances ocNetworkInstances
+	if err := json.Unmarshal(body, &instances); err != nil {
+		return nil, fmt.Errorf("parseRestconf: %v", err)
+	}
+
+	table := map[string]*neigh{}
+	for _, ni := range instances.NetworkInstance {
+		for _, proto := range ni.Protocols.Protocol {
+			if !strings.HasSuffix(proto.Identifier, "BGP") {
+				continue
+			}
+			for _, on := range proto.BGP.Neighbors.Neighbor {
+				n := on.neigh(ni.Name, now)
+				table[neighKey(n)] = n
+			}
+		}
+	}
+
+	return table, nil
+}
+
+// ocMember returns the member name of a JSON object, with or without the
+// module prefix RFC 7951 puts on top level members, nil if absent.
+func ocMember(doc map[string]json.RawMessage, name string) json.RawMessage {
+	for k, v := range doc {
+		if k == name || strings.HasSuffix(k, ":"+name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// neigh maps an OpenConfig neighbor of network instance vrf.
+// Uptime is computed from last-established relative to now.
+func (on *ocNeighbor) neigh(vrf string, now time.Time) *neigh {
+	n := &neigh{
+		Addr:        on.Address,
+		VRF:         vrf,
+		RemoteAS:    strconv.FormatUint(uint64(on.State.PeerAS), 10),
+		Description: on.State.Description,
+	}
+	state := on.State.SessionState
+	if i := strings.LastIndex(state, ":"); i >= 0 {
+		state = state[i+1:] // strip identity prefix
+	}
+	n.State = ocSessionStates[state]
+	if n.State == "" {
+		n.State = "?"
+	}
+	n.Shutdown = on.State.Enabled != nil && !*on.State.Enabled
+	n.setUptime("?")
+	if n.State == "Established" && on.State.LastEstablished > 0 {
+		since := time.Unix(0, int64(on.State.LastEstablished))
+		if d := now.Sub(since); d >= 0 {
+			n.setUptime(formatUptime(d))
+		}
+	}
+	for _, af := range on.AfiSafis.AfiSafi {
+		n.Prefixes += int(af.State.Prefixes.Received) // sum over address families
+	}
+	return n
+}
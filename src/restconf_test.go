@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRestconf(t *testing.T) {
+	now := time.Date(2026, 9, 14, 6, 0, 0, 0, time.UTC)
+	established := now.Add(-(3*24*time.Hour + 4*time.Hour)).UnixNano()
+	doc := `{"openconfig-network-instance:network-instances": {"network-instance": [
+	{"name": "CUST-A", "protocols": {"protocol": [{"identifier": "openconfig-policy-types:BGP", "name": "bgp", "bgp": {"neighbors": {"neighbor": [
+		{"neighbor-address": "198.51.100.1", "state": {"peer-as": 65001, "session-state": "ESTABLISHED", "enabled": true, "description": "CUST-A-1",
+			"last-established": "` + strconv.FormatInt(established, 10) + `"},
+			"afi-safis": {"afi-safi": [{"state": {"prefixes": {"received": 12}}}, {"state": {"prefixes": {"received": "3"}}}]}},
+		{"neighbor-address": "198.51.100.2", "state": {"peer-as": "65002", "session-state": "openconfig-bgp-types:IDLE", "enabled": false}},
+		{"neighbor-address": "198.51.100.3", "state": {"peer-as": 65003, "session-state": "ACTIVE"}}
+	]}}}, {"identifier": "STATIC", "name": "static"}]}},
+	{"name": "default", "protocols": {"protocol": [{"identifier": "BGP", "name": "bgp", "bgp": {"neighbors": {"neighbor": [
+		{"neighbor-address": "10.0.0.2", "state": {"peer-as": 64512, "session-state": "BOGUS"}}
+	]}}}]}}
+]}}`
+	cases := []struct {
+		key, remoteAS, state, uptime string
+		prefixes                     int
+		shutdown                     bool
+	}{
+		{tableKey("", "198.51.100.1", "CUST-A"), "65001", "Established", "3d04h", 15, false},
+		{tableKey("", "198.51.100.2", "CUST-A"), "65002", "Idle", "?", 0, true},
+		{tableKey("", "198.51.100.3", "CUST-A"), "65003", "Active", "?", 0, false},
+		{tableKey("", "10.0.0.2", "default"), "64512", "?", "?", 0, false},
+	}
+	table, err := parseRestconf(strings.NewReader(doc), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != len(cases) {
+		t.Errorf("got %d neighbors, want %d", len(table), len(cases))
+	}
+	for _, c := range cases {
+		n := table[c.key]
+		if n == nil {
+			t.Errorf("%s: missing", c.key)
+			continue
+		}
+		if n.RemoteAS != c.remoteAS || n.State != c.state || n.Uptime != c.uptime || n.Prefixes != c.prefixes || n.Shutdown != c.shutdown {
+			t.Errorf("%s: got %s %s %s %d shutdown=%v", c.key, n.RemoteAS, n.State, n.Uptime, n.Prefixes, n.Shutdown)
+		}
+	}
+
+	if _, err := parseRestconf(strings.NewReader(`{"ietf-interfaces:interfaces": {}}`), now); err == nil {
+		t.Error("document without network-instances: want error")
+	}
+}
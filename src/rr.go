@@ -0,0 +1,154 @@
+package main
+
+// route reflection and confederation attributes:
+//
+//	For address family: VPNv4 Unicast
+//	  Route-Reflector Client               (per address family)
+//	  Neighbor under common administration  (confederation peer)
+//	  Cluster ID is 10.255.255.2            (neighbor-specific cluster ID)
+//
+// The RR report counts route reflector clients per device.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var clusterIDPattern = regexp.MustCompile(`(?i)^(neighbor[- ]specific )?cluster[- ]id( is|:)? (\S+)$`)
+
+// parseRRLine records a route reflection or confederation line.
+// It returns false when line is about neither.
+func parseRRLine(n *neigh, af, line string) bool {
+	s := strings.TrimSpace(line)
+	switch {
+	case s == "Route-Reflector Client" || s == "Route Reflector client":
+		if af == "" {
+			af = "default"
+		}
+		n.afPolicy(af).RRClient = true
+		return true
+	case s == "Neighbor under common administration":
+		n.Confederation = true
+		return true
+	}
+	if s == "" || (s[0]|0x20 != 'c' && s[0]|0x20 != 'n') {
+		return false // skip the regexp, it only matches cluster or neighbor lines
+	}
+	if m := clusterIDPattern.FindStringSubmatch(s); m != nil {
+		n.ClusterID = m[3]
+		return true
+	}
+	return false
+}
+
+// rrClient reports whether n is a route reflector client in any address family.
+func (n *neigh) rrClient() bool {
+	for _, p := range n.Policies {
+		if p.RRClient {
+			return true
+		}
+	}
+	return false
+}
+
+type rrDevice struct {
+	Device          string   `json:"device"`
+	Clients         int      `json:"clients"`
+	Established     int      `json:"established"`    // clients Established
+	Down            []string `json:"down,omitempty"` // clients not Established, as addr:vrf
+	NonClients      int      `json:"non_clients"`    // iBGP neighbors not reflected to
+	Confederation   int      `json:"confederation"`  // confederation peers
+	ClusterIDs      []string `json:"cluster_ids,omitempty"`
+	AddressFamilies []string `json:"address_families,omitempty"` // with clients
+}
+
+// newRRReport groups the route reflection attributes by device.
+// Devices without clients, non-clients or confederation peers are left out.
+func newRRReport(list []*neigh) []*rrDevice {
+	devices := map[string]*rrDevice{}
+	get := func(name string) *rrDevice {
+		d := devices[name]
+		if d == nil {
+			d = &rrDevice{Device: name}
+			devices[name] = d
+		}
+		return d
+	}
+	for _, n := range list {
+		if n.Confederation {
+			get(n.Device).Confederation++
+		}
+		if !n.rrClient() {
+			if n.Link == "internal" {
+				get(n.Device).NonClients++
+			}
+			continue
+		}
+		d := get(n.Device)
+		d.Clients++
+		if n.State == "Established" {
+			d.Established++
+		} else {
+			d.Down = append(d.Down, n.Addr+":"+n.VRF)
+		}
+		if n.ClusterID != "" {
+			d.ClusterIDs = appendUnique(d.ClusterIDs, n.ClusterID)
+		}
+		for _, p := range n.Policies {
+			if p.RRClient {
+				d.AddressFamilies = appendUnique(d.AddressFamilies, p.AddressFamily)
+			}
+		}
+	}
+
+	var rows []*rrDevice
+	for _, d := range devices {
+		sort.Strings(d.ClusterIDs)
+		sort.Strings(d.AddressFamilies)
+		rows = append(rows, d)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Device < rows[j].Device })
+	return rows
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func writeRRReport(w io.Writer, rows []*rrDevice) {
+	fmt.Fprintf(w, "%-12s %7s %5s %5s %11s %6s %-16s %s\n", "Device", "Clients", "Up", "Down", "Non-clients", "Confed", "Cluster IDs", "Address families")
+	var down []string
+	for _, d := range rows {
+		fmt.Fprintf(w, "%-12s %7d %5d %5d %11d %6d %-16s %s\n", d.Device, d.Clients, d.Established, len(d.Down), d.NonClients, d.Confederation, strings.Join(d.ClusterIDs, ","), strings.Join(d.AddressFamilies, ","))
+		for _, c := range d.Down {
+			down = append(down, d.Device+" "+c)
+		}
+	}
+	if len(down) > 0 {
+		fmt.Fprintf(w, "\nClients not Established:\n")
+		for _, c := range down {
+			fmt.Fprintf(w, "  %s\n", c)
+		}
+	}
+}
+
+func writeRRReportJSON(w io.Writer, rows []*rrDevice) error {
+	if rows == nil {
+		rows = []*rrDevice{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		return fmt.Errorf("writeRRReportJSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+// read-only REST API over the collected neighbor table:
+// GET /neighbors?vrf=X&state=Idle&asn=65001
+// GET /devices
+// GET /devices/{name}/neighbors
+// The same table is served over gRPC, see grpc.go.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type apiServer struct {
+	keys []sortKey
+
+	mu        sync.RWMutex
+	table     map[string]*neigh
+	collected time.Time
+	lastErr   error
+	subs      map[chan *collectEvent]bool // StreamChanges subscribers
+}
+
+// collectEvent carries the changes found by one re-collection.
+type collectEvent struct {
+	changes   []*neighChange
+	collected time.Time
+}
+
+type apiDevice struct {
+	Name      string `json:"name"`
+	Neighbors int    `json:"neighbors"`
+}
+
+// serve collects the table, then re-collects every interval in the
+// background (interval 0 collects only once) while serving the REST API on
+// addr and the gRPC API on grpcAddr. Either address may be empty to disable
+// that API. onCollect may be nil.
+func serve(addr, grpcAddr string, interval time.Duration, collect collectFunc, filter *neighFilter, keys []sortKey, onCollect collectHook) error {
+	if addr == "" && grpcAddr == "" {
+		return errors.New("serve: no listen address")
+	}
+
+	s := &apiServer{keys: keys, subs: map[chan *collectEvent]bool{}}
+
+	update := func() {
+		table, err := collect()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil {
+			errorf("serve: %v", err)
+			s.lastErr = err
+			return
+		}
+		prev := s.table
+		s.table = filterTable(table, filter)
+		s.collected = time.Now()
+		s.lastErr = nil
+		infof("serve: collected %d neighbors", len(s.table))
+		if prev != nil {
+			s.publish(&collectEvent{changes: tableChanges(prev, s.table), collected: s.collected})
+		}
+		if onCollect != nil {
+			onCollect(prev, s.table)
+		}
+	}
+
+	update()
+	if interval > 0 {
+		go func() {
+			for range time.Tick(interval) {
+				update()
+			}
+		}()
+	}
+
+	errs := make(chan error, 2)
+
+	if grpcAddr != "" {
+		go func() {
+			infof("serve: gRPC listening on %s", grpcAddr)
+			errs <- serveGRPC(grpcAddr, s)
+		}()
+	}
+
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/neighbors", s.handleNeighbors)
+		mux.HandleFunc("/devices", s.handleDevices)
+		mux.HandleFunc("/devices/", s.handleDeviceNeighbors)
+
+		go func() {
+			infof("serve: listening on %s", addr)
+			errs <- http.ListenAndServe(addr, mux)
+		}()
+	}
+
+	return <-errs
+}
+
+// neighbors returns the current neighbors matching filter and device,
+// which may be nil, sorted by the server keys.
+func (s *apiServer) neighbors(filter *neighFilter, device func(n *neigh) bool) ([]*neigh, time.Time, error) {
+	s.mu.RLock()
+	table, collected, lastErr := s.table, s.collected, s.lastErr
+	s.mu.RUnlock()
+
+	if table == nil {
+		msg := "no data collected yet"
+		if lastErr != nil {
+			msg += ": " + lastErr.Error()
+		}
+		return nil, collected, errors.New(msg)
+	}
+
+	list := []*neigh{}
+	for _, n := range table {
+		if filter.match(n) && (device == nil || device(n)) {
+			list = append(list, n)
+		}
+	}
+	sortNeighbors(list, s.keys)
+
+	return list, collected, nil
+}
+
+// subscribe registers a channel receiving an event per re-collection.
+func (s *apiServer) subscribe() chan *collectEvent {
+	ch := make(chan *collectEvent, 16)
+	s.mu.Lock()
+	s.subs[ch] = true
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *apiServer) unsubscribe(ch chan *collectEvent) {
+	s.mu.Lock()
+	delete(s.subs, ch)
+	s.mu.Unlock()
+}
+
+// publish sends e to the subscribers, skipping those too slow to keep up.
+// Called with s.mu held.
+func (s *apiServer) publish(e *collectEvent) {
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			warnf("apiServer.publish: subscriber not keeping up, dropping %d changes", len(e.changes))
+		}
+	}
+}
+
+// snapshot returns the current neighbors matching the request query filters.
+func (s *apiServer) snapshot(w http.ResponseWriter, r *http.Request, device func(n *neigh) bool) ([]*neigh, bool) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	q := r.URL.Query()
+	filter, err := newNeighFilter(q.Get("vrf"), q.Get("state"), q.Get("asn"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	list, collected, err := s.neighbors(filter, device)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	w.Header().Set("Last-Modified", collected.UTC().Format(http.TimeFormat))
+
+	return list, true
+}
+
+func (s *apiServer) handleNeighbors(w http.ResponseWriter, r *http.Request) {
+	list, ok := s.snapshot(w, r, nil)
+	if !ok {
+		return
+	}
+	writeAPIJSON(w, list)
+}
+
+func (s *apiServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	list, ok := s.snapshot(w, r, nil)
+	if !ok {
+		return
+	}
+	count := map[string]int{}
+	for _, n := range list {
+		count[n.Device]++
+	}
+	devices := []apiDevice{}
+	for name, c := range count {
+		devices = append(devices, apiDevice{Name: name, Neighbors: c})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	writeAPIJSON(w, devices)
+}
+
+func (s *apiServer) handleDeviceNeighbors(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/devices/")
+	if !strings.HasSuffix(path, "/neighbors") {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimSuffix(path, "/neighbors")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	list, ok := s.snapshot(w, r, func(n *neigh) bool { return n.Device == name })
+	if !ok {
+		return
+	}
+	if len(list) == 0 {
+		http.Error(w, "unknown device or no matching neighbors: "+name, http.StatusNotFound)
+		return
+	}
+	writeAPIJSON(w, list)
+}
+
+func writeAPIJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		warnf("writeAPIJSON: %v", err)
+	}
+}
@@ -0,0 +1,717 @@
+package main
+
+// minimal SNMP client: v2c and v3 (USM with MD5/SHA authentication and AES-128 privacy),
+// GetBulk only, as needed to walk tables.
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BER tags
+const (
+	berInteger      = 0x02
+	berOctetString  = 0x04
+	berNull         = 0x05
+	berObjectID     = 0x06
+	berSequence     = 0x30
+	berIPAddress    = 0x40
+	berCounter32    = 0x41
+	berGauge32      = 0x42
+	berTimeTicks    = 0x43
+	berCounter64    = 0x46
+	berNoSuchObject = 0x80
+	berNoSuchInst   = 0x81
+	berEndOfMibView = 0x82
+	pduGetRequest   = 0xa0
+	pduResponse     = 0xa2
+	pduGetBulk      = 0xa5
+	pduReport       = 0xa8
+)
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berTLV(tag byte, content []byte) []byte {
+	b := append([]byte{tag}, berLength(len(content))...)
+	return append(b, content...)
+}
+
+func berSeq(tag byte, parts ...[]byte) []byte {
+	return berTLV(tag, bytes.Join(parts, nil))
+}
+
+func berInt(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	// strip redundant leading bytes, keeping the sign bit
+	for len(b) > 1 && ((b[0] == 0 && b[1]&0x80 == 0) || (b[0] == 0xff && b[1]&0x80 != 0)) {
+		b = b[1:]
+	}
+	return berTLV(berInteger, b)
+}
+
+func berOctets(b []byte) []byte {
+	return berTLV(berOctetString, b)
+}
+
+func berOID(oid []uint32) []byte {
+	if len(oid) < 2 {
+		return berTLV(berObjectID, []byte{0})
+	}
+	b := []byte{byte(oid[0]*40 + oid[1])}
+	for _, v := range oid[2:] {
+		var sub []byte
+		sub = append(sub, byte(v&0x7f))
+		for v >>= 7; v > 0; v >>= 7 {
+			sub = append([]byte{byte(v&0x7f) | 0x80}, sub...)
+		}
+		b = append(b, sub...)
+	}
+	return berTLV(berObjectID, b)
+}
+
+type berValue struct {
+	tag     byte
+	content []byte
+}
+
+// berRead decodes one TLV from b.
+func berRead(b []byte) (berValue, []byte, error) {
+	if len(b) < 2 {
+		return berValue{}, nil, fmt.Errorf("berRead: short buffer")
+	}
+	tag := b[0]
+	n := int(b[1])
+	b = b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < size {
+			return berValue{}, nil, fmt.Errorf("berRead: bad length")
+		}
+		n = 0
+		for _, c := range b[:size] {
+			n = n<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if n > len(b) {
+		return berValue{}, nil, fmt.Errorf("berRead: truncated value: tag=0x%02x length=%d", tag, n)
+	}
+	return berValue{tag: tag, content: b[:n]}, b[n:], nil
+}
+
+// berReadAll decodes all TLVs in b.
+func berReadAll(b []byte) ([]berValue, error) {
+	var list []berValue
+	for len(b) > 0 {
+		v, rest, err := berRead(b)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		b = rest
+	}
+	return list, nil
+}
+
+func (v berValue) int() int64 {
+	var n int64
+	if len(v.content) > 0 && v.content[0]&0x80 != 0 {
+		n = -1
+	}
+	for _, c := range v.content {
+		n = n<<8 | int64(c)
+	}
+	return n
+}
+
+func (v berValue) uint() uint64 {
+	var n uint64
+	for _, c := range v.content {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+func (v berValue) oid() []uint32 {
+	if len(v.content) == 0 {
+		return nil
+	}
+	oid := []uint32{uint32(v.content[0]) / 40, uint32(v.content[0]) % 40}
+	var sub uint32
+	for _, c := range v.content[1:] {
+		sub = sub<<7 | uint32(c&0x7f)
+		if c&0x80 == 0 {
+			oid = append(oid, sub)
+			sub = 0
+		}
+	}
+	return oid
+}
+
+func parseOID(s string) []uint32 {
+	var oid []uint32
+	for _, f := range strings.Split(strings.Trim(s, "."), ".") {
+		var v uint32
+		fmt.Sscanf(f, "%d", &v)
+		oid = append(oid, v)
+	}
+	return oid
+}
+
+func oidString(oid []uint32) string {
+	f := make([]string, len(oid))
+	for i, v := range oid {
+		f[i] = strconv.FormatUint(uint64(v), 10)
+	}
+	return strings.Join(f, ".")
+}
+
+func oidHasPrefix(oid, prefix []uint32) bool {
+	if len(oid) < len(prefix) {
+		return false
+	}
+	for i, v := range prefix {
+		if oid[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// oidCompare returns -1, 0 or 1 as a sorts before, equal to or after b,
+// in lexicographic order.
+func oidCompare(a, b []uint32) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+type snmpVarbind struct {
+	oid   []uint32
+	value berValue
+}
+
+type snmpClient struct {
+	conn      net.Conn
+	version   string // 2c or 3
+	community string
+	timeout   time.Duration
+	retries   int
+	requestID int32
+
+	// SNMPv3 USM
+	user        string
+	authHash    func() hash.Hash // nil for noAuth
+	authPass    string
+	privPass    string // empty for noPriv
+	authKey     []byte
+	privKey     []byte
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	timeBase    time.Time
+	salt        uint64
+}
+
+type snmpOptions struct {
+	version   string
+	community string
+	user      string
+	authProto string // MD5 or SHA
+	authPass  string
+	privProto string // AES
+	privPass  string
+	timeout   time.Duration
+	retries   int
+}
+
+func newSnmpClient(target string, opts snmpOptions) (*snmpClient, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "161")
+	}
+
+	c := &snmpClient{
+		version:   opts.version,
+		community: opts.community,
+		timeout:   opts.timeout,
+		retries:   opts.retries,
+		user:      opts.user,
+		authPass:  opts.authPass,
+		privPass:  opts.privPass,
+	}
+
+	switch opts.version {
+	case "2c":
+	case "3":
+		if opts.user == "" {
+			return nil, fmt.Errorf("newSnmpClient: snmp v3 requires user")
+		}
+		switch strings.ToUpper(opts.authProto) {
+		case "":
+			if opts.privPass != "" {
+				return nil, fmt.Errorf("newSnmpClient: snmp v3 privacy requires authentication")
+			}
+		case "MD5":
+			c.authHash = md5.New
+		case "SHA":
+			c.authHash = sha1.New
+		default:
+			return nil, fmt.Errorf("newSnmpClient: unsupported auth protocol: [%s] (use MD5 or SHA)", opts.authProto)
+		}
+		if c.authHash != nil && len(opts.authPass) < usmMinPassLength {
+			return nil, fmt.Errorf("newSnmpClient: snmp v3 authentication requires a passphrase of at least %d characters", usmMinPassLength)
+		}
+		if opts.privPass != "" && strings.ToUpper(opts.privProto) != "AES" {
+			return nil, fmt.Errorf("newSnmpClient: unsupported privacy protocol: [%s] (use AES)", opts.privProto)
+		}
+		if opts.privPass != "" && len(opts.privPass) < usmMinPassLength {
+			return nil, fmt.Errorf("newSnmpClient: snmp v3 privacy requires a passphrase of at least %d characters", usmMinPassLength)
+		}
+	default:
+		return nil, fmt.Errorf("newSnmpClient: unsupported snmp version: [%s] (use 2c or 3)", opts.version)
+	}
+
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("newSnmpClient: %v", err)
+	}
+	c.conn = conn
+
+	var seed [8]byte
+	rand.Read(seed[:])
+	c.salt = binary.BigEndian.Uint64(seed[:])
+	c.requestID = int32(binary.BigEndian.Uint32(seed[:4]) & 0x7fffffff)
+
+	if c.version == "3" {
+		if err := c.discover(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *snmpClient) close() {
+	c.conn.Close()
+}
+
+func (c *snmpClient) nextRequestID() int32 {
+	c.requestID = (c.requestID + 1) & 0x7fffffff
+	return c.requestID
+}
+
+func encodePDU(tag byte, reqID int32, a, b int, oids [][]uint32) []byte {
+	var vbs [][]byte
+	for _, oid := range oids {
+		vbs = append(vbs, berSeq(berSequence, berOID(oid), berTLV(berNull, nil)))
+	}
+	return berSeq(tag, berInt(int64(reqID)), berInt(int64(a)), berInt(int64(b)), berSeq(berSequence, vbs...))
+}
+
+// decodePDU returns the pdu tag, request id, error status and varbinds.
+func decodePDU(pdu berValue) (int32, int64, []snmpVarbind, error) {
+	fields, err := berReadAll(pdu.content)
+	if err != nil || len(fields) != 4 {
+		return 0, 0, nil, fmt.Errorf("decodePDU: bad pdu")
+	}
+	vbList, err := berReadAll(fields[3].content)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("decodePDU: bad varbind list: %v", err)
+	}
+	var vbs []snmpVarbind
+	for _, vb := range vbList {
+		pair, err := berReadAll(vb.content)
+		if err != nil || len(pair) != 2 || pair[0].tag != berObjectID {
+			return 0, 0, nil, fmt.Errorf("decodePDU: bad varbind")
+		}
+		vbs = append(vbs, snmpVarbind{oid: pair[0].oid(), value: pair[1]})
+	}
+	return int32(fields[0].int()), fields[1].int(), vbs, nil
+}
+
+// exchange sends a request and waits for the matching reply, retrying on timeout.
+func (c *snmpClient) exchange(req []byte, match func(reply []byte) bool) ([]byte, error) {
+	buf := make([]byte, 65535)
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if _, err := c.conn.Write(req); err != nil {
+			return nil, fmt.Errorf("exchange: %v", err)
+		}
+		deadline := time.Now().Add(c.timeout)
+		for {
+			c.conn.SetReadDeadline(deadline)
+			n, err := c.conn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break // retry
+				}
+				return nil, fmt.Errorf("exchange: %v", err)
+			}
+			reply := append([]byte(nil), buf[:n]...)
+			if match(reply) {
+				return reply, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("exchange: timeout after %d attempts", c.retries+1)
+}
+
+// getBulk issues a GetBulk request for oids.
+func (c *snmpClient) getBulk(oids [][]uint32, maxRepetitions int) ([]snmpVarbind, error) {
+	if c.version == "3" {
+		return c.getBulkV3(oids, maxRepetitions, true)
+	}
+
+	reqID := c.nextRequestID()
+	pdu := encodePDU(pduGetBulk, reqID, 0, maxRepetitions, oids)
+	msg := berSeq(berSequence, berInt(1), berOctets([]byte(c.community)), pdu)
+
+	var vbs []snmpVarbind
+	var status int64
+	_, err := c.exchange(msg, func(reply []byte) bool {
+		top, _, err := berRead(reply)
+		if err != nil {
+			return false
+		}
+		fields, err := berReadAll(top.content)
+		if err != nil || len(fields) != 3 || fields[2].tag != pduResponse {
+			return false
+		}
+		id, st, list, err := decodePDU(fields[2])
+		if err != nil || id != reqID {
+			return false
+		}
+		status, vbs = st, list
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getBulk: %v", err)
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("getBulk: error status %d", status)
+	}
+	return vbs, nil
+}
+
+// walk retrieves every varbind under root. An agent returning an OID not
+// past the previous one would loop forever, and fails the walk.
+func (c *snmpClient) walk(root []uint32, fn func(vb snmpVarbind) error) error {
+	next := root
+	for {
+		vbs, err := c.getBulk([][]uint32{next}, 25)
+		if err != nil {
+			return fmt.Errorf("walk: %v", err)
+		}
+		if len(vbs) == 0 {
+			return nil
+		}
+		for _, vb := range vbs {
+			if vb.value.tag == berEndOfMibView || !oidHasPrefix(vb.oid, root) {
+				return nil
+			}
+			if oidCompare(vb.oid, next) <= 0 {
+				return fmt.Errorf("walk: agent returned OID %s not increasing after %s", oidString(vb.oid), oidString(next))
+			}
+			if err := fn(vb); err != nil {
+				return err
+			}
+			next = vb.oid
+		}
+	}
+}
+
+// SNMPv3 USM (RFC 3414, RFC 3826)
+
+const (
+	v3FlagAuth       = 0x01
+	v3FlagPriv       = 0x02
+	v3FlagReportable = 0x04
+	usmSecurityModel = 3
+	usmMinPassLength = 8 // RFC 3414 section 11.2
+)
+
+// localizeKey implements password to key and key localization (RFC 3414 A.2).
+func localizeKey(h func() hash.Hash, password string, engineID []byte) []byte {
+	hh := h()
+	buf := make([]byte, 64)
+	pw := []byte(password)
+	idx := 0
+	for count := 0; count < 1048576; count += len(buf) {
+		for i := range buf {
+			buf[i] = pw[idx%len(pw)]
+			idx++
+		}
+		hh.Write(buf)
+	}
+	ku := hh.Sum(nil)
+	hh = h()
+	hh.Write(ku)
+	hh.Write(engineID)
+	hh.Write(ku)
+	return hh.Sum(nil)
+}
+
+func (c *snmpClient) flags() byte {
+	f := byte(v3FlagReportable)
+	if c.authHash != nil {
+		f |= v3FlagAuth
+	}
+	if c.privPass != "" {
+		f |= v3FlagPriv
+	}
+	return f
+}
+
+// discover learns the agent engine id, boots and time from a report.
+func (c *snmpClient) discover() error {
+	msgID := c.nextRequestID()
+	usm := berSeq(berSequence, berOctets(nil), berInt(0), berInt(0), berOctets(nil), berOctets(nil), berOctets(nil))
+	scoped := berSeq(berSequence, berOctets(nil), berOctets(nil), encodePDU(pduGetRequest, msgID, 0, 0, nil))
+	msg := berSeq(berSequence, berInt(3),
+		berSeq(berSequence, berInt(int64(msgID)), berInt(65507), berOctets([]byte{v3FlagReportable}), berInt(usmSecurityModel)),
+		berOctets(usm), scoped)
+
+	_, err := c.exchange(msg, func(reply []byte) bool {
+		m, err := parseV3Message(reply)
+		if err != nil || m.msgID != msgID {
+			return false
+		}
+		c.syncEngine(m)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("discover: %v", err)
+	}
+	if len(c.engineID) == 0 {
+		return fmt.Errorf("discover: agent reported empty engine id")
+	}
+
+	if c.authHash != nil {
+		c.authKey = localizeKey(c.authHash, c.authPass, c.engineID)
+		if c.privPass != "" {
+			c.privKey = localizeKey(c.authHash, c.privPass, c.engineID)[:16]
+		}
+	}
+
+	return nil
+}
+
+func (c *snmpClient) syncEngine(m *v3Message) {
+	c.engineID = m.engineID
+	c.engineBoots = m.engineBoots
+	c.engineTime = m.engineTime
+	c.timeBase = time.Now()
+}
+
+func (c *snmpClient) currentEngineTime() int64 {
+	return c.engineTime + int64(time.Since(c.timeBase)/time.Second)
+}
+
+type v3Message struct {
+	msgID       int32
+	flags       byte
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	authParams  []byte
+	privParams  []byte
+	data        berValue // scoped pdu or encrypted pdu
+}
+
+func parseV3Message(b []byte) (*v3Message, error) {
+	top, _, err := berRead(b)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := berReadAll(top.content)
+	if err != nil || len(fields) != 4 || fields[0].int() != 3 {
+		return nil, fmt.Errorf("parseV3Message: not a v3 message")
+	}
+	global, err := berReadAll(fields[1].content)
+	if err != nil || len(global) != 4 || len(global[2].content) != 1 {
+		return nil, fmt.Errorf("parseV3Message: bad global data")
+	}
+	usmOuter, _, err := berRead(fields[2].content)
+	if err != nil {
+		return nil, fmt.Errorf("parseV3Message: bad security parameters")
+	}
+	usm, err := berReadAll(usmOuter.content)
+	if err != nil || len(usm) != 6 {
+		return nil, fmt.Errorf("parseV3Message: bad usm parameters")
+	}
+	return &v3Message{
+		msgID:       int32(global[0].int()),
+		flags:       global[2].content[0],
+		engineID:    usm[0].content,
+		engineBoots: usm[1].int(),
+		engineTime:  usm[2].int(),
+		authParams:  usm[4].content,
+		privParams:  usm[5].content,
+		data:        fields[3],
+	}, nil
+}
+
+func (c *snmpClient) privIV(boots, engineTime int64, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv[0:], uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+func (c *snmpClient) aesCFB(encrypt bool, iv, data []byte) []byte {
+	block, _ := aes.NewCipher(c.privKey)
+	out := make([]byte, len(data))
+	if encrypt {
+		cipher.NewCFBEncrypter(block, iv).XORKeyStream(out, data)
+	} else {
+		cipher.NewCFBDecrypter(block, iv).XORKeyStream(out, data)
+	}
+	return out
+}
+
+func (c *snmpClient) sign(msg []byte) []byte {
+	mac := hmac.New(c.authHash, c.authKey)
+	mac.Write(msg)
+	return mac.Sum(nil)[:12]
+}
+
+func (c *snmpClient) encodeV3(msgID int32, pdu []byte) []byte {
+	boots, engineTime := c.engineBoots, c.currentEngineTime()
+	flags := c.flags()
+
+	data := berSeq(berSequence, berOctets(c.engineID), berOctets(nil), pdu)
+
+	var privParams []byte
+	if flags&v3FlagPriv != 0 {
+		c.salt++
+		privParams = make([]byte, 8)
+		binary.BigEndian.PutUint64(privParams, c.salt)
+		data = berOctets(c.aesCFB(true, c.privIV(boots, engineTime, privParams), data))
+	}
+
+	build := func(authParams []byte) []byte {
+		usm := berSeq(berSequence, berOctets(c.engineID), berInt(boots), berInt(engineTime),
+			berOctets([]byte(c.user)), berOctets(authParams), berOctets(privParams))
+		return berSeq(berSequence, berInt(3),
+			berSeq(berSequence, berInt(int64(msgID)), berInt(65507), berOctets([]byte{flags}), berInt(usmSecurityModel)),
+			berOctets(usm), data)
+	}
+
+	if flags&v3FlagAuth == 0 {
+		return build(nil)
+	}
+	return build(c.sign(build(make([]byte, 12))))
+}
+
+// verify checks the authentication parameters of a received message.
+func (c *snmpClient) verify(raw []byte, m *v3Message) bool {
+	if c.authHash == nil {
+		return true
+	}
+	if m.flags&v3FlagAuth == 0 || len(m.authParams) != 12 {
+		return false
+	}
+	i := bytes.Index(raw, m.authParams)
+	if i < 0 {
+		return false
+	}
+	zeroed := append([]byte(nil), raw...)
+	copy(zeroed[i:i+12], make([]byte, 12))
+	return hmac.Equal(c.sign(zeroed), m.authParams)
+}
+
+func (c *snmpClient) getBulkV3(oids [][]uint32, maxRepetitions int, resync bool) ([]snmpVarbind, error) {
+	msgID := c.nextRequestID()
+	req := c.encodeV3(msgID, encodePDU(pduGetBulk, msgID, 0, maxRepetitions, oids))
+
+	var pduTag byte
+	var status int64
+	var vbs []snmpVarbind
+	var reply *v3Message
+
+	_, err := c.exchange(req, func(raw []byte) bool {
+		m, err := parseV3Message(raw)
+		if err != nil || m.msgID != msgID {
+			return false
+		}
+		data := m.data
+		if m.flags&v3FlagPriv != 0 {
+			if c.privKey == nil || len(m.privParams) != 8 {
+				return false
+			}
+			plain := c.aesCFB(false, c.privIV(m.engineBoots, m.engineTime, m.privParams), m.data.content)
+			if data, _, err = berRead(plain); err != nil {
+				return false
+			}
+		}
+		scoped, err := berReadAll(data.content)
+		if err != nil || len(scoped) != 3 {
+			return false
+		}
+		if scoped[2].tag != pduReport && !c.verify(raw, m) {
+			return false
+		}
+		_, st, list, err := decodePDU(scoped[2])
+		if err != nil {
+			return false
+		}
+		pduTag, status, vbs, reply = scoped[2].tag, st, list, m
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getBulkV3: %v", err)
+	}
+
+	if pduTag == pduReport {
+		// usually notInTimeWindow: resynchronize engine time and try once more
+		oid := ""
+		if len(vbs) > 0 {
+			oid = fmt.Sprint(vbs[0].oid)
+		}
+		if !resync {
+			return nil, fmt.Errorf("getBulkV3: agent sent report: %s", oid)
+		}
+		c.syncEngine(reply)
+		return c.getBulkV3(oids, maxRepetitions, false)
+	}
+	if status != 0 {
+		return nil, fmt.Errorf("getBulkV3: error status %d", status)
+	}
+
+	return vbs, nil
+}
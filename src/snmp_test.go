@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBerInt(t *testing.T) {
+	cases := []struct {
+		v    int64
+		want string
+	}{
+		{0, "020100"},
+		{127, "02017f"},
+		{128, "02020080"},
+		{256, "02020100"},
+		{-1, "0201ff"},
+		{-129, "0202ff7f"},
+		{65507, "020300ffe3"},
+	}
+	for _, c := range cases {
+		b := berInt(c.v)
+		if got := hex.EncodeToString(b); got != c.want {
+			t.Errorf("berInt(%d) = %s, want %s", c.v, got, c.want)
+		}
+		v, rest, err := berRead(b)
+		if err != nil || len(rest) != 0 || v.int() != c.v {
+			t.Errorf("berRead(berInt(%d)): %d %v", c.v, v.int(), err)
+		}
+	}
+}
+
+func TestBerLength(t *testing.T) {
+	for n, want := range map[int]string{0: "00", 127: "7f", 128: "8180", 300: "82012c"} {
+		if got := hex.EncodeToString(berLength(n)); got != want {
+			t.Errorf("berLength(%d) = %s, want %s", n, got, want)
+		}
+	}
+	long := berOctets(bytes.Repeat([]byte{'x'}, 300))
+	if v, _, err := berRead(long); err != nil || len(v.content) != 300 {
+		t.Errorf("berRead long form: %d bytes, %v", len(v.content), err)
+	}
+	for _, bad := range []string{"", "04", "0405abcd", "0480", "0485ffffffffff"} {
+		b, _ := hex.DecodeString(bad)
+		if _, _, err := berRead(b); err == nil {
+			t.Errorf("berRead(%s): want error", bad)
+		}
+	}
+}
+
+func TestBerOID(t *testing.T) {
+	cases := []struct {
+		oid, want string
+	}{
+		{"1.3.6.1.2.1.15.3.1.2", "06092b060102010f030102"},
+		{"1.3.6.1.4.1.9.9.187", "06092b060104010909813b"},
+		{".1.3.6.1.2.1.15.3.1.7.198.51.100.1", "060e2b060102010f0301078146336401"},
+	}
+	for _, c := range cases {
+		b := berOID(parseOID(c.oid))
+		if got := hex.EncodeToString(b); got != c.want {
+			t.Errorf("berOID(%s) = %s, want %s", c.oid, got, c.want)
+		}
+		v, _, err := berRead(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := oidString(v.oid()); got != strings.Trim(c.oid, ".") {
+			t.Errorf("oid round trip: got %s, want %s", got, c.oid)
+		}
+	}
+}
+
+func TestOIDCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.3.6.1", "1.3.6.1", 0},
+		{"1.3.6.1", "1.3.6.1.2", -1},
+		{"1.3.6.2", "1.3.6.1.2", 1},
+		{"1.3.6.1.10", "1.3.6.1.9", 1},
+	}
+	for _, c := range cases {
+		if got := oidCompare(parseOID(c.a), parseOID(c.b)); got != c.want {
+			t.Errorf("oidCompare(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+	if !oidHasPrefix(parseOID("1.3.6.1.2.1.15.3.1.2.10.0.0.1"), parseOID("1.3.6.1.2.1.15.3.1.2")) {
+		t.Error("oidHasPrefix: want true")
+	}
+	if oidHasPrefix(parseOID("1.3.6.1.2"), parseOID("1.3.6.1.2.1")) {
+		t.Error("oidHasPrefix of a shorter oid: want false")
+	}
+}
+
+func TestDecodePDU(t *testing.T) {
+	oids := [][]uint32{parseOID("1.3.6.1.2.1.15.3.1.2"), parseOID("1.3.6.1.2.1.15.3.1.9")}
+	v, _, err := berRead(encodePDU(pduGetBulk, 4242, 0, 25, oids))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.tag != pduGetBulk {
+		t.Errorf("tag 0x%02x, want 0x%02x", v.tag, pduGetBulk)
+	}
+	reqID, status, vbs, err := decodePDU(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqID != 4242 || status != 0 || len(vbs) != 2 || oidString(vbs[1].oid) != "1.3.6.1.2.1.15.3.1.9" || vbs[1].value.tag != berNull {
+		t.Errorf("got id=%d status=%d varbinds=%v", reqID, status, vbs)
+	}
+	if _, _, _, err := decodePDU(berValue{tag: pduResponse, content: berInt(1)}); err == nil {
+		t.Error("short pdu: want error")
+	}
+}
+
+// TestLocalizeKey checks the RFC 3414 A.3 sample keys.
+func TestLocalizeKey(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	cases := []struct {
+		name string
+		h    func() hash.Hash
+		want string
+	}{
+		{"MD5", md5.New, "526f5eed9fcce26f8964c2930787d82b"},
+		{"SHA", sha1.New, "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(localizeKey(c.h, "maplesyrup", engineID)); got != c.want {
+			t.Errorf("%s: localized key %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestEncodeV3(t *testing.T) {
+	engineID, _ := hex.DecodeString("80000009030000c1b2a3f4e5")
+	pdu := encodePDU(pduGetBulk, 77, 0, 10, [][]uint32{parseOID("1.3.6.1.2.1.15.3.1.2")})
+	cases := []struct {
+		name     string
+		h        func() hash.Hash
+		privPass string
+		flags    byte
+	}{
+		{"noAuthNoPriv", nil, "", v3FlagReportable},
+		{"authNoPriv MD5", md5.New, "", v3FlagReportable | v3FlagAuth},
+		{"authPriv SHA", sha1.New, "privpass123", v3FlagReportable | v3FlagAuth | v3FlagPriv},
+	}
+	for _, c := range cases {
+		cl := &snmpClient{user: "monitor", authHash: c.h, privPass: c.privPass, engineID: engineID,
+			engineBoots: 5, engineTime: 1000, timeBase: time.Now(), salt: 41}
+		if c.h != nil {
+			cl.authKey = localizeKey(c.h, "authpass123", engineID)
+			if c.privPass != "" {
+				cl.privKey = localizeKey(c.h, c.privPass, engineID)[:16]
+			}
+		}
+
+		raw := cl.encodeV3(77, pdu)
+		m, err := parseV3Message(raw)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if m.msgID != 77 || m.flags != c.flags || !bytes.Equal(m.engineID, engineID) || m.engineBoots != 5 || m.engineTime < 1000 {
+			t.Errorf("%s: got id=%d flags=%x engine=%x boots=%d time=%d", c.name, m.msgID, m.flags, m.engineID, m.engineBoots, m.engineTime)
+		}
+		if !cl.verify(raw, m) {
+			t.Errorf("%s: signature does not verify", c.name)
+		}
+
+		data := m.data
+		if c.privPass != "" {
+			if len(m.privParams) != 8 || m.data.tag != berOctetString {
+				t.Errorf("%s: priv params %x, data tag 0x%02x", c.name, m.privParams, m.data.tag)
+				continue
+			}
+			plain := cl.aesCFB(false, cl.privIV(m.engineBoots, m.engineTime, m.privParams), m.data.content)
+			if data, _, err = berRead(plain); err != nil {
+				t.Errorf("%s: decrypted data: %v", c.name, err)
+				continue
+			}
+		}
+		scoped, err := berReadAll(data.content)
+		if err != nil || len(scoped) != 3 || !bytes.Equal(scoped[0].content, engineID) {
+			t.Errorf("%s: bad scoped pdu: %v", c.name, err)
+			continue
+		}
+		if reqID, _, _, err := decodePDU(scoped[2]); err != nil || reqID != 77 {
+			t.Errorf("%s: pdu id %d: %v", c.name, reqID, err)
+		}
+
+		if c.h != nil {
+			tampered := append([]byte(nil), raw...)
+			tampered[len(tampered)-1] ^= 0xff
+			if m, err := parseV3Message(tampered); err == nil && cl.verify(tampered, m) {
+				t.Errorf("%s: tampered message verifies", c.name)
+			}
+		}
+	}
+
+	if _, err := parseV3Message(berSeq(berSequence, berInt(1), berOctets([]byte("public")))); err == nil {
+		t.Error("v1 message: want error")
+	}
+}
+
+func TestPrivSalt(t *testing.T) {
+	engineID, _ := hex.DecodeString("80000009030000c1b2a3f4e5")
+	cl := &snmpClient{user: "monitor", authHash: sha1.New, privPass: "privpass123", engineID: engineID, timeBase: time.Now()}
+	cl.authKey = localizeKey(sha1.New, "authpass123", engineID)
+	cl.privKey = localizeKey(sha1.New, cl.privPass, engineID)[:16]
+	pdu := encodePDU(pduGetBulk, 1, 0, 10, nil)
+	a, _ := parseV3Message(cl.encodeV3(1, pdu))
+	b, _ := parseV3Message(cl.encodeV3(1, pdu))
+	if bytes.Equal(a.privParams, b.privParams) || bytes.Equal(a.data.content, b.data.content) {
+		t.Error("two messages share the privacy salt")
+	}
+}
+
+func TestNewSnmpClientOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		opts snmpOptions
+		want string // in the error
+	}{
+		{"version", snmpOptions{version: "1"}, "unsupported snmp version"},
+		{"no user", snmpOptions{version: "3"}, "requires user"},
+		{"auth proto", snmpOptions{version: "3", user: "u", authProto: "SHA256", authPass: "authpass123"}, "unsupported auth protocol"},
+		{"short auth", snmpOptions{version: "3", user: "u", authProto: "MD5", authPass: "short"}, "authentication requires a passphrase of at least 8"},
+		{"priv without auth", snmpOptions{version: "3", user: "u", privProto: "AES", privPass: "privpass123"}, "privacy requires authentication"},
+		{"priv proto", snmpOptions{version: "3", user: "u", authProto: "SHA", authPass: "authpass123", privProto: "DES", privPass: "privpass123"}, "unsupported privacy protocol"},
+		{"short priv", snmpOptions{version: "3", user: "u", authProto: "sha", authPass: "authpass123", privProto: "aes", privPass: "short"}, "privacy requires a passphrase of at least 8"},
+	}
+	for _, c := range cases {
+		_, err := newSnmpClient("192.0.2.1", c.opts)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
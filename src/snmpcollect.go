@@ -0,0 +1,153 @@
+package main
+
+// collect neighbors from CISCO-BGP4-MIB cbgpPeer2Table
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+var (
+	oidCbgpPeer2Entry            = parseOID("1.3.6.1.4.1.9.9.187.1.2.5.1")
+	oidCbgpPeer2AddrFamilyPrefix = parseOID("1.3.6.1.4.1.9.9.187.1.2.8.1")
+)
+
+// cbgpPeer2Entry columns
+const (
+	cbgpPeer2State              = 3
+	cbgpPeer2AdminStatus        = 4
+	cbgpPeer2RemoteAs           = 11
+	cbgpPeer2FsmEstablishedTime = 19
+)
+
+// cbgpPeer2AddrFamilyPrefixEntry columns
+const cbgpPeer2AcceptedPrefixes = 1
+
+const (
+	inetAddressTypeIPv4       = 1
+	inetAddressTypeIPv6       = 2
+	cbgpPeer2AdminStop        = 1
+	cbgpPeer2StateEstablished = 6
+)
+
+var cbgpPeer2StateNames = map[int64]string{1: "Idle", 2: "Connect", 3: "Active", 4: "OpenSent", 5: "OpenConfirm", 6: "Established"}
+
+// peerIndexAddr decodes the InetAddressType.InetAddress table index
+// and returns the address plus the remaining index suffix.
+func peerIndexAddr(index []uint32) (string, []uint32, error) {
+	if len(index) < 2 {
+		return "", nil, fmt.Errorf("peerIndexAddr: short index: %v", index)
+	}
+	addrType, size := index[0], int(index[1])
+	if len(index) < 2+size {
+		return "", nil, fmt.Errorf("peerIndexAddr: short address: %v", index)
+	}
+	ip := make(net.IP, size)
+	for i := range ip {
+		ip[i] = byte(index[2+i])
+	}
+	if (addrType == inetAddressTypeIPv4 && size != 4) || (addrType == inetAddressTypeIPv6 && size != 16) {
+		return "", nil, fmt.Errorf("peerIndexAddr: bad address type=%d size=%d", addrType, size)
+	}
+	return ip.String(), index[2+size:], nil
+}
+
+// snmpCollect walks cbgpPeer2Table and returns the same table the text parser would.
+// The MIB does not carry VRF names, so every neighbor is reported with vrf "--".
+func snmpCollect(target string, opts snmpOptions) (map[string]*neigh, error) {
+	debugf("snmpCollect: walking cbgpPeer2Table on %s (snmp v%s)", target, opts.version)
+
+	c, err := newSnmpClient(target, opts)
+	if err != nil {
+		return nil, fmt.Errorf("snmpCollect: %v", err)
+	}
+	defer c.close()
+
+	type peer struct {
+		state, admin int64
+		asn          uint64
+		established  uint64
+		prefixes     int
+	}
+	peers := map[string]*peer{}
+	get := func(addr string) *peer {
+		p, ok := peers[addr]
+		if !ok {
+			p = &peer{}
+			peers[addr] = p
+		}
+		return p
+	}
+
+	err = c.walk(oidCbgpPeer2Entry, func(vb snmpVarbind) error {
+		suffix := vb.oid[len(oidCbgpPeer2Entry):]
+		if len(suffix) < 1 {
+			return nil
+		}
+		col := suffix[0]
+		if col != cbgpPeer2State && col != cbgpPeer2AdminStatus && col != cbgpPeer2RemoteAs && col != cbgpPeer2FsmEstablishedTime {
+			return nil
+		}
+		addr, _, errAddr := peerIndexAddr(suffix[1:])
+		if errAddr != nil {
+			warnf("snmpCollect: %v", errAddr)
+			return nil
+		}
+		p := get(addr)
+		switch col {
+		case cbgpPeer2State:
+			p.state = vb.value.int()
+		case cbgpPeer2AdminStatus:
+			p.admin = vb.value.int()
+		case cbgpPeer2RemoteAs:
+			p.asn = vb.value.uint()
+		case cbgpPeer2FsmEstablishedTime:
+			p.established = vb.value.uint()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snmpCollect: cbgpPeer2Table: %v", err)
+	}
+
+	err = c.walk(oidCbgpPeer2AddrFamilyPrefix, func(vb snmpVarbind) error {
+		suffix := vb.oid[len(oidCbgpPeer2AddrFamilyPrefix):]
+		if len(suffix) < 1 || suffix[0] != cbgpPeer2AcceptedPrefixes {
+			return nil
+		}
+		addr, _, errAddr := peerIndexAddr(suffix[1:])
+		if errAddr != nil {
+			warnf("snmpCollect: %v", errAddr)
+			return nil
+		}
+		get(addr).prefixes += int(vb.value.uint()) // sum over address families
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snmpCollect: cbgpPeer2AddrFamilyPrefixTable: %v", err)
+	}
+
+	table := map[string]*neigh{}
+	for addr, p := range peers {
+		n := &neigh{Addr: addr, VRF: "--", RemoteAS: strconv.FormatUint(p.asn, 10), Prefixes: p.prefixes}
+		n.State = cbgpPeer2StateNames[p.state]
+		if n.State == "" {
+			n.State = "?"
+		}
+		if p.admin == cbgpPeer2AdminStop {
+			n.State = "Idle" // as "BGP state = Idle (Admin)", see applyStateMarker
+			n.Shutdown = true
+		}
+		n.setUptime("?")
+		if p.state == cbgpPeer2StateEstablished {
+			n.setUptime(formatUptime(time.Duration(p.established) * time.Second))
+		}
+		table[neighKey(n)] = n
+	}
+
+	infof("snmpCollect: found %d neighbors", len(table))
+
+	return table, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type compareFunc func(a, b *neigh) int
+
+var sortKeys = map[string]compareFunc{
+	"device":   func(a, b *neigh) int { return strings.Compare(a.Device, b.Device) },
+	"addr":     compareAddr,
+	"vrf":      func(a, b *neigh) int { return strings.Compare(a.VRF, b.VRF) },
+	"asn":      func(a, b *neigh) int { return compareNumeric(a.RemoteAS, b.RemoteAS) },
+	"state":    func(a, b *neigh) int { return strings.Compare(a.State, b.State) },
+	"prefixes": func(a, b *neigh) int { return compareInt(int64(a.Prefixes), int64(b.Prefixes)) },
+	"uptime":   compareUptime,
+}
+
+type sortKey struct {
+	compare compareFunc
+	desc    bool
+}
+
+// parseSortKeys parses a comma-separated list of sort keys.
+// A key may be suffixed with :asc (default) or :desc.
+// Example: vrf,prefixes:desc
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		name, order := s, "asc"
+		if i := strings.IndexByte(s, ':'); i >= 0 {
+			name, order = s[:i], s[i+1:]
+		}
+		cmp, ok := sortKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("parseSortKeys: unknown sort key: [%s]", name)
+		}
+		var desc bool
+		switch order {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("parseSortKeys: bad sort order: [%s]", s)
+		}
+		keys = append(keys, sortKey{compare: cmp, desc: desc})
+	}
+	return keys, nil
+}
+
+// sortNeighbors sorts neighbors by keys.
+// Ties are broken by address, vrf and device, so output is stable between runs.
+func sortNeighbors(list []*neigh, keys []sortKey) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		for _, k := range keys {
+			c := k.compare(a, b)
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		if c := compareAddr(a, b); c != 0 {
+			return c < 0
+		}
+		if a.VRF != b.VRF {
+			return a.VRF < b.VRF
+		}
+		return a.Device < b.Device
+	})
+}
+
+func neighborList(table map[string]*neigh) []*neigh {
+	list := make([]*neigh, 0, len(table))
+	for _, n := range table {
+		list = append(list, n)
+	}
+	return list
+}
+
+func compareAddr(a, b *neigh) int {
+	ipA := net.ParseIP(a.Addr)
+	ipB := net.ParseIP(b.Addr)
+	if ipA == nil || ipB == nil {
+		return strings.Compare(a.Addr, b.Addr)
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+// compareNumeric compares numerically when both values are integers.
+// Non-numeric values sort before numeric ones.
+func compareNumeric(a, b string) int {
+	va, errA := strconv.ParseInt(a, 10, 64)
+	vb, errB := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return compareInt(va, vb)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareUptime compares parsed uptimes.
+// Unknown uptimes (?, never) sort before known ones.
+func compareUptime(a, b *neigh) int {
+	da, okA := a.uptimeDuration()
+	db, okB := b.uptimeDuration()
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	return compareInt(int64(da), int64(db))
+}
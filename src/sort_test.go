@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortNeighbors(t *testing.T) {
+	up := func(s int64) *int64 { return &s }
+	table := []*neigh{
+		{Device: "pe2", Addr: "10.0.0.10", VRF: "CUST-A", RemoteAS: "65010", State: "Established", Prefixes: 5, UptimeSeconds: up(600)},
+		{Device: "pe1", Addr: "10.0.0.9", VRF: "CUST-B", RemoteAS: "65009", State: "Idle"},
+		{Device: "pe1", Addr: "2001:db8::1", VRF: "CUST-A", RemoteAS: "4200000001", State: "Established", Prefixes: 40, UptimeSeconds: up(86400)},
+		{Device: "pe1", Addr: "10.0.0.10", VRF: "CUST-A", RemoteAS: "65010", State: "Active", Prefixes: 5},
+		{Device: "pe1", Addr: "192.0.2.1", VRF: "CUST-B", RemoteAS: "?", State: "Established", Prefixes: 100, UptimeSeconds: up(60)},
+	}
+	cases := []struct {
+		spec string
+		want string // device/addr in order
+	}{
+		{"", "pe1/10.0.0.9 pe1/10.0.0.10 pe2/10.0.0.10 pe1/192.0.2.1 pe1/2001:db8::1"},
+		{"vrf,addr", "pe1/10.0.0.10 pe2/10.0.0.10 pe1/2001:db8::1 pe1/10.0.0.9 pe1/192.0.2.1"},
+		{"prefixes:desc", "pe1/192.0.2.1 pe1/2001:db8::1 pe1/10.0.0.10 pe2/10.0.0.10 pe1/10.0.0.9"},
+		{"asn", "pe1/192.0.2.1 pe1/10.0.0.9 pe1/10.0.0.10 pe2/10.0.0.10 pe1/2001:db8::1"},
+		{"uptime", "pe1/10.0.0.9 pe1/10.0.0.10 pe1/192.0.2.1 pe2/10.0.0.10 pe1/2001:db8::1"},
+		{"uptime:desc", "pe1/2001:db8::1 pe2/10.0.0.10 pe1/192.0.2.1 pe1/10.0.0.9 pe1/10.0.0.10"},
+		{"device, state:asc", "pe1/10.0.0.10 pe1/192.0.2.1 pe1/2001:db8::1 pe1/10.0.0.9 pe2/10.0.0.10"},
+	}
+	for _, c := range cases {
+		keys, err := parseSortKeys(c.spec)
+		if err != nil {
+			t.Errorf("parseSortKeys(%q): %v", c.spec, err)
+			continue
+		}
+		list := append([]*neigh(nil), table...)
+		sortNeighbors(list, keys)
+		var got []string
+		for _, n := range list {
+			got = append(got, n.Device+"/"+n.Addr)
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("sort %q:\ngot  %s\nwant %s", c.spec, strings.Join(got, " "), c.want)
+		}
+	}
+	for _, bad := range []string{"bogus", "vrf:up", "prefixes:"} {
+		if _, err := parseSortKeys(bad); err == nil {
+			t.Errorf("parseSortKeys(%q): want error", bad)
+		}
+	}
+}
+
+func TestCompareNumeric(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"65001", "65001", 0},
+		{"9", "65001", -1},
+		{"4200000001", "65001", 1},
+		{"?", "1", -1},
+		{"1", "?", 1},
+		{"1.10", "1.9", -1}, // asdot: compared as strings
+	}
+	for _, c := range cases {
+		if got := compareNumeric(c.a, c.b); got != c.want {
+			t.Errorf("compareNumeric(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// groupStat aggregates the neighbors sharing a VRF, remote AS or peer group.
+type groupStat struct {
+	Name        string `json:"name"`
+	Neighbors   int    `json:"neighbors"`
+	Established int    `json:"established"`
+	Prefixes    int    `json:"prefixes"`
+
+	RD       string   `json:"rd,omitempty"` // VRF groups only
+	ImportRT []string `json:"import_rt,omitempty"`
+	ExportRT []string `json:"export_rt,omitempty"`
+}
+
+// groupStats returns totals grouped by key, sorted by group name.
+func groupStats(list []*neigh, key func(n *neigh) string) []*groupStat {
+	byName := map[string]*groupStat{}
+	var stats []*groupStat
+	for _, n := range list {
+		name := key(n)
+		s, ok := byName[name]
+		if !ok {
+			s = &groupStat{Name: name}
+			byName[name] = s
+			stats = append(stats, s)
+		}
+		s.Neighbors++
+		if n.State == "Established" {
+			s.Established++
+		}
+		s.Prefixes += n.Prefixes
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// vrfStats joins the route distinguisher and route targets found in the
+// capture onto each VRF.
+func vrfStats(list []*neigh) []*groupStat {
+	stats := groupStats(list, func(n *neigh) string { return n.VRF })
+	byName := map[string]*groupStat{}
+	for _, s := range stats {
+		byName[s.Name] = s
+	}
+	for _, n := range list {
+		s := byName[n.VRF]
+		if s.RD == "" {
+			s.RD = n.RD
+		}
+		if s.ImportRT == nil {
+			s.ImportRT = n.ImportRT
+		}
+		if s.ExportRT == nil {
+			s.ExportRT = n.ExportRT
+		}
+	}
+	return stats
+}
+
+func asnStats(list []*neigh) []*groupStat {
+	stats := groupStats(list, func(n *neigh) string { return n.RemoteAS })
+	sort.SliceStable(stats, func(i, j int) bool { return compareNumeric(stats[i].Name, stats[j].Name) < 0 })
+	return stats
+}
+
+// noPeerGroup names the group of neighbors outside any peer group or template.
+const noPeerGroup = "(none)"
+
+func peerGroupStats(list []*neigh) []*groupStat {
+	return groupStats(list, func(n *neigh) string {
+		if n.PeerGroup == "" {
+			return noPeerGroup
+		}
+		return n.PeerGroup
+	})
+}
+
+type stateCount struct {
+	State     string `json:"state"`
+	Neighbors int    `json:"neighbors"`
+}
+
+type summaryReport struct {
+	Neighbors  int          `json:"neighbors"`
+	Prefixes   int          `json:"prefixes"`
+	States     []stateCount `json:"states"`
+	VRFs       []*groupStat `json:"vrfs"`
+	ASNs       []*groupStat `json:"asns"`
+	PeerGroups []*groupStat `json:"peer_groups"`
+	Top        []*neigh     `json:"top_prefixes"` // top neighbors by prefix count
+
+	Connections *connSummary `json:"connections,omitempty"` // detailed output only
+}
+
+// connSummary totals the connection counters. Top lists the neighbors
+// with the most drops, the fastest hint of a historically unstable session.
+type connSummary struct {
+	Neighbors   int      `json:"neighbors"` // with connection counters
+	Established int      `json:"established"`
+	Dropped     int      `json:"dropped"`
+	DropRatio   float64  `json:"drop_ratio"`
+	Top         []*neigh `json:"top_dropped"`
+}
+
+// newConnSummary returns nil when no neighbor has connection counters.
+func newConnSummary(list []*neigh, top int) *connSummary {
+	c := &connSummary{}
+	var dropped []*neigh
+	for _, n := range list {
+		if n.Connections == nil {
+			continue
+		}
+		c.Neighbors++
+		c.Established += n.Connections.Established
+		c.Dropped += n.Connections.Dropped
+		if n.Connections.Dropped > 0 {
+			dropped = append(dropped, n)
+		}
+	}
+	if c.Neighbors == 0 {
+		return nil
+	}
+	c.DropRatio = (&connStats{Established: c.Established, Dropped: c.Dropped}).dropRatio()
+	sort.SliceStable(dropped, func(i, j int) bool {
+		a, b := dropped[i].Connections, dropped[j].Connections
+		if a.Dropped != b.Dropped {
+			return a.Dropped > b.Dropped
+		}
+		return a.dropRatio() > b.dropRatio()
+	})
+	if len(dropped) > top {
+		dropped = dropped[:top]
+	}
+	c.Top = dropped
+	return c
+}
+
+// newSummaryReport aggregates list, keeping the top neighbors by prefix count.
+func newSummaryReport(list []*neigh, top int) *summaryReport {
+	r := &summaryReport{Neighbors: len(list), VRFs: vrfStats(list), ASNs: asnStats(list), PeerGroups: peerGroupStats(list)}
+
+	states := map[string]int{}
+	for _, n := range list {
+		r.Prefixes += n.Prefixes
+		states[n.State]++
+	}
+	for state, count := range states {
+		r.States = append(r.States, stateCount{State: state, Neighbors: count})
+	}
+	sort.Slice(r.States, func(i, j int) bool {
+		if r.States[i].Neighbors != r.States[j].Neighbors {
+			return r.States[i].Neighbors > r.States[j].Neighbors
+		}
+		return r.States[i].State < r.States[j].State
+	})
+
+	byPrefixes := append([]*neigh(nil), list...)
+	sortNeighbors(byPrefixes, []sortKey{{compare: sortKeys["prefixes"], desc: true}})
+	if len(byPrefixes) > top {
+		byPrefixes = byPrefixes[:top]
+	}
+	r.Top = byPrefixes
+	r.Connections = newConnSummary(list, top)
+
+	return r
+}
+
+func writeSummary(w io.Writer, r *summaryReport) {
+	fmt.Fprintf(w, "Neighbors: %d  Prefixes: %d\n", r.Neighbors, r.Prefixes)
+
+	fmt.Fprintf(w, "\n%-14s %9s\n", "State", "Neighbors")
+	for _, s := range r.States {
+		fmt.Fprintf(w, "%-14s %9d\n", s.State, s.Neighbors)
+	}
+
+	writeGroupStats(w, "VRF", r.VRFs)
+	writeGroupStats(w, "ASN", r.ASNs)
+	writeGroupStats(w, "Peer group", r.PeerGroups)
+
+	fmt.Fprintf(w, "\nTop %d neighbors by prefixes\n", len(r.Top))
+	writeTable(w, []*column{findColumn("addr"), findColumn("vrf"), findColumn("asn"), findColumn("state"), findColumn("prefixes")}, r.Top)
+
+	if c := r.Connections; c != nil {
+		fmt.Fprintf(w, "\nConnections established: %d  dropped: %d  drop ratio: %.2f\n", c.Established, c.Dropped, c.DropRatio)
+		if len(c.Top) > 0 {
+			fmt.Fprintf(w, "\nTop %d neighbors by connections dropped\n", len(c.Top))
+			writeTable(w, []*column{findColumn("addr"), findColumn("vrf"), findColumn("asn"), findColumn("state"), findColumn("conn_established"), findColumn("conn_dropped"), findColumn("drop_ratio")}, c.Top)
+		}
+	}
+}
+
+// writeGroupStats adds the RD and route target columns when any group has them.
+func writeGroupStats(w io.Writer, title string, stats []*groupStat) {
+	vrfInfo := false
+	for _, s := range stats {
+		if s.RD != "" || len(s.ImportRT) > 0 || len(s.ExportRT) > 0 {
+			vrfInfo = true
+		}
+	}
+	if !vrfInfo {
+		fmt.Fprintf(w, "\n%-14s %9s %11s %8s\n", title, "Neighbors", "Established", "Prefixes")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%-14s %9d %11d %8d\n", s.Name, s.Neighbors, s.Established, s.Prefixes)
+		}
+		return
+	}
+	fmt.Fprintf(w, "\n%-14s %9s %11s %8s %-15s %-20s %s\n", title, "Neighbors", "Established", "Prefixes", "RD", "Import RT", "Export RT")
+	for _, s := range stats {
+		row := fmt.Sprintf("%-14s %9d %11d %8d %-15s %-20s %s", s.Name, s.Neighbors, s.Established, s.Prefixes, s.RD, strings.Join(s.ImportRT, " "), strings.Join(s.ExportRT, " "))
+		fmt.Fprintln(w, strings.TrimRight(row, " "))
+	}
+}
+
+func writeSummaryJSON(w io.Writer, r *summaryReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("writeSummaryJSON: %v", err)
+	}
+	return nil
+}
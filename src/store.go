@@ -0,0 +1,290 @@
+package main
+
+// collection history kept in SQLite or PostgreSQL.
+// The database is driven through the sqlite3 and psql command-line clients,
+// keeping the tool free of database drivers.
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type historyStore struct {
+	driver string // sqlite or postgres
+	dsn    string // sqlite file path or psql connection string
+}
+
+// parseStore parses sqlite:path/to/history.db or postgres:connstring,
+// where connstring is anything psql accepts, e.g. postgres://user@host/db.
+func parseStore(spec string) (*historyStore, error) {
+	i := strings.IndexByte(spec, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("parseStore: expecting sqlite:path or postgres:connstring: [%s]", spec)
+	}
+	s := &historyStore{driver: spec[:i], dsn: spec[i+1:]}
+	if s.driver == "postgresql" {
+		s.driver = "postgres"
+	}
+	if s.driver == "postgres" && strings.HasPrefix(s.dsn, "//") {
+		s.dsn = spec // postgres://... URI
+	}
+	if s.driver != "sqlite" && s.driver != "postgres" {
+		return nil, fmt.Errorf("parseStore: unknown database: [%s]", s.driver)
+	}
+	if s.dsn == "" {
+		return nil, fmt.Errorf("parseStore: missing database: [%s]", spec)
+	}
+	return s, nil
+}
+
+// client returns the database client command, printing query results as
+// aligned columns with a header, or as CSV records without header.
+func (s *historyStore) client(csvOutput bool) *exec.Cmd {
+	if s.driver == "sqlite" {
+		if csvOutput {
+			return exec.Command("sqlite3", "-bail", "-csv", "-noheader", s.dsn)
+		}
+		return exec.Command("sqlite3", "-bail", "-header", "-column", s.dsn)
+	}
+	if csvOutput {
+		return exec.Command("psql", "-X", "-q", "-v", "ON_ERROR_STOP=1", "--csv", "-t", "-d", s.dsn)
+	}
+	return exec.Command("psql", "-X", "-q", "-v", "ON_ERROR_STOP=1", "-d", s.dsn)
+}
+
+// run feeds sql to the database client, copying query results to w.
+func (s *historyStore) run(sql string, w io.Writer) error {
+	cmd := s.client(false)
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("historyStore.run: %s: %v", cmd.Path, err)
+	}
+	return nil
+}
+
+func (s *historyStore) schema() string {
+	timeType := "TEXT" // ISO 8601, see storeTimeLayout
+	if s.driver == "postgres" {
+		timeType = "TIMESTAMPTZ"
+	}
+	return `CREATE TABLE IF NOT EXISTS bgp_neighbors (
+	collected_at ` + timeType + ` NOT NULL,
+	device TEXT NOT NULL,
+	vrf TEXT NOT NULL,
+	addr TEXT NOT NULL,
+	remote_as TEXT NOT NULL,
+	state TEXT NOT NULL,
+	prefixes INTEGER NOT NULL,
+	uptime_seconds BIGINT
+);
+CREATE INDEX IF NOT EXISTS bgp_neighbors_peer ON bgp_neighbors (device, vrf, addr, collected_at);
+`
+}
+
+// storeTimeLayout writes collected_at in UTC with microseconds, the
+// resolution of a PostgreSQL timestamp: runs within the same second stay
+// apart, and fixed width text sorts chronologically in SQLite.
+const storeTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// save records one collection run.
+func (s *historyStore) save(table map[string]*neigh, at time.Time) error {
+	var sql bytes.Buffer
+	sql.WriteString(s.schema())
+	sql.WriteString("BEGIN;\n")
+	ts := sqlQuote(at.UTC().Format(storeTimeLayout))
+	for _, n := range neighborList(table) {
+		uptime := "NULL"
+		if n.UptimeSeconds != nil {
+			uptime = strconv.FormatInt(*n.UptimeSeconds, 10)
+		}
+		fmt.Fprintf(&sql, "INSERT INTO bgp_neighbors VALUES (%s, %s, %s, %s, %s, %s, %d, %s);\n",
+			ts, sqlQuote(n.Device), sqlQuote(n.VRF), sqlQuote(n.Addr), sqlQuote(n.RemoteAS), sqlQuote(n.State), n.Prefixes, uptime)
+	}
+	sql.WriteString("COMMIT;\n")
+
+	if err := s.run(sql.String(), os.Stderr); err != nil {
+		return fmt.Errorf("historyStore.save: %v", err)
+	}
+
+	infof("historyStore.save: stored %d neighbors in %s", len(table), s.driver)
+
+	return nil
+}
+
+// historyRun is one recorded collection.
+type historyRun struct {
+	at    time.Time
+	table map[string]*neigh
+}
+
+const storeColumns = "collected_at, device, vrf, addr, remote_as, state, prefixes, uptime_seconds"
+
+// lastRun returns the neighbors recorded by the latest collection run,
+// or nil if none was recorded.
+func (s *historyStore) lastRun() (map[string]*neigh, error) {
+	runs, err := s.runs(`WHERE collected_at = (SELECT MAX(collected_at) FROM bgp_neighbors)`)
+	if err != nil {
+		return nil, fmt.Errorf("historyStore.lastRun: %v", err)
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return runs[0].table, nil
+}
+
+// runsSince returns the collection runs recorded since the given time, oldest first.
+func (s *historyStore) runsSince(since time.Time) ([]*historyRun, error) {
+	runs, err := s.runs("WHERE collected_at >= " + sqlQuote(since.UTC().Format(storeTimeLayout)))
+	if err != nil {
+		return nil, fmt.Errorf("historyStore.runsSince: %v", err)
+	}
+	return runs, nil
+}
+
+// runs returns the runs with rows matching the where clause, oldest first.
+func (s *historyStore) runs(where string) ([]*historyRun, error) {
+	var out bytes.Buffer
+	cmd := s.client(true)
+	cmd.Stdin = strings.NewReader(s.schema() + "SELECT " + storeColumns + " FROM bgp_neighbors\n" + where + "\nORDER BY collected_at;\n")
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", cmd.Path, err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var runs []*historyRun
+	var last string
+	for _, r := range records {
+		if len(r) != 8 {
+			return nil, fmt.Errorf("expecting 8 columns: %v", r)
+		}
+		if r[0] != last {
+			at, err := parseStoreTime(r[0])
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, &historyRun{at: at, table: map[string]*neigh{}})
+			last = r[0]
+		}
+		prefixes, err := strconv.Atoi(r[6])
+		if err != nil {
+			return nil, fmt.Errorf("bad prefixes: %v", r)
+		}
+		n := &neigh{Device: r[1], VRF: r[2], Addr: r[3], RemoteAS: r[4], State: r[5], Prefixes: prefixes}
+		if r[7] != "" {
+			uptime, err := strconv.ParseInt(r[7], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad uptime_seconds: %v", r)
+			}
+			n.UptimeSeconds = &uptime
+		}
+		runs[len(runs)-1].table[neighKey(n)] = n
+	}
+	return runs, nil
+}
+
+// parseStoreTime parses collected_at as printed by sqlite3 (RFC 3339, with
+// microseconds since storeTimeLayout) or psql.
+func parseStoreTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05.999999-07", "2006-01-02 15:04:05.999999-07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad collected_at: [%s]", s)
+}
+
+// storeReports are the canned queries for the query subcommand.
+// %s in a query is replaced by the quoted argument.
+var storeReports = map[string]struct {
+	help  string
+	query string
+	arg   bool
+}{
+	"runs": {
+		help: "collection runs with neighbor and prefix totals",
+		query: `SELECT collected_at, COUNT(*) AS neighbors,
+	SUM(CASE WHEN state = 'Established' THEN 1 ELSE 0 END) AS established,
+	SUM(prefixes) AS prefixes
+FROM bgp_neighbors GROUP BY collected_at ORDER BY collected_at;`,
+	},
+	"flaps": {
+		help: "sessions leaving Established between runs, most flapping first",
+		query: `SELECT device, vrf, addr, COUNT(*) AS flaps, MAX(collected_at) AS last_flap
+FROM (SELECT device, vrf, addr, state, collected_at,
+	LAG(state) OVER (PARTITION BY device, vrf, addr ORDER BY collected_at) AS prev_state
+	FROM bgp_neighbors) t
+WHERE prev_state = 'Established' AND state <> 'Established'
+GROUP BY device, vrf, addr ORDER BY flaps DESC, device, vrf, addr;`,
+	},
+	"growth": {
+		help: "prefix count growth per neighbor from first to last run",
+		query: `SELECT DISTINCT device, vrf, addr, first_prefixes, last_prefixes, last_prefixes - first_prefixes AS growth
+FROM (SELECT device, vrf, addr,
+	FIRST_VALUE(prefixes) OVER w AS first_prefixes,
+	LAST_VALUE(prefixes) OVER w AS last_prefixes
+	FROM bgp_neighbors
+	WINDOW w AS (PARTITION BY device, vrf, addr ORDER BY collected_at ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)) t
+ORDER BY growth DESC, device, vrf, addr;`,
+	},
+	"history": {
+		help: "state and prefixes over time for neighbor address ADDR",
+		query: `SELECT collected_at, device, vrf, addr, state, prefixes, uptime_seconds
+FROM bgp_neighbors WHERE addr = %s ORDER BY device, vrf, collected_at;`,
+		arg: true,
+	},
+}
+
+func storeReportNames() []string {
+	var names []string
+	for name := range storeReports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// storeReportUsage lists the reports with their descriptions.
+func storeReportUsage() string {
+	var lines []string
+	for _, name := range storeReportNames() {
+		lines = append(lines, fmt.Sprintf("  %-8s %s", name, storeReports[name].help))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// report runs a canned query, see storeReports.
+func (s *historyStore) report(w io.Writer, name string, args []string) error {
+	r, ok := storeReports[name]
+	if !ok {
+		return fmt.Errorf("historyStore.report: unknown report: [%s] (available: %s)", name, strings.Join(storeReportNames(), ","))
+	}
+	query := r.query
+	if r.arg {
+		if len(args) != 1 {
+			return fmt.Errorf("historyStore.report: %s requires one argument", name)
+		}
+		query = fmt.Sprintf(query, sqlQuote(args[0]))
+	}
+	if err := s.run(s.schema()+query+"\n", w); err != nil {
+		return fmt.Errorf("historyStore.report: %v", err)
+	}
+	return nil
+}
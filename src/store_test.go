@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStore(t *testing.T) {
+	cases := []struct {
+		spec, driver, dsn string
+	}{
+		{"sqlite:history.db", "sqlite", "history.db"},
+		{"sqlite:/var/lib/bgpn/history.db", "sqlite", "/var/lib/bgpn/history.db"},
+		{"postgres:dbname=bgp host=db1", "postgres", "dbname=bgp host=db1"},
+		{"postgresql:dbname=bgp", "postgres", "dbname=bgp"},
+		{"postgres://monitor@db1/bgp", "postgres", "postgres://monitor@db1/bgp"},
+	}
+	for _, c := range cases {
+		s, err := parseStore(c.spec)
+		if err != nil {
+			t.Errorf("parseStore(%q): %v", c.spec, err)
+			continue
+		}
+		if s.driver != c.driver || s.dsn != c.dsn {
+			t.Errorf("parseStore(%q): got %s %s, want %s %s", c.spec, s.driver, s.dsn, c.driver, c.dsn)
+		}
+	}
+	for _, bad := range []string{"history.db", "mysql:bgp", "sqlite:"} {
+		if _, err := parseStore(bad); err == nil {
+			t.Errorf("parseStore(%q): want error", bad)
+		}
+	}
+}
+
+func TestSQLQuote(t *testing.T) {
+	for s, want := range map[string]string{"CUST-A": "'CUST-A'", "O'Brien": "'O''Brien'", "": "''"} {
+		if got := sqlQuote(s); got != want {
+			t.Errorf("sqlQuote(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+// TestStoreRuns saves runs into a scratch SQLite database, two of them
+// within the same second.
+func TestStoreRuns(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 client not found")
+	}
+	s, err := parseStore("sqlite:" + filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(state string, prefixes int) map[string]*neigh {
+		up := int64(3600)
+		n := &neigh{Device: "pe1", VRF: "CUST-A", Addr: "198.51.100.1", RemoteAS: "65001", State: state, Prefixes: prefixes, UptimeSeconds: &up}
+		o := &neigh{Device: "pe1", VRF: "O'Brien", Addr: "198.51.100.9", RemoteAS: "65009", State: "Idle"}
+		return map[string]*neigh{neighKey(n): n, neighKey(o): o}
+	}
+	base := time.Date(2026, 9, 14, 6, 0, 0, 0, time.UTC)
+	saves := []struct {
+		at       time.Time
+		state    string
+		prefixes int
+	}{
+		{base, "Established", 100},
+		{base.Add(time.Hour), "Established", 120},
+		{base.Add(time.Hour + 400*time.Millisecond), "Active", 0},
+	}
+	for _, r := range saves {
+		if err := s.save(run(r.state, r.prefixes), r.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, err := s.lastRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := last[tableKey("pe1", "198.51.100.1", "CUST-A")]
+	if len(last) != 2 || n == nil || n.State != "Active" || n.Prefixes != 0 {
+		t.Errorf("lastRun: got %d neighbors, %+v", len(last), n)
+	}
+
+	runs, err := s.runsSince(base.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("runsSince: got %d runs, want 2", len(runs))
+	}
+	for i, r := range runs {
+		want := saves[i+1]
+		n := r.table[tableKey("pe1", "198.51.100.1", "CUST-A")]
+		if !r.at.Equal(want.at) || n == nil || n.State != want.state || n.Prefixes != want.prefixes || *n.UptimeSeconds != 3600 {
+			t.Errorf("run %d: at %v, neighbor %+v", i, r.at, n)
+		}
+		if o := r.table[tableKey("pe1", "198.51.100.9", "O'Brien")]; o == nil || o.UptimeSeconds != nil {
+			t.Errorf("run %d: quoted vrf neighbor %+v", i, o)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := s.report(&out, "flaps", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "198.51.100.1") {
+		t.Errorf("flaps report without the flapping neighbor:\n%s", out.String())
+	}
+	if err := s.report(&out, "history", nil); err == nil {
+		t.Error("history without address: want error")
+	}
+}
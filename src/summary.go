@@ -0,0 +1,109 @@
+package main
+
+// parser for cisco summary output, a much cheaper alternative input:
+// show bgp vpnv4 unicast all summary
+// show ip bgp summary
+//
+//Neighbor        V           AS MsgRcvd MsgSent   TblVer  InQ OutQ Up/Down  State/PfxRcd
+//192.0.2.1       4        64512  284883  277641    99871    0    0 27w3d         236
+//198.51.100.5    4        65002       0       0        1    0    0 00:42:17 Idle
+//2001:DB8::1
+//                4        65004       0       0        1    0    0 never    Idle (Admin)
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const sectionSummary = "summary"
+
+func isSummaryHeader(line string) bool {
+	return strings.HasPrefix(line, "Neighbor ") && strings.Contains(line, "State/PfxRcd")
+}
+
+// summaryCommandVRF extracts the vrf from a command echo like:
+// pe1#show bgp vpnv4 unicast vrf CUST-A summary
+func summaryCommandVRF(line string) string {
+	if vrf := splitFields(line).after("vrf"); vrf != "all" {
+		return vrf
+	}
+	return ""
+}
+
+// parseSummaryLine parses one row of the summary table.
+// The summary section ends at the first line that is not a table row.
+// Rows are emitted right away since they carry the whole neighbor.
+func parseSummaryLine(scanner *neighScanner, line string, lineNum int) error {
+	f := strings.Fields(line)
+
+	if len(f) == 0 {
+		if scanner.summaryWrapped != "" {
+			return fmt.Errorf("parseSummaryLine: missing row for wrapped neighbor %s: line=%d", scanner.summaryWrapped, lineNum)
+		}
+		scanner.section = ""
+		return nil
+	}
+
+	if len(f) == 1 && scanner.summaryWrapped == "" && net.ParseIP(strings.TrimPrefix(f[0], "*")) != nil {
+		scanner.summaryWrapped = f[0] // long (IPv6) address, row continues on next line
+		return nil
+	}
+
+	if scanner.summaryWrapped != "" {
+		f = append([]string{scanner.summaryWrapped}, f...)
+		scanner.summaryWrapped = ""
+	}
+
+	if len(f) < 10 || net.ParseIP(strings.TrimPrefix(f[0], "*")) == nil {
+		scanner.section = ""
+		return nil // not a row
+	}
+
+	counters := make([]int, 5) // MsgRcvd MsgSent TblVer InQ OutQ
+	for i := range counters {
+		v, err := strconv.Atoi(f[3+i])
+		if err != nil {
+			return fmt.Errorf("parseSummaryLine: bad counter: line=%d [%s]", lineNum, line)
+		}
+		counters[i] = v
+	}
+
+	n := &neigh{
+		Addr:     strings.TrimPrefix(f[0], "*"),
+		VRF:      scanner.summaryVRF,
+		RemoteAS: f[2],
+		MsgRcvd:  counters[0],
+		MsgSent:  counters[1],
+		TblVer:   counters[2],
+		InQ:      counters[3],
+		OutQ:     counters[4],
+		Dynamic:  strings.HasPrefix(f[0], "*"),
+		summary:  true,
+	}
+	if n.VRF == "" {
+		n.VRF = vrfDefault
+		if scanner.vpnInput {
+			n.VRF = vrfGlobalVPN
+		}
+	}
+
+	n.setUptime(f[8])
+
+	stateOrPfx := strings.Join(f[9:], " ")
+	if count, err := strconv.Atoi(stateOrPfx); err == nil {
+		n.State = "Established"
+		n.Prefixes = count
+		if scanner.summaryAF != "" {
+			n.afPolicy(scanner.summaryAF).Prefixes = count
+		}
+	} else {
+		n.State = f[9]
+		for _, marker := range f[10:] {
+			applyStateMarker(n, marker) // Idle (Admin)
+		}
+	}
+
+	return scanner.emitNeighbor(n)
+}
@@ -0,0 +1,138 @@
+package main
+
+// TCP session details after the BGP part of a neighbor block:
+//
+//	  Transport(tcp) path-mtu-discovery is enabled
+//	Connection state is ESTAB, I/O status: 1, unread input bytes: 0
+//	Connection is ECN Disabled, Mininum incoming TTL 0, Outgoing TTL 255
+//	SRTT: 300 ms, RTTO: 303 ms, RTV: 3 ms, KRTT: 0 ms
+//	Status Flags: passive open, gen tcbs
+//	Option Flags: nagle, path mtu capable, md5
+//	Datagrams (max data segment is 1436 bytes):
+//	Rcvd: 290001 (out of order: 0), with data: 284883, total data bytes: 5432100
+//	Sent: 285002 (retransmit: 0, fastretransmit: 0, partialack: 0, Second Congestion: 0), with data: 277641, total data bytes: 5123400
+//
+// The local and foreign host and port are kept in the neighbor, see local.go.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tcpSession struct {
+	State            string `json:"state,omitempty"`             // ESTAB, CLOSEWAIT...
+	Open             string `json:"open,omitempty"`              // active or passive: who opened the connection
+	MSS              int    `json:"mss,omitempty"`               // max data segment, bytes
+	PathMTUDiscovery bool   `json:"path_mtu_discovery"`          // transport path-mtu-discovery enabled
+	MD5              bool   `json:"md5,omitempty"`               // TCP MD5 authentication option
+	AO               bool   `json:"tcp_ao,omitempty"`            // TCP authentication option (RFC 5925)
+	MinIncomingTTL   int    `json:"min_incoming_ttl,omitempty"`  // ttl-security
+	OutgoingTTL      int    `json:"outgoing_ttl,omitempty"`      // ebgp-multihop
+	SRTT             int    `json:"srtt_ms,omitempty"`           // smoothed round-trip time
+	Rcvd             int64  `json:"rcvd,omitempty"`              // datagrams
+	RcvdBytes        int64  `json:"rcvd_bytes,omitempty"`        // total data bytes
+	RcvdOutOfOrder   int64  `json:"rcvd_out_of_order,omitempty"` // datagrams
+	Sent             int64  `json:"sent,omitempty"`              // datagrams
+	SentBytes        int64  `json:"sent_bytes,omitempty"`        // total data bytes
+	Retransmit       int64  `json:"retransmit,omitempty"`        // datagrams
+	FastRetransmit   int64  `json:"fast_retransmit,omitempty"`   // datagrams
+
+	options bool // option flags seen, see authValue
+}
+
+func (n *neigh) tcpSession() *tcpSession {
+	if n.TCP == nil {
+		n.TCP = &tcpSession{}
+	}
+	return n.TCP
+}
+
+// parseTCPLine records a TCP session line.
+// It returns false when line is not about the TCP session.
+func parseTCPLine(n *neigh, line string) bool {
+	s := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(s, "Transport(tcp) path-mtu-discovery is "):
+		n.tcpSession().PathMTUDiscovery = strings.HasSuffix(s, " enabled")
+	case strings.HasPrefix(line, "Connection state is "):
+		n.tcpSession().State = splitFields(line).word(3)
+	case strings.HasPrefix(line, "Connection is "):
+		tcp := n.tcpSession()
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			fmt.Sscanf(part, "Mininum incoming TTL %d", &tcp.MinIncomingTTL) // sic
+			fmt.Sscanf(part, "Minimum incoming TTL %d", &tcp.MinIncomingTTL)
+			fmt.Sscanf(part, "Outgoing TTL %d", &tcp.OutgoingTTL)
+		}
+	case strings.HasPrefix(line, "SRTT: "):
+		fmt.Sscanf(line, "SRTT: %d ms", &n.tcpSession().SRTT)
+	case strings.HasPrefix(line, "Status Flags: "):
+		for _, flag := range strings.Split(line[len("Status Flags: "):], ",") {
+			switch strings.TrimSpace(flag) {
+			case "active open":
+				n.tcpSession().Open = "active"
+			case "passive open":
+				n.tcpSession().Open = "passive"
+			}
+		}
+	case strings.HasPrefix(line, "Option Flags: "):
+		for _, flag := range strings.Split(line[len("Option Flags: "):], ",") {
+			n.tcpSession().parseOptionFlag(flag)
+		}
+	case strings.HasPrefix(line, "Datagrams (max data segment is "):
+		fmt.Sscanf(line, "Datagrams (max data segment is %d bytes)", &n.tcpSession().MSS)
+	case strings.HasPrefix(line, "Rcvd: "):
+		tcp := n.tcpSession()
+		fmt.Sscanf(line, "Rcvd: %d (out of order: %d)", &tcp.Rcvd, &tcp.RcvdOutOfOrder)
+		tcp.RcvdBytes = tcpCounter(line, "total data bytes: ")
+	case strings.HasPrefix(line, "Sent: "):
+		tcp := n.tcpSession()
+		fmt.Sscanf(line, "Sent: %d (retransmit: %d, fastretransmit: %d", &tcp.Sent, &tcp.Retransmit, &tcp.FastRetransmit)
+		tcp.SentBytes = tcpCounter(line, "total data bytes: ")
+	default:
+		return false
+	}
+	return true
+}
+
+// tcpCounter returns the number following label in line, or 0.
+func tcpCounter(line, label string) int64 {
+	i := strings.Index(line, label)
+	if i < 0 {
+		return 0
+	}
+	var v int64
+	fmt.Sscanf(line[i+len(label):], "%d", &v)
+	return v
+}
+
+// column values, empty when unknown
+
+func tcpState(n *neigh) string {
+	if n.TCP == nil {
+		return ""
+	}
+	return n.TCP.State
+}
+
+func tcpMSS(n *neigh) string {
+	if n.TCP == nil || n.TCP.MSS == 0 {
+		return ""
+	}
+	return strconv.Itoa(n.TCP.MSS)
+}
+
+func tcpPathMTUDiscovery(n *neigh) string {
+	if n.TCP == nil {
+		return ""
+	}
+	return yesNo(n.TCP.PathMTUDiscovery)
+}
+
+func tcpRetransmit(n *neigh) string {
+	if n.TCP == nil || n.TCP.State == "" {
+		return ""
+	}
+	return strconv.FormatInt(n.TCP.Retransmit, 10)
+}
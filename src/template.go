@@ -0,0 +1,98 @@
+package main
+
+// user templates (-template file.tmpl, -template-string) render the
+// neighbor list with Go text/template, e.g. monitoring config stanzas,
+// chat messages or wiki markup:
+//
+//	{{range .Neighbors}}{{.Device}} {{.Addr}} {{.VRF}} {{.State}}
+//	{{end}}
+//
+// Neighbor fields use the Go names of the JSON fields (Addr, VRF,
+// RemoteAS, Prefixes, Timers.HoldTime...). Functions: column NAME N
+// (a -columns value, e.g. uptime), json V, join LIST SEP, lower, upper,
+// pad WIDTH S, and established N.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is the dot of a user template.
+type templateData struct {
+	Neighbors   []*neigh
+	Total       int
+	Established int
+	Generated   time.Time
+	VRFs        []*groupStat
+	ASNs        []*groupStat
+}
+
+var templateFuncs = template.FuncMap{
+	"column": func(name string, n *neigh) (string, error) {
+		c := findColumn(name)
+		if c == nil {
+			return "", fmt.Errorf("unknown column: [%s] (available: %s)", name, strings.Join(columnNames(), ","))
+		}
+		return c.value(n), nil
+	},
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"join":        strings.Join,
+	"lower":       strings.ToLower,
+	"upper":       strings.ToUpper,
+	"pad":         func(width int, s string) string { return fmt.Sprintf("%-*s", width, s) },
+	"established": func(n *neigh) bool { return n.State == "Established" },
+}
+
+// loadTemplate parses the -template file or the -template-string text.
+// It returns nil when neither is given.
+func loadTemplate(path, text string) (*template.Template, error) {
+	switch {
+	case path != "" && text != "":
+		return nil, fmt.Errorf("loadTemplate: use either -template or -template-string")
+	case path != "":
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("loadTemplate: %v", err)
+		}
+		t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(buf))
+		if err != nil {
+			return nil, fmt.Errorf("loadTemplate: %v", err)
+		}
+		return t, nil
+	case text != "":
+		t, err := template.New("template-string").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("loadTemplate: %v", err)
+		}
+		return t, nil
+	}
+	return nil, nil
+}
+
+func writeTemplate(w io.Writer, t *template.Template, list []*neigh, now time.Time) error {
+	data := templateData{
+		Neighbors: list,
+		Total:     len(list),
+		Generated: now,
+		VRFs:      vrfStats(list),
+		ASNs:      asnStats(list),
+	}
+	for _, n := range list {
+		if n.State == "Established" {
+			data.Established++
+		}
+	}
+	if err := t.Execute(w, data); err != nil {
+		return fmt.Errorf("writeTemplate: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package main
+
+// interactive collection for routers that refuse scripted exec channels:
+// the show command is typed into a login shell over telnet or "ssh -tt" by
+// a driver that answers the login and enable prompts, pages through --More--
+// and knows the output ended when the device prompt comes back. Output is
+// parsed as it arrives, and the session is kept open between collections
+// (-watch, serve), reconnecting after errors.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	terminalPasswordEnv = "BGPN_TERMINAL_PASSWORD"
+	enablePasswordEnv   = "BGPN_ENABLE_PASSWORD"
+)
+
+// terminalPrompt matches a device prompt, e.g. "pe1#" or "pe1>".
+var terminalPrompt = regexp.MustCompile(`^[A-Za-z0-9][\w.\-@/:()]*[>#]$`)
+
+type termChunk struct {
+	data []byte
+	err  error
+}
+
+type termSession struct {
+	transport      string // telnet or ssh
+	host           string
+	port           int
+	user           string
+	password       string
+	enablePassword string
+	command        string
+	timeout        time.Duration // waiting for output
+
+	conn   io.ReadWriteCloser
+	eol    string
+	chunks chan termChunk
+	buf    []byte // output not consumed yet
+	prompt string // learned at login, e.g. "pe1#"
+}
+
+// parseTerminalURL parses telnet://[user@]host[:port] or ssh://[user@]host[:port].
+// Passwords come from the environment, not the URL, to keep them out of
+// shell history.
+func parseTerminalURL(spec string) (*termSession, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parseTerminalURL: %v", err)
+	}
+	if (u.Scheme != "telnet" && u.Scheme != "ssh") || u.Hostname() == "" {
+		return nil, fmt.Errorf("parseTerminalURL: expecting telnet://host[:port] or ssh://[user@]host[:port]: [%s]", spec)
+	}
+	if _, found := u.User.Password(); found {
+		return nil, fmt.Errorf("parseTerminalURL: password in URL: use %s", terminalPasswordEnv)
+	}
+	t := &termSession{
+		transport:      u.Scheme,
+		host:           u.Hostname(),
+		user:           u.User.Username(),
+		password:       os.Getenv(terminalPasswordEnv),
+		enablePassword: os.Getenv(enablePasswordEnv),
+	}
+	if u.Port() != "" {
+		if t.port, err = strconv.Atoi(u.Port()); err != nil {
+			return nil, fmt.Errorf("parseTerminalURL: bad port: [%s]", spec)
+		}
+	}
+	return t, nil
+}
+
+func (t *termSession) String() string {
+	return t.transport + "://" + t.host
+}
+
+// collect runs the command, connecting and logging in first if needed.
+func (t *termSession) collect(device string, opts parseOptions) (map[string]*neigh, error) {
+	if t.conn == nil {
+		if err := t.connect(); err != nil {
+			return nil, fmt.Errorf("termSession.collect: %s: %v", t, err)
+		}
+	}
+
+	opts.device = device
+	pr, pw := io.Pipe()
+	var table map[string]*neigh
+	var parseErr error
+	done := make(chan struct{})
+	go func() {
+		table, _, parseErr = parseInput(pr, t.String(), opts)
+		io.Copy(io.Discard, pr)
+		close(done)
+	}()
+
+	err := t.run(t.command, pw)
+	pw.Close()
+	<-done
+	if err != nil {
+		t.close() // reconnect on next collection
+		return nil, fmt.Errorf("termSession.collect: %s: %v", t, err)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("termSession.collect: %v", parseErr)
+	}
+	return table, nil
+}
+
+func (t *termSession) connect() error {
+	var err error
+	switch t.transport {
+	case "telnet":
+		port := t.port
+		if port == 0 {
+			port = 23
+		}
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(t.host, strconv.Itoa(port)), t.timeout)
+		t.conn, t.eol = &telnetConn{Conn: conn}, "\r"
+	case "ssh":
+		t.conn, err = t.dialSSH()
+		t.eol = "\n"
+	}
+	if err != nil {
+		return err
+	}
+
+	t.buf, t.prompt = nil, ""
+	t.chunks = make(chan termChunk, 16)
+	go func(conn io.Reader, chunks chan<- termChunk) {
+		for {
+			b := make([]byte, 32*1024)
+			n, err := conn.Read(b)
+			if n > 0 {
+				chunks <- termChunk{data: b[:n]}
+			}
+			if err != nil {
+				chunks <- termChunk{err: err}
+				close(chunks)
+				return
+			}
+		}
+	}(t.conn, t.chunks)
+
+	if err := t.login(); err != nil {
+		t.close()
+		return err
+	}
+	infof("termSession.connect: %s: logged in, prompt %s", t, t.prompt)
+	return nil
+}
+
+// dialSSH starts an interactive shell (not an exec channel) with a forced
+// pseudo-terminal. A password is passed through SSH_ASKPASS, see askpass.
+func (t *termSession) dialSSH() (io.ReadWriteCloser, error) {
+	args := []string{"-tt"}
+	if t.port != 0 {
+		args = append(args, "-p", strconv.Itoa(t.port))
+	}
+	if t.user != "" {
+		args = append(args, "-l", t.user)
+	}
+	if t.password == "" {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	cmd := exec.Command("ssh", append(args, t.host)...)
+	cmd.Stderr = os.Stderr
+	if t.password != "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS="+exe, "SSH_ASKPASS_REQUIRE=force", askpassEnv+"="+t.password)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &sshShell{Reader: stdout, WriteCloser: stdin, cmd: cmd}, nil
+}
+
+func (t *termSession) close() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+func (t *termSession) send(s string) error {
+	if _, err := io.WriteString(t.conn, s); err != nil {
+		return fmt.Errorf("send: %v", err)
+	}
+	return nil
+}
+
+// read waits for more output.
+func (t *termSession) read(waiting string) error {
+	select {
+	case c, ok := <-t.chunks:
+		if !ok || c.err != nil {
+			return fmt.Errorf("connection closed waiting for %s: %v", waiting, c.err)
+		}
+		t.buf = append(t.buf, c.data...)
+		return nil
+	case <-time.After(t.timeout):
+		return fmt.Errorf("timeout waiting for %s", waiting)
+	}
+}
+
+// partial returns the last incomplete output line, as the terminal shows it.
+func (t *termSession) partial() string {
+	return strings.TrimSpace(cleanLine(string(t.lastLine())))
+}
+
+func (t *termSession) lastLine() []byte {
+	return t.buf[bytes.LastIndexByte(t.buf, '\n')+1:]
+}
+
+// login answers the username, password and enable prompts until the
+// privileged prompt shows up, then disables paging.
+func (t *termSession) login() error {
+	var sentUser, sentPassword, nudged bool
+	for t.prompt == "" {
+		p := t.partial()
+		lower := strings.ToLower(p)
+		switch {
+		case strings.HasSuffix(lower, "username:") || strings.HasSuffix(lower, "login:"):
+			if t.user == "" {
+				return fmt.Errorf("login: username required")
+			}
+			if sentUser {
+				return fmt.Errorf("login: rejected")
+			}
+			sentUser = true
+			t.buf = nil
+			if err := t.send(t.user + t.eol); err != nil {
+				return err
+			}
+		case strings.HasSuffix(lower, "password:"):
+			if sentPassword || t.password == "" {
+				return fmt.Errorf("login: password rejected or missing (%s)", terminalPasswordEnv)
+			}
+			sentPassword = true
+			t.buf = nil
+			if err := t.send(t.password + t.eol); err != nil {
+				return err
+			}
+		case terminalPrompt.MatchString(p):
+			t.prompt = p
+			t.buf = nil
+		default:
+			err := t.read("login prompt")
+			if err != nil && !nudged && strings.HasPrefix(err.Error(), "timeout") {
+				nudged = true // some servers print the prompt only after a key press
+				err = t.send(t.eol)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if strings.HasSuffix(t.prompt, ">") && t.enablePassword != "" {
+		if err := t.enable(); err != nil {
+			return err
+		}
+	}
+
+	return t.run("terminal length 0", io.Discard)
+}
+
+func (t *termSession) enable() error {
+	if err := t.send("enable" + t.eol); err != nil {
+		return err
+	}
+	for !strings.HasSuffix(strings.ToLower(t.partial()), "password:") {
+		if err := t.read("enable password prompt"); err != nil {
+			return fmt.Errorf("enable: %v", err)
+		}
+	}
+	t.buf = nil
+	if err := t.send(t.enablePassword + t.eol); err != nil {
+		return err
+	}
+	for {
+		p := t.partial()
+		switch {
+		case strings.HasSuffix(strings.ToLower(p), "password:"):
+			return fmt.Errorf("enable: password rejected")
+		case terminalPrompt.MatchString(p):
+			if !strings.HasSuffix(p, "#") {
+				return fmt.Errorf("enable: still unprivileged: %s", p)
+			}
+			t.prompt = p
+			t.buf = nil
+			return nil
+		}
+		if err := t.read("enable prompt"); err != nil {
+			return fmt.Errorf("enable: %v", err)
+		}
+	}
+}
+
+// run types command and copies its output to w line by line until the
+// prompt returns, paging through --More-- if paging is still on.
+// The prompt is written first, so the capture names the device.
+func (t *termSession) run(command string, w io.Writer) error {
+	if _, err := io.WriteString(w, t.prompt); err != nil {
+		return err
+	}
+	if err := t.send(command + t.eol); err != nil {
+		return err
+	}
+	for {
+		if i := bytes.LastIndexByte(t.buf, '\n'); i >= 0 {
+			if _, err := w.Write(t.buf[:i+1]); err != nil {
+				return err
+			}
+			t.buf = append(t.buf[:0], t.buf[i+1:]...)
+		}
+		switch {
+		case t.partial() == t.prompt:
+			t.buf = nil
+			return nil
+		case moreMarker.Match(t.lastLine()):
+			t.buf = moreMarker.ReplaceAll(t.buf, nil)
+			if err := t.send(" "); err != nil {
+				return err
+			}
+		}
+		if err := t.read("output of " + command); err != nil {
+			return err
+		}
+	}
+}
+
+// sshShell is the interactive ssh client process.
+type sshShell struct {
+	io.Reader
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (s *sshShell) Close() error {
+	s.WriteCloser.Close()
+	s.cmd.Process.Kill()
+	return s.cmd.Wait()
+}
+
+// telnet protocol bytes
+const (
+	telnetIAC  = 255
+	telnetDont = 254
+	telnetDo   = 253
+	telnetWont = 252
+	telnetWill = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetEcho = 1
+	telnetSGA  = 3 // suppress go ahead
+)
+
+// telnetConn strips telnet commands from the data read, refusing every
+// option but the server echoing and suppressing go-ahead.
+type telnetConn struct {
+	net.Conn
+	state int // 0 data, 1 after IAC, 2 after IAC DO/DONT/WILL/WONT, 3 in subnegotiation, 4 IAC in subnegotiation
+	verb  byte
+}
+
+func (c *telnetConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(b)
+		if n == 0 {
+			return 0, err
+		}
+		data, reply := c.filter(b[:n])
+		if len(reply) > 0 {
+			if _, werr := c.Conn.Write(reply); werr != nil {
+				return 0, werr
+			}
+		}
+		if len(data) > 0 || err != nil {
+			return copy(b, data), err
+		}
+	}
+}
+
+// filter returns the data bytes and the option replies.
+func (c *telnetConn) filter(in []byte) (data, reply []byte) {
+	data = in[:0] // filtered in place
+	for _, x := range in {
+		switch c.state {
+		case 0:
+			if x == telnetIAC {
+				c.state = 1
+				continue
+			}
+			data = append(data, x)
+		case 1:
+			switch x {
+			case telnetIAC:
+				data = append(data, x) // escaped 255
+				c.state = 0
+			case telnetDo, telnetDont, telnetWill, telnetWont:
+				c.verb = x
+				c.state = 2
+			case telnetSB:
+				c.state = 3
+			default:
+				c.state = 0
+			}
+		case 2:
+			switch {
+			case c.verb == telnetWill && (x == telnetEcho || x == telnetSGA):
+				reply = append(reply, telnetIAC, telnetDo, x)
+			case c.verb == telnetWill:
+				reply = append(reply, telnetIAC, telnetDont, x)
+			case c.verb == telnetDo && x == telnetSGA:
+				reply = append(reply, telnetIAC, telnetWill, x)
+			case c.verb == telnetDo:
+				reply = append(reply, telnetIAC, telnetWont, x)
+			}
+			c.state = 0
+		case 3:
+			if x == telnetIAC {
+				c.state = 4
+			}
+		case 4:
+			c.state = 3
+			if x == telnetSE {
+				c.state = 0
+			}
+		}
+	}
+	return data, reply
+}
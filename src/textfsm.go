@@ -0,0 +1,151 @@
+package main
+
+// input adapter for JSON produced by ntc-templates/TextFSM (e.g. netmiko
+// use_textfsm=True) for show ip bgp neighbors and show ip bgp summary:
+// a list of records with lowercase or uppercase template value names.
+// Field names changed between template versions, so several aliases are accepted.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// textfsmFields maps template value names to neighbor fields.
+var textfsmFields = []struct {
+	names []string
+	set   func(n *neigh, v string) error
+}{
+	{[]string{"vrf"}, func(n *neigh, v string) error { n.VRF = v; return nil }},
+	{[]string{"remote_as", "neigh_as", "neighbor_as", "remote_asn"}, func(n *neigh, v string) error { n.RemoteAS = v; return nil }},
+	{[]string{"bgp_state", "state"}, func(n *neigh, v string) error { n.State = strings.TrimSuffix(v, ","); return nil }},
+	{[]string{"state_pfxrcd", "state_or_prefixes_received", "state_pfx"}, textfsmStateOrPrefixes},
+	{[]string{"uptime", "up_down", "up_time"}, func(n *neigh, v string) error { n.setUptime(v); return nil }},
+	{[]string{"prefixes_current", "prefixes_received", "accepted_prefixes"}, func(n *neigh, v string) error { return textfsmInt(&n.Prefixes, v) }},
+	{[]string{"description", "neighbor_description"}, func(n *neigh, v string) error { n.Description = v; return nil }},
+	{[]string{"peer_group", "peer_group_name", "template"}, func(n *neigh, v string) error { n.PeerGroup = v; return nil }},
+	{[]string{"remote_router_id", "remote_id"}, func(n *neigh, v string) error { n.RouterID = v; return nil }},
+	{[]string{"router_id", "local_router_id"}, func(n *neigh, v string) error { n.LocalRouterID = v; return nil }}, // summary: local identifier
+	{[]string{"local_as"}, func(n *neigh, v string) error { n.LocalAS = v; return nil }},
+	{[]string{"localhost_ip", "local_host"}, func(n *neigh, v string) error { n.LocalHost = v; return nil }},
+	{[]string{"localhost_port", "local_port"}, func(n *neigh, v string) error { return textfsmInt(&n.LocalPort, v) }},
+	{[]string{"remotehost_ip", "remote_host", "foreign_host"}, func(n *neigh, v string) error { n.ForeignHost = v; return nil }},
+	{[]string{"remotehost_port", "remote_port", "foreign_port"}, func(n *neigh, v string) error { return textfsmInt(&n.ForeignPort, v) }},
+	{[]string{"msg_rcvd"}, func(n *neigh, v string) error { return textfsmInt(&n.MsgRcvd, v) }},
+	{[]string{"msg_sent"}, func(n *neigh, v string) error { return textfsmInt(&n.MsgSent, v) }},
+	{[]string{"in_q", "inq"}, func(n *neigh, v string) error { return textfsmInt(&n.InQ, v) }},
+	{[]string{"out_q", "outq"}, func(n *neigh, v string) error { return textfsmInt(&n.OutQ, v) }},
+	{[]string{"last_reset"}, func(n *neigh, v string) error { n.LastReset = v; return nil }},
+	{[]string{"last_reset_reason", "reset_reason"}, func(n *neigh, v string) error { n.ResetReason = v; return nil }},
+}
+
+var textfsmAddrNames = []string{"neighbor", "remote_ip", "bgp_neigh", "neighbor_ip", "peer"}
+
+func textfsmInt(dst *int, v string) error {
+	if v == "" {
+		return nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("textfsmInt: bad number: [%s]", v)
+	}
+	*dst = i
+	return nil
+}
+
+// textfsmStateOrPrefixes handles the summary State/PfxRcd column:
+// a number means Established with that many prefixes.
+func textfsmStateOrPrefixes(n *neigh, v string) error {
+	if count, err := strconv.Atoi(v); err == nil {
+		n.State = "Established"
+		n.Prefixes = count
+		return nil
+	}
+	n.State = v
+	return nil
+}
+
+// textfsmValue renders a record value as string. List values are joined by commas.
+func textfsmValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(x)
+	case []interface{}:
+		var parts []string
+		for _, e := range x {
+			parts = append(parts, textfsmValue(e))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// scanTextFSM reads a JSON list of TextFSM records from r and emits one neighbor per record.
+func (scanner *neighScanner) scanTextFSM(r io.Reader) error {
+	var records []map[string]interface{}
+	if err := json.NewDecoder(decodeInput(bufio.NewReader(r))).Decode(&records); err != nil {
+		return fmt.Errorf("scanTextFSM: %v", err)
+	}
+
+	for i, rec := range records {
+		scanner.lines++
+
+		fields := map[string]string{}
+		for k, v := range rec {
+			fields[strings.ToLower(k)] = textfsmValue(v)
+		}
+
+		err := scanner.textfsmRecord(fields)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("scanTextFSM: record %d: %v", i, err)
+		if scanner.opts.strict || scanner.emitErr != nil {
+			return err
+		}
+		scanner.curr = nil
+		scanner.errors = append(scanner.errors, err)
+	}
+
+	return nil
+}
+
+// textfsmRecord converts one record and emits it.
+func (scanner *neighScanner) textfsmRecord(fields map[string]string) error {
+	var addr string
+	for _, name := range textfsmAddrNames {
+		if v := fields[name]; v != "" {
+			addr = v
+			break
+		}
+	}
+	if addr == "" {
+		return fmt.Errorf("textfsmRecord: missing neighbor address (%s)", strings.Join(textfsmAddrNames, ","))
+	}
+
+	if err := scanner.startNeighbor(addr); err != nil {
+		return err
+	}
+
+	scanner.curr.setUptime("?")
+
+	for _, f := range textfsmFields {
+		for _, name := range f.names {
+			v, found := fields[name]
+			if !found || v == "" {
+				continue
+			}
+			if err := f.set(scanner.curr, v); err != nil {
+				return fmt.Errorf("textfsmRecord: %s: %v", name, err)
+			}
+			break
+		}
+	}
+
+	return scanner.flush()
+}
@@ -0,0 +1,149 @@
+package main
+
+// BGP session timers: the hold time and keepalive in use (negotiated as
+// the lower of both ends' hold times), the per-neighbor configured
+// override and the minimum hold time accepted from the neighbor.
+//
+//  Last read 00:00:12, last write 00:00:31, hold time is 90, keepalive interval is 30 seconds
+//  Configured hold time is 90,keepalive interval is 30 seconds
+//  Minimum holdtime from neighbor is 0 seconds
+//
+// The timers report lists the sessions whose timers in use deviate from a
+// standard, e.g. -timers 180/60.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type bgpTimers struct {
+	HoldTime            int `json:"hold_time"` // seconds, in use
+	Keepalive           int `json:"keepalive"` // seconds, in use
+	ConfiguredHoldTime  int `json:"configured_hold_time,omitempty"`
+	ConfiguredKeepalive int `json:"configured_keepalive,omitempty"`
+	MinHoldTime         int `json:"min_hold_time,omitempty"` // minimum accepted from the neighbor
+
+	configured bool // override line seen, its timers may be zero
+}
+
+var timersPattern = regexp.MustCompile(`(?i)hold ?time is (\d+), ?keepalive interval is (\d+) seconds`)
+
+func (n *neigh) timers() *bgpTimers {
+	if n.Timers == nil {
+		n.Timers = &bgpTimers{}
+	}
+	return n.Timers
+}
+
+// parseTimersLine records a timers line.
+// It returns false when line is not about timers.
+func parseTimersLine(n *neigh, line string) bool {
+	s := strings.TrimSpace(line)
+	if strings.HasPrefix(s, "Minimum holdtime from neighbor is ") {
+		var secs int
+		if _, err := fmt.Sscanf(s, "Minimum holdtime from neighbor is %d seconds", &secs); err == nil {
+			n.timers().MinHoldTime = secs
+			return true
+		}
+		return false
+	}
+	if !strings.Contains(s, "keepalive interval is ") {
+		return false // skip the regexp on unrelated lines
+	}
+	m := timersPattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	hold, _ := strconv.Atoi(m[1])
+	keepalive, _ := strconv.Atoi(m[2])
+	t := n.timers()
+	if strings.HasPrefix(s, "Configured ") {
+		t.ConfiguredHoldTime, t.ConfiguredKeepalive, t.configured = hold, keepalive, true
+		return true
+	}
+	t.HoldTime, t.Keepalive = hold, keepalive
+	return true
+}
+
+// parseTimersStandard parses "hold/keepalive", e.g. 180/60.
+func parseTimersStandard(s string) (hold, keepalive int, err error) {
+	if _, err := fmt.Sscanf(s, "%d/%d", &hold, &keepalive); err != nil || hold < 0 || keepalive < 0 || fmt.Sprintf("%d/%d", hold, keepalive) != s {
+		return 0, 0, fmt.Errorf("parseTimersStandard: expecting hold/keepalive seconds, e.g. 180/60: [%s]", s)
+	}
+	return hold, keepalive, nil
+}
+
+type timersDeviation struct {
+	Device              string `json:"device,omitempty"`
+	Addr                string `json:"addr"`
+	VRF                 string `json:"vrf"`
+	State               string `json:"state"`
+	HoldTime            int    `json:"hold_time"`
+	Keepalive           int    `json:"keepalive"`
+	ConfiguredHoldTime  int    `json:"configured_hold_time,omitempty"`
+	ConfiguredKeepalive int    `json:"configured_keepalive,omitempty"`
+	Source              string `json:"source"` // configured (neighbor override) or negotiated (router timers or the peer's lower hold time)
+}
+
+// timersDeviations returns the neighbors whose timers in use differ from
+// the standard. Neighbors without timers (summary output) are skipped.
+func timersDeviations(list []*neigh, hold, keepalive int) []*timersDeviation {
+	var rows []*timersDeviation
+	for _, n := range list {
+		t := n.Timers
+		if t == nil || (t.HoldTime == hold && t.Keepalive == keepalive) {
+			continue
+		}
+		source := "negotiated"
+		if t.configured && (t.ConfiguredHoldTime != hold || t.ConfiguredKeepalive != keepalive) {
+			source = "configured"
+		}
+		rows = append(rows, &timersDeviation{
+			Device:              n.Device,
+			Addr:                n.Addr,
+			VRF:                 n.VRF,
+			State:               n.State,
+			HoldTime:            t.HoldTime,
+			Keepalive:           t.Keepalive,
+			ConfiguredHoldTime:  t.ConfiguredHoldTime,
+			ConfiguredKeepalive: t.ConfiguredKeepalive,
+			Source:              source,
+		})
+	}
+	return rows
+}
+
+func writeTimersDeviations(w io.Writer, rows []*timersDeviation, hold, keepalive int) {
+	fmt.Fprintf(w, "%d neighbors with timers other than %d/%d\n", len(rows), hold, keepalive)
+	fmt.Fprintf(w, "%-12s %-15s %-14s %-12s %6s %9s %10s %s\n", "Device", "Neighbor", "VRF", "State", "Hold", "Keepalive", "Configured", "Source")
+	for _, r := range rows {
+		configured := ""
+		if r.Source == "configured" {
+			configured = fmt.Sprintf("%d/%d", r.ConfiguredHoldTime, r.ConfiguredKeepalive)
+		}
+		fmt.Fprintf(w, "%-12s %-15s %-14s %-12s %6d %9d %10s %s\n", r.Device, r.Addr, r.VRF, r.State, r.HoldTime, r.Keepalive, configured, r.Source)
+	}
+}
+
+func writeTimersDeviationsJSON(w io.Writer, rows []*timersDeviation) error {
+	if rows == nil {
+		rows = []*timersDeviation{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		return fmt.Errorf("writeTimersDeviationsJSON: %v", err)
+	}
+	return nil
+}
+
+func timersValue(n *neigh, get func(t *bgpTimers) int) string {
+	if n.Timers == nil {
+		return ""
+	}
+	return strconv.Itoa(get(n.Timers))
+}
@@ -0,0 +1,140 @@
+package main
+
+// top talkers: the neighbors with the most prefixes and, against a previous
+// run (JSON table or history store), the largest prefix count changes.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type topEntry struct {
+	Device   string `json:"device,omitempty"`
+	Addr     string `json:"addr"`
+	VRF      string `json:"vrf"`
+	RemoteAS string `json:"remote_as"`
+	State    string `json:"state"`
+	Prefixes int    `json:"prefixes"`
+	Previous *int   `json:"previous,omitempty"` // unset for neighbors missing from the previous run
+	Delta    *int   `json:"delta,omitempty"`    // unset without a previous run
+}
+
+type topReport struct {
+	Top       []*topEntry `json:"top"`
+	Increases []*topEntry `json:"increases,omitempty"`
+	Decreases []*topEntry `json:"decreases,omitempty"`
+
+	compared bool // against a previous run
+}
+
+// loadPrevious loads the run to compare against: a history store spec
+// (sqlite:path, postgres:connstring) or a JSON table file.
+func loadPrevious(spec string) (map[string]*neigh, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var prev map[string]*neigh
+	var err error
+	if strings.HasPrefix(spec, "sqlite:") || strings.HasPrefix(spec, "postgres") {
+		var s *historyStore
+		if s, err = parseStore(spec); err == nil {
+			prev, err = s.lastRun()
+		}
+	} else {
+		prev, err = loadTableJSON(spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadPrevious: %v", err)
+	}
+	if prev == nil {
+		warnf("loadPrevious: %s: no previous run, reporting without deltas", spec)
+	}
+	return prev, nil
+}
+
+// newTopReport ranks list by prefix count. Neighbors missing from prev
+// count as increases from zero; neighbors gone since prev are not reported.
+func newTopReport(list []*neigh, prev map[string]*neigh, n int) *topReport {
+	entries := make([]*topEntry, 0, len(list))
+	for _, nb := range list {
+		e := &topEntry{Device: nb.Device, Addr: nb.Addr, VRF: nb.VRF, RemoteAS: nb.RemoteAS, State: nb.State, Prefixes: nb.Prefixes}
+		if prev != nil {
+			delta := nb.Prefixes
+			if p, found := prev[neighKey(nb)]; found {
+				previous := p.Prefixes
+				e.Previous = &previous
+				delta -= previous
+			}
+			e.Delta = &delta
+		}
+		entries = append(entries, e)
+	}
+
+	r := &topReport{Top: topBy(entries, n, false, func(e *topEntry) int { return e.Prefixes }), compared: prev != nil}
+	if r.compared {
+		r.Increases = topBy(entries, n, true, func(e *topEntry) int { return *e.Delta })
+		r.Decreases = topBy(entries, n, true, func(e *topEntry) int { return -*e.Delta })
+	}
+	return r
+}
+
+// topBy returns up to n entries with the highest scores.
+func topBy(entries []*topEntry, n int, positiveOnly bool, score func(e *topEntry) int) []*topEntry {
+	sorted := append([]*topEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return score(sorted[i]) > score(sorted[j]) })
+	var out []*topEntry
+	for _, e := range sorted {
+		if len(out) == n || (positiveOnly && score(e) <= 0) {
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func writeTop(w io.Writer, r *topReport) {
+	fmt.Fprintf(w, "Top %d neighbors by prefixes\n", len(r.Top))
+	writeTopEntries(w, r.Top, r.compared)
+	if !r.compared {
+		return
+	}
+	fmt.Fprintf(w, "\nLargest increases\n")
+	writeTopEntries(w, r.Increases, true)
+	fmt.Fprintf(w, "\nLargest decreases\n")
+	writeTopEntries(w, r.Decreases, true)
+}
+
+func writeTopEntries(w io.Writer, entries []*topEntry, deltas bool) {
+	fmt.Fprintf(w, "%-12s %-15s %-14s %-10s %-12s %9s", "Device", "Neighbor", "VRF", "ASN", "State", "Prefixes")
+	if deltas {
+		fmt.Fprintf(w, " %9s %8s", "Previous", "Delta")
+	}
+	fmt.Fprintln(w)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%-12s %-15s %-14s %-10s %-12s %9d", e.Device, e.Addr, e.VRF, e.RemoteAS, e.State, e.Prefixes)
+		if deltas {
+			previous := "new"
+			if e.Previous != nil {
+				previous = strconv.Itoa(*e.Previous)
+			}
+			fmt.Fprintf(w, " %9s %+8d", previous, *e.Delta)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeTopJSON(w io.Writer, r *topReport) error {
+	if r.Top == nil {
+		r.Top = []*topEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("writeTopJSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+// prefix count trend over the runs recorded in the history store (or a
+// directory of JSON tables), per neighbor, VRF or device, for capacity
+// planning of route reflector memory:
+//
+//	report trend -since 30d -by vrf -store sqlite:history.db
+//	collected_at,device,vrf,prefixes
+//	2026-09-14T06:00:00Z,rr1,CUST-A,1204
+//
+// With -stats, one line per series instead: first, last, min and max
+// counts, growth, and the growth per day of a least squares fit.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type trendPoint struct {
+	At       time.Time `json:"at"`
+	Prefixes int       `json:"prefixes"`
+}
+
+// trendSeries is the prefix count of one neighbor, VRF or device over the runs.
+type trendSeries struct {
+	Device    string       `json:"device,omitempty"`
+	VRF       string       `json:"vrf,omitempty"`
+	Addr      string       `json:"addr,omitempty"`
+	Points    []trendPoint `json:"points,omitempty"`
+	Samples   int          `json:"samples"`
+	First     int          `json:"first"`
+	Last      int          `json:"last"`
+	Min       int          `json:"min"`
+	Max       int          `json:"max"`
+	Growth    int          `json:"growth"`
+	GrowthPct *float64     `json:"growth_pct"` // nil when the first count is 0
+	PerDay    float64      `json:"per_day"`
+}
+
+// trendGroupings are the -by values, with the key columns of each.
+var trendGroupings = map[string][]string{
+	"neighbor": {"device", "vrf", "addr"},
+	"vrf":      {"device", "vrf"},
+	"device":   {"device"},
+}
+
+// newTrend returns the series of runs grouped by neighbor, vrf or device,
+// sorted by device, vrf and addr. Neighbors count in every run they
+// appear in, whatever their state.
+func newTrend(runs []*historyRun, by string) ([]*trendSeries, error) {
+	if _, ok := trendGroupings[by]; !ok {
+		return nil, fmt.Errorf("newTrend: bad grouping: [%s] (expecting neighbor, vrf or device)", by)
+	}
+	series := map[string]*trendSeries{}
+	for _, r := range runs {
+		sums := map[string]int{}
+		for _, n := range r.table {
+			s := &trendSeries{Device: n.Device, VRF: n.VRF, Addr: n.Addr}
+			switch by {
+			case "vrf":
+				s.Addr = ""
+			case "device":
+				s.VRF, s.Addr = "", ""
+			}
+			k := tableKey(s.Device, s.Addr, s.VRF)
+			if _, found := series[k]; !found {
+				series[k] = s
+			}
+			sums[k] += n.Prefixes
+		}
+		for k, sum := range sums {
+			s := series[k]
+			s.Points = append(s.Points, trendPoint{At: r.at, Prefixes: sum})
+		}
+	}
+
+	list := make([]*trendSeries, 0, len(series))
+	for _, s := range series {
+		s.stats()
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Device != b.Device {
+			return a.Device < b.Device
+		}
+		if a.VRF != b.VRF {
+			return a.VRF < b.VRF
+		}
+		return a.Addr < b.Addr
+	})
+	return list, nil
+}
+
+// stats computes the growth statistics from the points, oldest first.
+func (s *trendSeries) stats() {
+	p := s.Points
+	s.Samples = len(p)
+	if len(p) == 0 {
+		return
+	}
+	s.First, s.Last = p[0].Prefixes, p[len(p)-1].Prefixes
+	s.Min, s.Max = s.First, s.First
+	for _, pt := range p {
+		if pt.Prefixes < s.Min {
+			s.Min = pt.Prefixes
+		}
+		if pt.Prefixes > s.Max {
+			s.Max = pt.Prefixes
+		}
+	}
+	s.Growth = s.Last - s.First
+	if s.First > 0 {
+		pct := 100 * float64(s.Growth) / float64(s.First)
+		s.GrowthPct = &pct
+	}
+	s.PerDay = trendSlope(p) * 24
+}
+
+// trendSlope returns the least squares slope of the points, in prefixes
+// per hour; 0 when all points are at the same time.
+func trendSlope(p []trendPoint) float64 {
+	var sx, sy, sxx, sxy float64
+	t0 := p[0].At
+	for _, pt := range p {
+		x := pt.At.Sub(t0).Hours()
+		y := float64(pt.Prefixes)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(p))
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
+
+// keyValues returns the key columns of s for grouping by.
+func (s *trendSeries) keyValues(by string) []string {
+	values := map[string]string{"device": s.Device, "vrf": s.VRF, "addr": s.Addr}
+	var v []string
+	for _, c := range trendGroupings[by] {
+		v = append(v, values[c])
+	}
+	return v
+}
+
+// writeTrendCSV writes the points of every series, or with stats one
+// record per series.
+func writeTrendCSV(w io.Writer, list []*trendSeries, by string, stats bool) error {
+	cw := csv.NewWriter(w)
+	keys := trendGroupings[by]
+	if stats {
+		cw.Write(append(keys, "samples", "first", "last", "min", "max", "growth", "growth_pct", "per_day"))
+		for _, s := range list {
+			pct := ""
+			if s.GrowthPct != nil {
+				pct = strconv.FormatFloat(*s.GrowthPct, 'f', 1, 64)
+			}
+			cw.Write(append(s.keyValues(by), strconv.Itoa(s.Samples), strconv.Itoa(s.First), strconv.Itoa(s.Last),
+				strconv.Itoa(s.Min), strconv.Itoa(s.Max), strconv.Itoa(s.Growth), pct, strconv.FormatFloat(s.PerDay, 'f', 1, 64)))
+		}
+	} else {
+		cw.Write(append([]string{"collected_at"}, append(keys, "prefixes")...))
+		for _, s := range list {
+			for _, p := range s.Points {
+				cw.Write(append([]string{p.At.UTC().Format(time.RFC3339)}, append(s.keyValues(by), strconv.Itoa(p.Prefixes))...))
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writeTrendCSV: %v", err)
+	}
+	return nil
+}
+
+// writeTrendJSON writes the series with their statistics, and the points
+// unless stats only.
+func writeTrendJSON(w io.Writer, list []*trendSeries, stats bool) error {
+	if stats {
+		for _, s := range list {
+			s.Points = nil
+		}
+	}
+	for _, s := range list {
+		s.PerDay = math.Round(s.PerDay*10) / 10
+		if s.GrowthPct != nil {
+			pct := math.Round(*s.GrowthPct*10) / 10
+			s.GrowthPct = &pct
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		return fmt.Errorf("writeTrendJSON: %v", err)
+	}
+	return nil
+}
+
+// parseSince parses a -since age, e.g. 30d, 2w or 12h, into the start time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		if d, err = parseUptime(s); err != nil {
+			return time.Time{}, fmt.Errorf("parseSince: bad age: [%s] (expecting e.g. 30d, 2w, 12h)", s)
+		}
+	}
+	return now.Add(-d), nil
+}
+
+// runTrend writes the trend report of the runs in spec, see loadRuns.
+func runTrend(w io.Writer, spec, since, by, format string, stats bool) error {
+	start, err := parseSince(since, time.Now())
+	if err != nil {
+		return err
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("runTrend: bad format: [%s] (expecting csv or json)", format)
+	}
+	runs, err := loadRuns(spec, start)
+	if err != nil {
+		return err
+	}
+	list, err := newTrend(runs, by)
+	if err != nil {
+		return err
+	}
+	infof("runTrend: %d series over %d runs since %s", len(list), len(runs), start.UTC().Format(time.RFC3339))
+	if format == "json" {
+		return writeTrendJSON(w, list, stats)
+	}
+	return writeTrendCSV(w, list, by, stats)
+}
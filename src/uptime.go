@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var uptimeUnits = map[byte]time.Duration{
+	'y': 365 * 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'd': 24 * time.Hour,
+	'h': time.Hour,
+	'm': time.Minute,
+	's': time.Second,
+}
+
+// parseUptime converts cisco uptime strings into a duration:
+// 00:42:17 (less than one day)
+// 3d04h    (less than one week)
+// 5w2d     (less than one year)
+// 1y8w     (one year or more)
+func parseUptime(s string) (time.Duration, error) {
+	if s == "" || s == "?" || s == "never" {
+		return 0, fmt.Errorf("parseUptime: no uptime: [%s]", s)
+	}
+
+	if strings.Contains(s, ":") {
+		f := strings.Split(s, ":")
+		if len(f) != 3 {
+			return 0, fmt.Errorf("parseUptime: bad clock uptime: [%s]", s)
+		}
+		var d time.Duration
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			v, err := strconv.Atoi(f[i])
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("parseUptime: bad clock uptime: [%s]", s)
+			}
+			d += time.Duration(v) * unit
+		}
+		return d, nil
+	}
+
+	var d time.Duration
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			digits++
+			continue
+		}
+		unit, ok := uptimeUnits[c]
+		if !ok || digits == 0 {
+			return 0, fmt.Errorf("parseUptime: bad uptime: [%s]", s)
+		}
+		v, _ := strconv.Atoi(s[i-digits : i])
+		d += time.Duration(v) * unit
+		digits = 0
+	}
+	if digits != 0 {
+		return 0, fmt.Errorf("parseUptime: missing unit: [%s]", s)
+	}
+
+	return d, nil
+}
+
+// formatUptime renders a duration the way cisco does, see parseUptime.
+func formatUptime(d time.Duration) string {
+	day := 24 * time.Hour
+	week := 7 * day
+	year := 365 * day
+	switch {
+	case d < day:
+		secs := int64(d / time.Second)
+		return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	case d < week:
+		return fmt.Sprintf("%dd%02dh", d/day, (d%day)/time.Hour)
+	case d < year:
+		return fmt.Sprintf("%dw%dd", d/week, (d%week)/day)
+	}
+	return fmt.Sprintf("%dy%dw", d/year, (d%year)/week)
+}
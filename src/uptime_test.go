@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUptime(t *testing.T) {
+	day := 24 * time.Hour
+	cases := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"00:00:00", 0},
+		{"00:42:17", 42*time.Minute + 17*time.Second},
+		{"23:59:59", 24*time.Hour - time.Second},
+		{"3d04h", 3*day + 4*time.Hour},
+		{"5w2d", 5*7*day + 2*day},
+		{"1y8w", 365*day + 8*7*day},
+		{"2y", 2 * 365 * day}, // EOS
+		{"1d2h3m", day + 2*time.Hour + 3*time.Minute},
+	}
+	for _, c := range cases {
+		got, err := parseUptime(c.s)
+		if err != nil {
+			t.Errorf("parseUptime(%q): %v", c.s, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseUptime(%q): got %v, want %v", c.s, got, c.want)
+		}
+	}
+	for _, bad := range []string{"", "?", "never", "1:2", "00:-1:00", "aa:00:00", "5x", "w5", "12"} {
+		if d, err := parseUptime(bad); err == nil {
+			t.Errorf("parseUptime(%q): got %v, want error", bad, d)
+		}
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	for _, s := range []string{"00:00:00", "00:42:17", "3d04h", "5w2d", "1y8w"} {
+		d, err := parseUptime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatUptime(d); got != s {
+			t.Errorf("formatUptime(parseUptime(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestSetUptime(t *testing.T) {
+	var n neigh
+	n.setUptime("01:00:00")
+	if d, ok := n.uptimeDuration(); !ok || d != time.Hour || *n.UptimeSeconds != 3600 {
+		t.Errorf("01:00:00: got %v %v", d, ok)
+	}
+	n.setUptime("never")
+	if d, ok := n.uptimeDuration(); ok || n.UptimeSeconds != nil || n.Uptime != "never" {
+		t.Errorf("never: got %v %v", d, ok)
+	}
+}
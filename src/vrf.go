@@ -0,0 +1,134 @@
+package main
+
+// VRF route distinguishers and route targets, from show vrf detail output
+// (IOS-XE, or IOS-XR show vrf all detail) appended to the capture:
+//
+//	VRF CUST-A (VRF Id = 2); default RD 65000:101; default VPNID <not set>
+//	  Export VPN route-target communities
+//	    RT:65000:101
+//	  Import VPN route-target communities
+//	    RT:65000:101             RT:65000:999
+//
+// or from the route distinguisher headers of show bgp vpnv4 unicast all:
+//
+//	Route Distinguisher: 65000:101 (default for vrf CUST-A)
+//
+// Either may come before or after the neighbors; the VRF attributes are
+// joined onto the neighbors of the same device and VRF.
+
+import (
+	"regexp"
+	"strings"
+)
+
+const sectionVRF = "vrf"
+
+type vrfInfo struct {
+	RD       string
+	ImportRT []string
+	ExportRT []string
+}
+
+var (
+	vrfDetailPattern = regexp.MustCompile(`^VRF (\S+?)(?: \(VRF Id = \d+\))?;\s*(?:default )?RD ([^;]+);`)
+	vrfRDPattern     = regexp.MustCompile(`^Route Distinguisher: (\S+) \(default for vrf (\S+)\)`)
+)
+
+func (scanner *neighScanner) vrf(name string) *vrfInfo {
+	if scanner.vrfs == nil {
+		scanner.vrfs = map[string]*vrfInfo{}
+	}
+	v := scanner.vrfs[name]
+	if v == nil {
+		v = &vrfInfo{}
+		scanner.vrfs[name] = v
+	}
+	return v
+}
+
+// setRD records rd unless not set, the bgp table may fill in an RD the
+// vrf definition left unset.
+func (v *vrfInfo) setRD(rd string) {
+	rd = strings.TrimSpace(rd)
+	if rd == "" || strings.Contains(rd, "not set") {
+		return
+	}
+	v.RD = rd
+}
+
+// parseVRFLine records a line of show vrf detail, or a route distinguisher
+// header. It returns false when line is neither.
+func parseVRFLine(scanner *neighScanner, line string) (bool, error) {
+	if m := vrfRDPattern.FindStringSubmatch(line); m != nil {
+		if err := scanner.flush(); err != nil {
+			return true, err
+		}
+		scanner.vrf(m[2]).setRD(m[1])
+		return true, nil
+	}
+
+	if m := vrfDetailPattern.FindStringSubmatch(line); m != nil {
+		if err := scanner.flush(); err != nil {
+			return true, err
+		}
+		scanner.vrf(m[1]).setRD(m[2])
+		scanner.section = sectionVRF
+		scanner.vrfName = m[1]
+		scanner.vrfRTs = nil
+		return true, nil
+	}
+
+	if scanner.section != sectionVRF {
+		return false, nil
+	}
+	if promptDevice(line) != "" || strings.HasPrefix(line, "BGP ") {
+		scanner.section = "" // vrf detail ended
+		return false, nil
+	}
+
+	v := scanner.vrf(scanner.vrfName)
+	s := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(s, "Import VPN route-target communities"):
+		scanner.vrfRTs = &v.ImportRT
+	case strings.HasPrefix(s, "Export VPN route-target communities"):
+		scanner.vrfRTs = &v.ExportRT
+	case strings.HasPrefix(s, "RT:") && scanner.vrfRTs != nil:
+		for _, f := range splitFields(s) {
+			if rt := strings.TrimPrefix(f, "RT:"); rt != f {
+				*scanner.vrfRTs = appendUnique(*scanner.vrfRTs, rt)
+			}
+		}
+	default:
+		scanner.vrfRTs = nil
+	}
+	return true, nil
+}
+
+// applyVRF copies the VRF attributes found so far onto n.
+func (scanner *neighScanner) applyVRF(n *neigh) {
+	v := scanner.vrfs[n.VRF]
+	if v == nil {
+		return
+	}
+	if v.RD != "" {
+		n.RD = v.RD
+	}
+	if len(v.ImportRT) > 0 {
+		n.ImportRT = v.ImportRT
+	}
+	if len(v.ExportRT) > 0 {
+		n.ExportRT = v.ExportRT
+	}
+}
+
+// applyVRFs joins the VRF attributes onto the neighbors emitted before the
+// VRF sections were found.
+func (scanner *neighScanner) applyVRFs(table map[string]*neigh) {
+	if len(scanner.vrfs) == 0 {
+		return
+	}
+	for _, n := range table {
+		scanner.applyVRF(n)
+	}
+}
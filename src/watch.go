@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	ansiClear     = "\033[H\033[2J"
+	ansiHighlight = "\033[7m" // reverse video
+	ansiReset     = "\033[0m"
+)
+
+// changedNeighbors returns the keys of neighbors that are new or whose
+// state or prefix count differs from the previous table.
+func changedNeighbors(prev, curr map[string]*neigh) map[string]bool {
+	changed := map[string]bool{}
+	if prev == nil {
+		return changed // first iteration
+	}
+	for k, n := range curr {
+		p, found := prev[k]
+		if !found || p.State != n.State || p.Prefixes != n.Prefixes {
+			changed[k] = true
+		}
+	}
+	return changed
+}
+
+// neighChange is a neighbor added, removed or changed between collections.
+type neighChange struct {
+	Kind  string // added, removed, changed
+	Neigh *neigh
+	Prev  *neigh // changed only
+}
+
+// tableChanges lists the differences found by changedNeighbors plus the
+// neighbors gone from curr, sorted by key.
+func tableChanges(prev, curr map[string]*neigh) []*neighChange {
+	var keys []string
+	for k := range changedNeighbors(prev, curr) {
+		keys = append(keys, k)
+	}
+	for k := range prev {
+		if _, found := curr[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []*neighChange
+	for _, k := range keys {
+		p, n := prev[k], curr[k]
+		switch {
+		case n == nil:
+			changes = append(changes, &neighChange{Kind: "removed", Neigh: p})
+		case p == nil:
+			changes = append(changes, &neighChange{Kind: "added", Neigh: n})
+		default:
+			changes = append(changes, &neighChange{Kind: "changed", Neigh: n, Prev: p})
+		}
+	}
+	return changes
+}
+
+// watch re-runs collect every interval, redrawing the table and
+// highlighting neighbors changed since the previous iteration.
+// onCollect, if not nil, is called with the previous and the new filtered table.
+func watch(w io.Writer, interval time.Duration, collect collectFunc, filter *neighFilter, keys []sortKey, cols []*column, onCollect collectHook) {
+	var prev map[string]*neigh
+
+	for {
+		start := time.Now()
+
+		table, err := collect()
+		if err != nil {
+			errorf("watch: %v", err)
+		} else {
+			table = filterTable(table, filter)
+			changed := changedNeighbors(prev, table)
+			if onCollect != nil {
+				onCollect(prev, table)
+			}
+
+			list := neighborList(table)
+			sortNeighbors(list, keys)
+
+			gone := 0
+			for k := range prev {
+				if _, found := table[k]; !found {
+					gone++
+				}
+			}
+
+			fmt.Fprint(w, ansiClear)
+			fmt.Fprintf(w, "%s every %v: %d neighbors, %d changed, %d gone\n\n",
+				start.Format("2006-01-02 15:04:05"), interval, len(list), len(changed), gone)
+			writeTableMarked(w, cols, list, func(n *neigh) bool { return changed[neighKey(n)] })
+
+			prev = table
+		}
+
+		time.Sleep(time.Until(start.Add(interval)))
+	}
+}
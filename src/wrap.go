@@ -0,0 +1,88 @@
+package main
+
+// reassembly of neighbor headers wrapped over several lines, by platforms
+// folding long vrf names or link types, or by a terminal hard-wrapping at
+// its width, even mid-token:
+//
+//	BGP neighbor is 198.51.100.1,  vrf CUST-A-INTERNET-TRANSIT-PRIMARY,
+//	  remote AS 65001, external link
+//	BGP neighbor is 198.51.100.5,  vrf CUST-B-INTERNET-TRANSIT-SECONDARY,  remote AS 650
+//	02, external link
+//
+// The header is held until it is complete (has its link type) or the next
+// line is not a continuation of it.
+
+import "strings"
+
+// maxHeaderContinuations bounds the lines joined onto one header.
+const maxHeaderContinuations = 3
+
+type headerJoiner struct {
+	header  string // held header, empty for none
+	lineNum int
+	joined  int // continuation lines joined so far
+}
+
+// feed passes line to parse, holding back a neighbor header that may
+// continue on the next line.
+func (j *headerJoiner) feed(line string, lineNum int, parse lineConsumerFunc) error {
+	if j.header != "" {
+		header, headerNum := j.header, j.lineNum
+		j.header = ""
+		if j.joined < maxHeaderContinuations && isHeaderContinuation(line) {
+			j.joined++
+			return j.hold(joinHeader(header, line), headerNum, parse)
+		}
+		if err := parse(header, headerNum); err != nil {
+			return err
+		}
+	}
+	if strings.HasPrefix(line, "BGP neighbor is ") {
+		j.joined = 0
+		return j.hold(line, lineNum, parse)
+	}
+	return parse(line, lineNum)
+}
+
+// hold keeps an incomplete header for the next line, and passes a
+// complete one to parse.
+func (j *headerJoiner) hold(header string, lineNum int, parse lineConsumerFunc) error {
+	if strings.Contains(header, " link") {
+		return parse(header, lineNum)
+	}
+	j.header, j.lineNum = header, lineNum
+	return nil
+}
+
+// flush passes the header held at the end of input, if any.
+func (j *headerJoiner) flush(parse lineConsumerFunc) error {
+	if j.header == "" {
+		return nil
+	}
+	header := j.header
+	j.header = ""
+	return parse(header, j.lineNum)
+}
+
+// isHeaderContinuation reports whether line continues a neighbor header:
+// an unindented line that does not start anything else, or one carrying
+// the remaining header fields.
+func isHeaderContinuation(line string) bool {
+	s := strings.TrimSpace(line)
+	if s == "" || strings.HasPrefix(line, "BGP neighbor is ") || promptDevice(line) != "" {
+		return false
+	}
+	if lineIndent(line) == 0 {
+		return true
+	}
+	return strings.Contains(s, "remote AS ") || strings.Contains(s, "local AS ") || strings.HasSuffix(s, " link") || strings.Contains(s, " link ") || strings.Contains(s, " link,")
+}
+
+// joinHeader appends a continuation line. A hard wrap at the terminal width
+// splits a token, and is joined without a space.
+func joinHeader(header, line string) string {
+	if lineIndent(line) == 0 && !strings.HasSuffix(header, ",") && !strings.HasSuffix(header, " ") {
+		return header + line
+	}
+	return strings.TrimRight(header, " ") + " " + strings.TrimSpace(line)
+}
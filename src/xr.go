@@ -0,0 +1,78 @@
+package main
+
+// Cisco IOS-XR "show bgp vrf all neighbors" output, registered as the
+// "iosxr" dialect. The remote AS is on the line after the header, joined
+// onto it by headerJoiner; XR phrasing is mapped onto the IOS lines
+// lineParser already understands:
+//
+//RP/0/RSP0/CPU0:pe2#show bgp vrf all neighbors
+//BGP neighbor is 198.51.100.33, vrf CUST-A
+// Remote AS 65033, local AS 64512, external link
+// Remote router ID 198.51.100.33
+//  BGP state = Established, up for 2d04h
+//(...)
+// For Address Family: IPv4 Unicast
+//  Policy for incoming advertisements is RPL-CUST-IN
+//  18 accepted prefixes, 18 are bestpaths
+//(...)
+//  Last reset 00:00:00
+
+import (
+	"fmt"
+	"strings"
+)
+
+// xrRewrites maps IOS-XR line prefixes to their IOS equivalents.
+var xrRewrites = []struct{ xr, ios string }{
+	{" For Address Family: ", " For address family: "},
+	{"  Policy for incoming advertisements is ", "  Route map for incoming advertisements is "},
+	{"  Policy for outgoing advertisements is ", "  Route map for outgoing advertisements is "},
+	{"  Last reset 00:00:00", "  Last reset never"},
+}
+
+func init() {
+	registerDialect("iosxr", dialectFunc(xrParser))
+}
+
+func xrParser(scanner *neighScanner, line string, lineNum int) error {
+
+	switch {
+	case strings.HasPrefix(line, "BGP neighbor is "):
+		line = strings.Replace(line, " Remote AS ", ", remote AS ", 1)
+	case strings.HasPrefix(line, " Remote router ID ") && scanner.curr != nil:
+		scanner.curr.RouterID = splitFields(line).word(3)
+		return nil
+	case (strings.HasPrefix(line, "  Local host: ") || strings.HasPrefix(line, "  Foreign host: ")) && scanner.curr != nil:
+		parseEndpointLine(scanner.curr, strings.TrimSpace(line)) // before the last reset
+		return nil
+	case strings.HasPrefix(line, "  BGP state = "):
+		line = xrStateReason(line)
+	case strings.HasSuffix(line, " are bestpaths") && strings.Contains(line, " accepted prefixes, "):
+		// "  18 accepted prefixes, 18 are bestpaths"
+		line = fmt.Sprintf("    Prefixes Current: - %s", splitFields(line).word(0))
+	}
+
+	for _, r := range xrRewrites {
+		if strings.HasPrefix(line, r.xr) {
+			line = r.ios + line[len(r.xr):]
+			break
+		}
+	}
+
+	return lineParser(scanner, line, lineNum)
+}
+
+// xrStateReason drops the reason a session is down from the state line,
+// "  BGP state = Idle (No best local address found)", keeping one-word
+// markers like "(Admin)".
+func xrStateReason(line string) string {
+	i := strings.Index(line, " (")
+	if i < 0 {
+		return line
+	}
+	j := strings.IndexByte(line[i:], ')')
+	if j < 0 || !strings.Contains(line[i+2:i+j], " ") {
+		return line
+	}
+	return line[:i] + line[i+j+1:]
+}
@@ -0,0 +1,457 @@
+package main
+
+// minimal YAML emitter driven by json struct tags.
+// Scalars are written in JSON syntax, which is valid YAML.
+//
+// parseYAML decodes the block subset used by intent files: mappings,
+// sequences, plain and quoted scalars, one-level flow collections
+// ([a, b] and {k: v}) and comments. Anchors and block scalars are not supported.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func writeYAML(w io.Writer, list []*neigh) error {
+	var buf bytes.Buffer
+	if len(list) == 0 {
+		buf.WriteString("[]\n")
+	}
+	for _, n := range list {
+		yamlSeqItem(&buf, reflect.ValueOf(n), 0)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writeYAML: %v", err)
+	}
+	return nil
+}
+
+type yamlField struct {
+	name  string
+	value reflect.Value
+}
+
+// yamlFields lists struct fields by json name, honoring "-" and omitempty.
+func yamlFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		name := sf.Name
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		if opts[0] != "" {
+			name = opts[0]
+		}
+		fv := v.Field(i)
+		if strings.Contains(tag, ",omitempty") && yamlEmpty(fv) {
+			continue
+		}
+		fields = append(fields, yamlField{name: name, value: fv})
+	}
+	return fields
+}
+
+// yamlEmpty follows the encoding/json definition of empty for omitempty.
+func yamlEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
+
+func yamlMapFields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	for _, k := range v.MapKeys() {
+		fields = append(fields, yamlField{name: fmt.Sprint(k.Interface()), value: v.MapIndex(k)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+func yamlDeref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// yamlScalar returns the inline form of v, or ok=false when v needs a block.
+func yamlScalar(v reflect.Value) (string, bool) {
+	v = yamlDeref(v)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return "null", true // nil
+	case reflect.Struct:
+		if len(yamlFields(v)) == 0 {
+			return "{}", true
+		}
+		return "", false
+	case reflect.Map:
+		if v.Len() == 0 {
+			return "{}", true
+		}
+		return "", false
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return "[]", true
+		}
+		return "", false
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "null", true
+	}
+	return string(b), true
+}
+
+func yamlBlockFields(v reflect.Value) []yamlField {
+	v = yamlDeref(v)
+	if v.Kind() == reflect.Map {
+		return yamlMapFields(v)
+	}
+	return yamlFields(v)
+}
+
+// yamlMapping writes fields as "key: value" lines.
+// The first line is written without indentation when first is true (after "- ").
+func yamlMapping(buf *bytes.Buffer, fields []yamlField, indent int, first bool) {
+	pad := strings.Repeat("  ", indent)
+	for i, f := range fields {
+		if i > 0 || !first {
+			buf.WriteString(pad)
+		}
+		buf.WriteString(yamlKey(f.name))
+		buf.WriteString(":")
+		if s, ok := yamlScalar(f.value); ok {
+			buf.WriteString(" " + s + "\n")
+			continue
+		}
+		buf.WriteString("\n")
+		v := yamlDeref(f.value)
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			for j := 0; j < v.Len(); j++ {
+				yamlSeqItem(buf, v.Index(j), indent+1)
+			}
+			continue
+		}
+		yamlMapping(buf, yamlBlockFields(v), indent+1, false)
+	}
+}
+
+func yamlSeqItem(buf *bytes.Buffer, v reflect.Value, indent int) {
+	buf.WriteString(strings.Repeat("  ", indent) + "- ")
+	if s, ok := yamlScalar(v); ok {
+		buf.WriteString(s + "\n")
+		return
+	}
+	v = yamlDeref(v)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		buf.WriteString("\n")
+		for j := 0; j < v.Len(); j++ {
+			yamlSeqItem(buf, v.Index(j), indent+1)
+		}
+		return
+	}
+	yamlMapping(buf, yamlBlockFields(v), indent+1, true)
+}
+
+func yamlKey(k string) string {
+	for _, c := range k {
+		if !(c == '_' || c == '-' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b, _ := json.Marshal(k)
+			return string(b)
+		}
+	}
+	return k
+}
+
+type yamlLine struct {
+	num    int // 1-based line number
+	indent int
+	text   string
+}
+
+// parseYAML returns map[string]interface{}, []interface{} or string values.
+func parseYAML(r io.Reader) (interface{}, error) {
+	var lines []*yamlLine
+	err := scanFile(r, func(line string, lineNum int) error {
+		text := yamlStripComment(line)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" || trimmed == "---" {
+			return nil
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return fmt.Errorf("tab indentation")
+		}
+		lines = append(lines, &yamlLine{num: lineNum, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parseYAML: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.node(lines[0].indent)
+	if err != nil {
+		return nil, fmt.Errorf("parseYAML: %v", err)
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("parseYAML: line %d: bad indentation", lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// yamlStripComment removes a # comment outside quotes.
+func yamlStripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+type yamlParser struct {
+	lines []*yamlLine
+	pos   int
+}
+
+func yamlIsSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// node parses the block starting at the current line, indented by indent.
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	l := p.lines[p.pos]
+	if yamlIsSeqItem(l.text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := yamlSplitKey(l.text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return yamlValue(l.text, l.num)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || !yamlIsSeqItem(l.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		// the item content continues the block at its own column: "- key: v"
+		l.indent += len(l.text) - len(rest)
+		l.text = rest
+		v, err := p.node(l.indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || yamlIsSeqItem(l.text) {
+			break
+		}
+		key, rest, ok := yamlSplitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expecting key: value: [%s]", l.num, l.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key: [%s]", l.num, key)
+		}
+		p.pos++
+		if rest != "" {
+			v, err := yamlValue(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		// a sequence may sit at the same indentation as its key
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && yamlIsSeqItem(p.lines[p.pos].text) {
+			v, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		v, err := p.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested parses the block below a line indented by indent, or returns
+// an empty value when there is none.
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return "", nil // null
+	}
+	return p.node(p.lines[p.pos].indent)
+}
+
+// yamlSplitKey splits "key: value" outside quotes and flow collections.
+func yamlSplitKey(text string) (key, rest string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	var quote rune
+	for i, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			k, err := yamlScalarValue(strings.TrimSpace(text[:i]))
+			if err != nil {
+				return "", "", false
+			}
+			return k, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// yamlValue parses an inline value: a scalar or a one-level flow collection.
+func yamlValue(text string, lineNum int) (interface{}, error) {
+	switch {
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("line %d: block scalars not supported", lineNum)
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*"):
+		return nil, fmt.Errorf("line %d: anchors and aliases not supported", lineNum)
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence: [%s]", lineNum, text)
+		}
+		list := []interface{}{}
+		for _, item := range yamlSplitFlow(text[1 : len(text)-1]) {
+			v, err := yamlScalarValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping: [%s]", lineNum, text)
+		}
+		m := map[string]interface{}{}
+		for _, item := range yamlSplitFlow(text[1 : len(text)-1]) {
+			k, v, ok := yamlSplitKey(item)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expecting key: value: [%s]", lineNum, item)
+			}
+			s, err := yamlScalarValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			m[k] = s
+		}
+		return m, nil
+	}
+	v, err := yamlScalarValue(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", lineNum, err)
+	}
+	return v, nil
+}
+
+// yamlSplitFlow splits flow collection items on commas outside quotes.
+func yamlSplitFlow(s string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+func yamlScalarValue(s string) (string, error) {
+	switch {
+	case s == "~" || s == "null":
+		return "", nil
+	case strings.HasPrefix(s, `"`):
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("bad quoted string: [%s]", s)
+		}
+		return u, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("bad quoted string: [%s]", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteYAML(t *testing.T) {
+	up := int64(3600)
+	list := []*neigh{{Device: "pe1", Addr: "198.51.100.1", VRF: "CUST-A", RemoteAS: "65001", State: "Established", Prefixes: 26, UptimeSeconds: &up,
+		Policies: []*afPolicy{{AddressFamily: "IPv4 Unicast", Prefixes: 26}}, Extra: map[string]string{"site": "par 1", "a:b": `say "hi"`}}}
+	want := `- device: "pe1"
+  addr: "198.51.100.1"
+  vrf: "CUST-A"
+  remote_as: "65001"
+  state: "Established"
+  uptime: ""
+  uptime_seconds: 3600
+  prefixes: 26
+  policies:
+    - address_family: "IPv4 Unicast"
+      prefixes: 26
+  extra:
+    "a:b": "say \"hi\""
+    site: "par 1"
+`
+	var out bytes.Buffer
+	if err := writeYAML(&out, list); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	// the emitter output reads back
+	v, err := parseYAML(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, ok := v.([]interface{})
+	if !ok || len(items) != 1 {
+		t.Fatalf("read back: %#v", v)
+	}
+	n := items[0].(map[string]interface{})
+	extra := n["extra"].(map[string]interface{})
+	policies := n["policies"].([]interface{})
+	if n["addr"] != "198.51.100.1" || n["uptime_seconds"] != "3600" || n["uptime"] != "" || extra["a:b"] != `say "hi"` ||
+		policies[0].(map[string]interface{})["address_family"] != "IPv4 Unicast" {
+		t.Errorf("read back: %#v", n)
+	}
+
+	out.Reset()
+	writeYAML(&out, nil)
+	if out.String() != "[]\n" {
+		t.Errorf("empty list: %q", out.String())
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	cases := []struct {
+		name, doc string
+		want      interface{}
+	}{
+		{"scalar", "hello\n", "hello"},
+		{"empty", "# nothing\n---\n", nil},
+		{"mapping", "a: 1\nb: two words # comment\nc: 'it''s'\nd: \"x # y\"\ne: ~\n", m{"a": "1", "b": "two words", "c": "it's", "d": "x # y", "e": ""}},
+		{"nested", "pe1:\n  CUST-A:\n    - 198.51.100.1\n    - 198.51.100.2\n  CUST-B: []\n", m{"pe1": m{"CUST-A": l{"198.51.100.1", "198.51.100.2"}, "CUST-B": l{}}}},
+		{"sequence at key indentation", "neighbors:\n- addr: 198.51.100.1\n  asn: 65001\n- addr: 198.51.100.2\n", m{"neighbors": l{m{"addr": "198.51.100.1", "asn": "65001"}, m{"addr": "198.51.100.2"}}}},
+		{"flow", "vrfs: [CUST-A, 'CUST,B', \"C\"]\nneighbor: {addr: 198.51.100.1, asn: 65001}\nnone: []\n", m{"vrfs": l{"CUST-A", "CUST,B", "C"}, "neighbor": m{"addr": "198.51.100.1", "asn": "65001"}, "none": l{}}},
+		{"nested sequence", "-\n  - a\n  - b\n- c\n", l{l{"a", "b"}, "c"}},
+		{"null value", "a:\nb: 1\n", m{"a": "", "b": "1"}},
+		{"url value", "url: http://host:8080/path\n", m{"url": "http://host:8080/path"}},
+	}
+	for _, c := range cases {
+		got, err := parseYAML(strings.NewReader(c.doc))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	cases := []struct {
+		doc, want string // in the error
+	}{
+		{"a: 1\na: 2\n", "line 2: duplicate key"},
+		{"a:\n\tb: 1\n", "tab indentation"},
+		{"a: |\n  text\n", "line 1: block scalars not supported"},
+		{"a: &x 1\n", "anchors and aliases not supported"},
+		{"a: [1, 2\n", "unterminated flow sequence"},
+		{"a: {b: 1\n", "unterminated flow mapping"},
+		{"a: {b}\n", "expecting key: value"},
+		{"a: \"open\n", "bad quoted string"},
+		{"a: 1\n  b: 2\n", "line 2: bad indentation"},
+		{"- a\nb: 1\n", "line 2: bad indentation"},
+	}
+	for _, c := range cases {
+		_, err := parseYAML(strings.NewReader(c.doc))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: error %v, want %q", c.doc, err, c.want)
+		}
+	}
+}